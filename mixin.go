@@ -0,0 +1,126 @@
+package pugtemplate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"flamingo.me/flamingo/v3/framework/config"
+	"flamingo.me/flamingo/v3/framework/flamingo"
+	"flamingo.me/flamingo/v3/framework/web"
+	"flamingo.me/pugtemplate/pugjs"
+	"github.com/pkg/errors"
+)
+
+type (
+	// MixinController renders a single mixin of a template as HTML fragment, e.g. to update parts of a page
+	MixinController struct {
+		Engine    *pugjs.Engine   `inject:""`
+		Logger    flamingo.Logger `inject:""`
+		Allowlist config.Slice    `inject:"config:pug_template.mixins.allowlist,optional"`
+	}
+
+	// mixinCall are the arguments and attributes of the mixin, like in +name(args...)&attributes(attributes)
+	mixinCall struct {
+		Args       []interface{}          `json:"args"`
+		Attributes map[string]interface{} `json:"attributes"`
+	}
+)
+
+// Get renders the mixin of the query parameters tpl and mixin, the call is read from the json request body,
+// or from the json query parameters args and attributes
+func (mc *MixinController) Get(ctx context.Context, r *web.Request) web.Result {
+	tplName, _ := r.Query1("tpl")
+	mixin, _ := r.Query1("mixin")
+
+	if !mc.allowed(mixin) {
+		return mixinResponse(http.StatusForbidden, strings.NewReader("mixin not allowed"))
+	}
+
+	call, err := readMixinCall(r)
+	if err != nil {
+		mc.logger(ctx).Debug(err)
+		return mixinResponse(http.StatusBadRequest, strings.NewReader("invalid mixin call"))
+	}
+
+	body, err := mc.render(ctx, tplName, mixin, call)
+	if errors.Is(err, pugjs.ErrMixinNotFound) || errors.Is(err, pugjs.ErrTemplateNotFound) {
+		mc.logger(ctx).Debug(err)
+		return mixinResponse(http.StatusNotFound, strings.NewReader("mixin not found"))
+	} else if err != nil {
+		mc.logger(ctx).Error(err)
+		return mixinResponse(http.StatusInternalServerError, strings.NewReader("mixin could not be rendered"))
+	}
+
+	return mixinResponse(http.StatusOK, body)
+}
+
+// render the mixin call, template errors panic and are returned as error
+func (mc *MixinController) render(ctx context.Context, tplName, mixin string, call *mixinCall) (body io.Reader, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			var ok bool
+			if err, ok = r.(error); !ok {
+				err = fmt.Errorf("%v", r)
+			}
+			err = errors.Wrapf(err, "mixin %s of template %s failed", mixin, tplName)
+		}
+	}()
+	return mc.Engine.RenderMixin(ctx, tplName, mixin, call.Args, call.Attributes)
+}
+
+func (mc *MixinController) logger(ctx context.Context) flamingo.Logger {
+	return mc.Logger.WithContext(ctx).WithField(flamingo.LogKeyModule, "pugtemplate").WithField(flamingo.LogKeyCategory, "mixin")
+}
+
+// allowed checks the mixin name against the configured allowlist, mixins are not allowed by default
+func (mc *MixinController) allowed(mixin string) bool {
+	var allowlist []string
+	mc.Allowlist.MapInto(&allowlist)
+
+	for _, allowed := range allowlist {
+		if allowed == mixin {
+			return mixin != ""
+		}
+	}
+	return false
+}
+
+func readMixinCall(r *web.Request) (*mixinCall, error) {
+	call := new(mixinCall)
+
+	if req := r.Request(); req.Body != nil && req.Method != http.MethodGet {
+		if err := json.NewDecoder(req.Body).Decode(call); err != nil && err != io.EOF {
+			return nil, errors.Wrap(err, "invalid mixin call")
+		}
+		return call, nil
+	}
+
+	if args, _ := r.Query1("args"); args != "" {
+		if err := json.Unmarshal([]byte(args), &call.Args); err != nil {
+			return nil, errors.Wrap(err, "invalid mixin args")
+		}
+	}
+	if attributes, _ := r.Query1("attributes"); attributes != "" {
+		if err := json.Unmarshal([]byte(attributes), &call.Attributes); err != nil {
+			return nil, errors.Wrap(err, "invalid mixin attributes")
+		}
+	}
+	return call, nil
+}
+
+func mixinResponse(status int, body io.Reader) *web.Response {
+	contentType := "text/plain; charset=utf-8"
+	if status == http.StatusOK {
+		contentType = "text/html; charset=utf-8"
+	}
+
+	return &web.Response{
+		Header: http.Header{"Content-Type": []string{contentType}},
+		Status: uint(status),
+		Body:   body,
+	}
+}
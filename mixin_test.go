@@ -0,0 +1,111 @@
+package pugtemplate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"flamingo.me/flamingo/v3/framework/config"
+	"flamingo.me/flamingo/v3/framework/flamingo"
+	"flamingo.me/flamingo/v3/framework/web"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"flamingo.me/pugtemplate/pugjs"
+)
+
+type failFunc struct{}
+
+func (failFunc) Func(context.Context) interface{} {
+	return func() (string, error) { return "", errors.New("secret backend failure") }
+}
+
+// mixinTestEngine creates an engine with a page template, which defines the mixins button and broken
+func mixinTestEngine(t *testing.T) *pugjs.Engine {
+	t.Helper()
+
+	inline := true
+	code := func(val string) *pugjs.Token {
+		return &pugjs.Token{Type: "Code", Val: val, MustEscape: true, IsInline: &inline}
+	}
+	b, err := json.Marshal(&pugjs.Token{Type: "Block", Nodes: []*pugjs.Token{
+		{Type: "Mixin", Name: "button", Args: "label", Block: &pugjs.Token{Type: "Block", Nodes: []*pugjs.Token{
+			code("label + ' ' + attributes.class"),
+		}}},
+		{Type: "Mixin", Name: "broken", Block: &pugjs.Token{Type: "Block", Nodes: []*pugjs.Token{
+			code("fail()"),
+		}}},
+	}})
+	require.NoError(t, err)
+
+	basedir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(basedir, "template", "page"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(basedir, "template", "page", "page.ast.json"), b, 0o644))
+
+	e := pugjs.NewEngineWithOptions()
+	e.Basedir = basedir
+	e.Logger = flamingo.NullLogger{}
+	e.FuncProvider = func() map[string]flamingo.TemplateFunc {
+		return map[string]flamingo.TemplateFunc{"fail": failFunc{}}
+	}
+	return e
+}
+
+func TestMixinController_Get(t *testing.T) {
+	mc := &MixinController{
+		Engine:    mixinTestEngine(t),
+		Logger:    flamingo.NullLogger{},
+		Allowlist: config.Slice{"button", "broken", "teaser"},
+	}
+
+	get := func(method, target, body string) (*web.Response, string) {
+		req, _ := http.NewRequest(method, target, strings.NewReader(body))
+		response := mc.Get(context.Background(), web.CreateRequest(req, nil)).(*web.Response)
+		b, _ := io.ReadAll(response.Body)
+		return response, string(b)
+	}
+
+	t.Run("mixin", func(t *testing.T) {
+		response, body := get(http.MethodPost, "/_pugtpl/mixin?tpl=page&mixin=button", `{"args": ["buy"], "attributes": {"class": "primary"}}`)
+		assert.Equal(t, uint(http.StatusOK), response.Status)
+		assert.Equal(t, "text/html; charset=utf-8", response.Header.Get("Content-Type"))
+		assert.Equal(t, "buy primary", body)
+	})
+
+	t.Run("not allowed", func(t *testing.T) {
+		response, _ := get(http.MethodGet, "/_pugtpl/mixin?tpl=page&mixin=footer", "")
+		assert.Equal(t, uint(http.StatusForbidden), response.Status)
+		response, _ = get(http.MethodGet, "/_pugtpl/mixin?tpl=page", "")
+		assert.Equal(t, uint(http.StatusForbidden), response.Status)
+	})
+
+	t.Run("invalid call", func(t *testing.T) {
+		response, body := get(http.MethodPost, "/_pugtpl/mixin?tpl=page&mixin=button", `{"args": {}}`)
+		assert.Equal(t, uint(http.StatusBadRequest), response.Status)
+		assert.Equal(t, "invalid mixin call", body)
+		response, _ = get(http.MethodGet, "/_pugtpl/mixin?tpl=page&mixin=button&args=x", "")
+		assert.Equal(t, uint(http.StatusBadRequest), response.Status)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		response, body := get(http.MethodGet, "/_pugtpl/mixin?tpl=page&mixin=teaser", "")
+		assert.Equal(t, uint(http.StatusNotFound), response.Status)
+		assert.Equal(t, "mixin not found", body)
+
+		response, body = get(http.MethodGet, "/_pugtpl/mixin?tpl=missing&mixin=button", "")
+		assert.Equal(t, uint(http.StatusNotFound), response.Status, "unknown templates are not found as well")
+		assert.Equal(t, "mixin not found", body)
+	})
+
+	t.Run("failing mixin", func(t *testing.T) {
+		response, body := get(http.MethodGet, "/_pugtpl/mixin?tpl=page&mixin=broken", "")
+		assert.Equal(t, uint(http.StatusInternalServerError), response.Status)
+		assert.Equal(t, "mixin could not be rendered", body, "errors are logged, not returned")
+	})
+}
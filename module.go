@@ -35,6 +35,7 @@ type (
 
 	routes struct {
 		controller       *DebugController
+		mixinController  *MixinController
 		Basedir          string       `inject:"config:pug_template.basedir"`
 		Whitelist        config.Slice `inject:"config:pug_template.cors_whitelist"`
 		CheckWebpack1337 bool         `inject:"config:pug_template.check_webpack_1337"`
@@ -51,11 +52,25 @@ func (m *Module) CueConfig() string {
 pug_template: {
 	trace?: bool
 	ratelimit: float
+	max_depth?: int
+	partials?: {
+		folder?: string
+		fail_fast?: bool
+	}
+	mixins?: {
+		allowlist: [...string]
+	}
+	fragment_cache?: {
+		size?: int
+		ttl?: =~"^([0-9]+(\\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$"
+	}
 	debug: bool
 	basedir: string
 	cors_whitelist: [...string]
 	check_webpack_1337: bool | *false
 }
+
+core: locale: locale?: string
 `
 }
 
@@ -77,8 +92,9 @@ func (afs assetFileSystem) Open(path string) (http.File, error) {
 }
 
 // Inject - inject func
-func (r *routes) Inject(controller *DebugController) {
+func (r *routes) Inject(controller *DebugController, mixinController *MixinController) {
 	r.controller = controller
+	r.mixinController = mixinController
 }
 
 func assetHandler(whitelisted []string, check1337 bool) http.Handler {
@@ -113,6 +129,9 @@ func (r *routes) Routes(registry *web.RouterRegistry) {
 	registry.MustRoute("/_pugtpl/debug", "pugtpl.debug")
 	registry.HandleGet("pugtpl.debug", r.controller.Get)
 
+	registry.MustRoute("/_pugtpl/mixin", "pugtpl.mixin")
+	registry.HandleAny("pugtpl.mixin", r.mixinController.Get)
+
 	registry.HandleAny("_static", web.WrapHTTPHandler(http.StripPrefix("/static/", assetHandler(whitelist, r.CheckWebpack1337))))
 	registry.MustRoute("/static/*n", "_static")
 
@@ -146,6 +165,7 @@ func (m *Module) Configure(injector *dingo.Injector) {
 	}
 
 	injector.BindMap((*flamingo.TemplateFunc)(nil), "Math").To(templatefunctions.JsMath{})
+	injector.BindMap((*flamingo.TemplateFunc)(nil), "Number").To(templatefunctions.JsNumber{})
 	injector.BindMap((*flamingo.TemplateFunc)(nil), "Object").To(templatefunctions.JsObject{})
 	injector.BindMap((*flamingo.TemplateFunc)(nil), "debug").To(templatefunctions.DebugFunc{})
 	injector.BindMap((*flamingo.TemplateFunc)(nil), "JSON").To(templatefunctions.JsJSON{})
@@ -155,6 +175,13 @@ func (m *Module) Configure(injector *dingo.Injector) {
 	injector.BindMap((*flamingo.TemplateFunc)(nil), "capitalize").To(templatefunctions.CapitalizeFunc{})
 	injector.BindMap((*flamingo.TemplateFunc)(nil), "trim").To(templatefunctions.TrimFunc{})
 	injector.BindMap((*flamingo.TemplateFunc)(nil), "escapeHtml").To(templatefunctions.EscapeHTMLFunc{})
+	injector.BindMap((*flamingo.TemplateFunc)(nil), "setPartialData").To(templatefunctions.SetPartialDataFunc{})
+	injector.BindMap((*flamingo.TemplateFunc)(nil), "setStatus").To(templatefunctions.SetStatusFunc{})
+	injector.BindMap((*flamingo.TemplateFunc)(nil), "setHeader").To(templatefunctions.SetHeaderFunc{})
+	injector.BindMap((*flamingo.TemplateFunc)(nil), "addCacheTag").To(templatefunctions.AddCacheTagFunc{})
+	injector.BindMap((*flamingo.TemplateFunc)(nil), "formatNumber").To(templatefunctions.FormatNumberFunc{})
+	injector.BindMap((*flamingo.TemplateFunc)(nil), "formatCurrency").To(templatefunctions.FormatCurrencyFunc{})
+	injector.BindMap((*flamingo.TemplateFunc)(nil), "formatPercent").To(templatefunctions.FormatPercentFunc{})
 
 	injector.BindMap((*flamingo.TemplateFunc)(nil), "parseInt").To(templatefunctions.ParseInt{})
 
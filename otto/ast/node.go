@@ -36,6 +36,18 @@ type (
 		Value        []Expression
 	}
 
+	ArrayPattern struct {
+		LeftBracket  file.Idx
+		RightBracket file.Idx
+		Elements     []Expression // nil for holes
+		Rest         Expression
+	}
+
+	AssignPattern struct {
+		Target  Expression
+		Default Expression
+	}
+
 	AssignExpression struct {
 		Operator token.Token
 		Left     Expression
@@ -130,12 +142,25 @@ type (
 		Value      []Property
 	}
 
+	ObjectPattern struct {
+		LeftBrace  file.Idx
+		RightBrace file.Idx
+		Properties []PatternProperty
+		Rest       Expression
+	}
+
 	ParameterList struct {
 		Opening file.Idx
-		List    []*Identifier
+		List    []Expression // *Identifier, *ArrayPattern, *ObjectPattern or *AssignPattern
+		Rest    Expression
 		Closing file.Idx
 	}
 
+	PatternProperty struct {
+		Key   string
+		Value Expression
+	}
+
 	Property struct {
 		Key   string
 		Kind  string
@@ -154,12 +179,32 @@ type (
 		Sequence []Expression
 	}
 
+	SpreadElement struct {
+		Spread   file.Idx
+		Argument Expression
+	}
+
 	StringLiteral struct {
 		Idx     file.Idx
 		Literal string
 		Value   string
 	}
 
+	TemplateElement struct {
+		Idx     file.Idx
+		Literal string // raw source text
+		Parsed  string // text with escape sequences resolved
+	}
+
+	// TemplateLiteral has one more element than expressions, the elements surround the expressions
+	TemplateLiteral struct {
+		OpenQuote   file.Idx
+		CloseQuote  file.Idx
+		Tag         Expression // the tag function of a tagged template, or nil
+		Elements    []*TemplateElement
+		Expressions []Expression
+	}
+
 	ThisExpression struct {
 		Idx file.Idx
 	}
@@ -172,7 +217,9 @@ type (
 	}
 
 	VariableExpression struct {
+		Kind        token.Token // VAR, LET or CONST
 		Name        string
+		Target      Expression // *ArrayPattern or *ObjectPattern when destructuring, instead of Name
 		Idx         file.Idx
 		Initializer Expression
 	}
@@ -181,6 +228,8 @@ type (
 // _expressionNode
 
 func (*ArrayLiteral) _expressionNode()          {}
+func (*ArrayPattern) _expressionNode()          {}
+func (*AssignPattern) _expressionNode()         {}
 func (*AssignExpression) _expressionNode()      {}
 func (*BadExpression) _expressionNode()         {}
 func (*BinaryExpression) _expressionNode()      {}
@@ -196,9 +245,12 @@ func (*NewExpression) _expressionNode()         {}
 func (*NullLiteral) _expressionNode()           {}
 func (*NumberLiteral) _expressionNode()         {}
 func (*ObjectLiteral) _expressionNode()         {}
+func (*ObjectPattern) _expressionNode()         {}
 func (*RegExpLiteral) _expressionNode()         {}
 func (*SequenceExpression) _expressionNode()    {}
+func (*SpreadElement) _expressionNode()         {}
 func (*StringLiteral) _expressionNode()         {}
+func (*TemplateLiteral) _expressionNode()       {}
 func (*ThisExpression) _expressionNode()        {}
 func (*UnaryExpression) _expressionNode()       {}
 func (*VariableExpression) _expressionNode()    {}
@@ -268,6 +320,13 @@ type (
 		Body   Statement
 	}
 
+	ForOfStatement struct {
+		For    file.Idx
+		Into   Expression
+		Source Expression
+		Body   Statement
+	}
+
 	ForStatement struct {
 		For         file.Idx
 		Initializer Expression
@@ -319,6 +378,7 @@ type (
 
 	VariableStatement struct {
 		Var  file.Idx
+		Kind token.Token // VAR, LET or CONST
 		List []Expression
 	}
 
@@ -347,6 +407,7 @@ func (*DoWhileStatement) _statementNode()    {}
 func (*EmptyStatement) _statementNode()      {}
 func (*ExpressionStatement) _statementNode() {}
 func (*ForInStatement) _statementNode()      {}
+func (*ForOfStatement) _statementNode()      {}
 func (*ForStatement) _statementNode()        {}
 func (*FunctionStatement) _statementNode()   {}
 func (*IfStatement) _statementNode()         {}
@@ -403,6 +464,8 @@ type Program struct {
 // ==== //
 
 func (self *ArrayLiteral) Idx0() file.Idx          { return self.LeftBracket }
+func (self *ArrayPattern) Idx0() file.Idx          { return self.LeftBracket }
+func (self *AssignPattern) Idx0() file.Idx         { return self.Target.Idx0() }
 func (self *AssignExpression) Idx0() file.Idx      { return self.Left.Idx0() }
 func (self *BadExpression) Idx0() file.Idx         { return self.From }
 func (self *BinaryExpression) Idx0() file.Idx      { return self.Left.Idx0() }
@@ -418,12 +481,20 @@ func (self *NewExpression) Idx0() file.Idx         { return self.New }
 func (self *NullLiteral) Idx0() file.Idx           { return self.Idx }
 func (self *NumberLiteral) Idx0() file.Idx         { return self.Idx }
 func (self *ObjectLiteral) Idx0() file.Idx         { return self.LeftBrace }
+func (self *ObjectPattern) Idx0() file.Idx         { return self.LeftBrace }
 func (self *RegExpLiteral) Idx0() file.Idx         { return self.Idx }
 func (self *SequenceExpression) Idx0() file.Idx    { return self.Sequence[0].Idx0() }
+func (self *SpreadElement) Idx0() file.Idx         { return self.Spread }
 func (self *StringLiteral) Idx0() file.Idx         { return self.Idx }
 func (self *ThisExpression) Idx0() file.Idx        { return self.Idx }
 func (self *UnaryExpression) Idx0() file.Idx       { return self.Idx }
 func (self *VariableExpression) Idx0() file.Idx    { return self.Idx }
+func (self *TemplateLiteral) Idx0() file.Idx {
+	if self.Tag != nil {
+		return self.Tag.Idx0()
+	}
+	return self.OpenQuote
+}
 
 func (self *BadStatement) Idx0() file.Idx        { return self.From }
 func (self *BlockStatement) Idx0() file.Idx      { return self.LeftBrace }
@@ -435,6 +506,7 @@ func (self *DoWhileStatement) Idx0() file.Idx    { return self.Do }
 func (self *EmptyStatement) Idx0() file.Idx      { return self.Semicolon }
 func (self *ExpressionStatement) Idx0() file.Idx { return self.Expression.Idx0() }
 func (self *ForInStatement) Idx0() file.Idx      { return self.For }
+func (self *ForOfStatement) Idx0() file.Idx      { return self.For }
 func (self *ForStatement) Idx0() file.Idx        { return self.For }
 func (self *FunctionStatement) Idx0() file.Idx   { return self.Function.Idx0() }
 func (self *IfStatement) Idx0() file.Idx         { return self.If }
@@ -453,6 +525,8 @@ func (self *WithStatement) Idx0() file.Idx       { return self.With }
 // ==== //
 
 func (self *ArrayLiteral) Idx1() file.Idx          { return self.RightBracket }
+func (self *ArrayPattern) Idx1() file.Idx          { return self.RightBracket + 1 }
+func (self *AssignPattern) Idx1() file.Idx         { return self.Default.Idx1() }
 func (self *AssignExpression) Idx1() file.Idx      { return self.Right.Idx1() }
 func (self *BadExpression) Idx1() file.Idx         { return self.To }
 func (self *BinaryExpression) Idx1() file.Idx      { return self.Right.Idx1() }
@@ -468,10 +542,13 @@ func (self *NewExpression) Idx1() file.Idx         { return self.RightParenthesi
 func (self *NullLiteral) Idx1() file.Idx           { return file.Idx(int(self.Idx) + 4) } // "null"
 func (self *NumberLiteral) Idx1() file.Idx         { return file.Idx(int(self.Idx) + len(self.Literal)) }
 func (self *ObjectLiteral) Idx1() file.Idx         { return self.RightBrace }
+func (self *ObjectPattern) Idx1() file.Idx         { return self.RightBrace + 1 }
 func (self *RegExpLiteral) Idx1() file.Idx         { return file.Idx(int(self.Idx) + len(self.Literal)) }
 func (self *SequenceExpression) Idx1() file.Idx    { return self.Sequence[0].Idx1() }
+func (self *SpreadElement) Idx1() file.Idx         { return self.Argument.Idx1() }
 func (self *StringLiteral) Idx1() file.Idx         { return file.Idx(int(self.Idx) + len(self.Literal)) }
 func (self *ThisExpression) Idx1() file.Idx        { return self.Idx + 4 }
+func (self *TemplateLiteral) Idx1() file.Idx       { return self.CloseQuote + 1 }
 func (self *UnaryExpression) Idx1() file.Idx {
 	if self.Postfix {
 		return self.Operand.Idx1() + 2 // ++ --
@@ -479,6 +556,9 @@ func (self *UnaryExpression) Idx1() file.Idx {
 	return self.Operand.Idx1()
 }
 func (self *VariableExpression) Idx1() file.Idx {
+	if self.Initializer == nil && self.Target != nil {
+		return self.Target.Idx1()
+	}
 	if self.Initializer == nil {
 		return file.Idx(int(self.Idx) + len(self.Name) + 1)
 	}
@@ -495,6 +575,7 @@ func (self *DoWhileStatement) Idx1() file.Idx    { return self.Test.Idx1() }
 func (self *EmptyStatement) Idx1() file.Idx      { return self.Semicolon + 1 }
 func (self *ExpressionStatement) Idx1() file.Idx { return self.Expression.Idx1() }
 func (self *ForInStatement) Idx1() file.Idx      { return self.Body.Idx1() }
+func (self *ForOfStatement) Idx1() file.Idx      { return self.Body.Idx1() }
 func (self *ForStatement) Idx1() file.Idx        { return self.Body.Idx1() }
 func (self *FunctionStatement) Idx1() file.Idx   { return self.Function.Idx1() }
 func (self *IfStatement) Idx1() file.Idx {
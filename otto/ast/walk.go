@@ -38,11 +38,23 @@ func Walk(v Visitor, n Node) {
 				Walk(v, ex)
 			}
 		}
+	case *ArrayPattern:
+		if n != nil {
+			for _, ex := range n.Elements {
+				Walk(v, ex)
+			}
+			Walk(v, n.Rest)
+		}
 	case *AssignExpression:
 		if n != nil {
 			Walk(v, n.Left)
 			Walk(v, n.Right)
 		}
+	case *AssignPattern:
+		if n != nil {
+			Walk(v, n.Target)
+			Walk(v, n.Default)
+		}
 	case *BadExpression:
 	case *BinaryExpression:
 		if n != nil {
@@ -112,6 +124,12 @@ func Walk(v Visitor, n Node) {
 			Walk(v, n.Source)
 			Walk(v, n.Body)
 		}
+	case *ForOfStatement:
+		if n != nil {
+			Walk(v, n.Into)
+			Walk(v, n.Source)
+			Walk(v, n.Body)
+		}
 	case *ForStatement:
 		if n != nil {
 			Walk(v, n.Initializer)
@@ -125,6 +143,7 @@ func Walk(v Visitor, n Node) {
 			for _, p := range n.ParameterList.List {
 				Walk(v, p)
 			}
+			Walk(v, n.ParameterList.Rest)
 			Walk(v, n.Body)
 		}
 	case *FunctionStatement:
@@ -157,6 +176,13 @@ func Walk(v Visitor, n Node) {
 				Walk(v, p.Value)
 			}
 		}
+	case *ObjectPattern:
+		if n != nil {
+			for _, p := range n.Properties {
+				Walk(v, p.Value)
+			}
+			Walk(v, n.Rest)
+		}
 	case *Program:
 		if n != nil {
 			for _, b := range n.Body {
@@ -174,6 +200,10 @@ func Walk(v Visitor, n Node) {
 				Walk(v, e)
 			}
 		}
+	case *SpreadElement:
+		if n != nil {
+			Walk(v, n.Argument)
+		}
 	case *StringLiteral:
 	case *SwitchStatement:
 		if n != nil {
@@ -182,6 +212,13 @@ func Walk(v Visitor, n Node) {
 				Walk(v, c)
 			}
 		}
+	case *TemplateLiteral:
+		if n != nil {
+			Walk(v, n.Tag)
+			for _, e := range n.Expressions {
+				Walk(v, e)
+			}
+		}
 	case *ThisExpression:
 	case *ThrowStatement:
 		if n != nil {
@@ -199,6 +236,7 @@ func Walk(v Visitor, n Node) {
 		}
 	case *VariableExpression:
 		if n != nil {
+			Walk(v, n.Target)
 			Walk(v, n.Initializer)
 		}
 	case *VariableStatement:
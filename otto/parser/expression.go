@@ -2,6 +2,7 @@ package parser
 
 import (
 	"regexp"
+	"strings"
 
 	"flamingo.me/pugtemplate/otto/ast"
 	"flamingo.me/pugtemplate/otto/file"
@@ -78,6 +79,8 @@ func (self *_parser) parsePrimaryExpression() ast.Expression {
 			Literal: literal,
 			Value:   value,
 		}
+	case token.BACKTICK:
+		return self.parseTemplateLiteral(nil)
 	case token.NUMBER:
 		self.next()
 		value, err := parseNumberLiteral(literal)
@@ -174,21 +177,30 @@ func (self *_parser) parseRegExpLiteral() *ast.RegExpLiteral {
 	}
 }
 
-func (self *_parser) parseVariableDeclaration(declarationList *[]*ast.VariableExpression) ast.Expression {
+func (self *_parser) parseVariableDeclaration(kind token.Token, declarationList *[]*ast.VariableExpression) ast.Expression {
 
-	if self.token != token.IDENTIFIER {
+	var node *ast.VariableExpression
+	idx := self.idx
+	switch self.token {
+	case token.IDENTIFIER:
+		literal := self.literal
+		self.next()
+		node = &ast.VariableExpression{
+			Kind: kind,
+			Name: literal,
+			Idx:  idx,
+		}
+	case token.LEFT_BRACKET, token.LEFT_BRACE:
+		node = &ast.VariableExpression{
+			Kind:   kind,
+			Target: self.parseBindingTarget(),
+			Idx:    idx,
+		}
+	default:
 		idx := self.expect(token.IDENTIFIER)
 		self.nextStatement()
 		return &ast.BadExpression{From: idx, To: self.idx}
 	}
-
-	literal := self.literal
-	idx := self.idx
-	self.next()
-	node := &ast.VariableExpression{
-		Name: literal,
-		Idx:  idx,
-	}
 	if self.mode&StoreComments != 0 {
 		self.comments.SetExpression(node)
 	}
@@ -203,12 +215,101 @@ func (self *_parser) parseVariableDeclaration(declarationList *[]*ast.VariableEx
 		}
 		self.next()
 		node.Initializer = self.parseAssignmentExpression()
+	} else if kind == token.CONST && !self.isOf() && self.token != token.IN {
+		self.error(idx, "Missing initializer in const declaration")
+	} else if node.Target != nil && !self.isOf() && self.token != token.IN {
+		self.error(idx, "Missing initializer in destructuring declaration")
 	}
 
 	return node
 }
 
-func (self *_parser) parseVariableDeclarationList(var_ file.Idx) []ast.Expression {
+// parseBindingTarget parses an identifier, an array pattern or an object pattern
+func (self *_parser) parseBindingTarget() ast.Expression {
+	switch self.token {
+	case token.LEFT_BRACKET:
+		return self.parseArrayPattern()
+	case token.LEFT_BRACE:
+		return self.parseObjectPattern()
+	case token.IDENTIFIER:
+		return self.parseIdentifier()
+	}
+	idx := self.expect(token.IDENTIFIER)
+	self.nextStatement()
+	return &ast.BadExpression{From: idx, To: self.idx}
+}
+
+// parseBindingElement parses a binding target with an optional default value
+func (self *_parser) parseBindingElement() ast.Expression {
+	target := self.parseBindingTarget()
+	if self.token != token.ASSIGN {
+		return target
+	}
+	self.next()
+	return &ast.AssignPattern{
+		Target:  target,
+		Default: self.parseAssignmentExpression(),
+	}
+}
+
+func (self *_parser) parseArrayPattern() ast.Expression {
+	node := &ast.ArrayPattern{LeftBracket: self.expect(token.LEFT_BRACKET)}
+	for self.token != token.RIGHT_BRACKET && self.token != token.EOF {
+		if self.token == token.COMMA {
+			node.Elements = append(node.Elements, nil)
+			self.next()
+			continue
+		}
+		if self.token == token.ELLIPSIS {
+			self.next()
+			node.Rest = self.parseBindingTarget()
+			break
+		}
+		node.Elements = append(node.Elements, self.parseBindingElement())
+		if self.token != token.RIGHT_BRACKET {
+			self.expect(token.COMMA)
+		}
+	}
+	node.RightBracket = self.expect(token.RIGHT_BRACKET)
+	return node
+}
+
+func (self *_parser) parseObjectPattern() ast.Expression {
+	node := &ast.ObjectPattern{LeftBrace: self.expect(token.LEFT_BRACE)}
+	for self.token != token.RIGHT_BRACE && self.token != token.EOF {
+		if self.token == token.ELLIPSIS {
+			self.next()
+			node.Rest = self.parseIdentifier()
+			break
+		}
+		idx, tkn := self.idx, self.token
+		_, key := self.parseObjectPropertyKey()
+		var value ast.Expression
+		if self.token == token.COLON {
+			self.next()
+			value = self.parseBindingElement()
+		} else if tkn == token.IDENTIFIER {
+			value = &ast.Identifier{Name: key, Idx: idx}
+			if self.token == token.ASSIGN {
+				self.next()
+				value = &ast.AssignPattern{
+					Target:  value,
+					Default: self.parseAssignmentExpression(),
+				}
+			}
+		} else {
+			self.expect(token.COLON)
+		}
+		node.Properties = append(node.Properties, ast.PatternProperty{Key: key, Value: value})
+		if self.token != token.RIGHT_BRACE {
+			self.expect(token.COMMA)
+		}
+	}
+	node.RightBrace = self.expect(token.RIGHT_BRACE)
+	return node
+}
+
+func (self *_parser) parseVariableDeclarationList(var_ file.Idx, kind token.Token) []ast.Expression {
 
 	var declarationList []*ast.VariableExpression // Avoid bad expressions
 	var list []ast.Expression
@@ -217,7 +318,7 @@ func (self *_parser) parseVariableDeclarationList(var_ file.Idx) []ast.Expressio
 		if self.mode&StoreComments != 0 {
 			self.comments.MarkComments(ast.LEADING)
 		}
-		decl := self.parseVariableDeclaration(&declarationList)
+		decl := self.parseVariableDeclaration(kind, &declarationList)
 		list = append(list, decl)
 		if self.token != token.COMMA {
 			break
@@ -271,7 +372,24 @@ func (self *_parser) parseObjectPropertyKey() (string, string) {
 }
 
 func (self *_parser) parseObjectProperty() ast.Property {
+	if self.token == token.ELLIPSIS {
+		idx := self.idx
+		self.next()
+		return ast.Property{
+			Kind:  "spread",
+			Value: &ast.SpreadElement{Spread: idx, Argument: self.parseAssignmentExpression()},
+		}
+	}
+
+	idx, tkn := self.idx, self.token
 	literal, value := self.parseObjectPropertyKey()
+	if tkn == token.IDENTIFIER && (self.token == token.COMMA || self.token == token.RIGHT_BRACE) {
+		return ast.Property{
+			Key:   value,
+			Kind:  "value",
+			Value: &ast.Identifier{Name: value, Idx: idx},
+		}
+	}
 	if literal == "get" && self.token != token.COLON {
 		idx := self.idx
 		_, value := self.parseObjectPropertyKey()
@@ -363,7 +481,7 @@ func (self *_parser) parseArrayLiteral() ast.Expression {
 			continue
 		}
 
-		exp := self.parseAssignmentExpression()
+		exp := self.parseSpreadOrAssignmentExpression()
 
 		value = append(value, exp)
 		if self.token != token.RIGHT_BRACKET {
@@ -385,6 +503,15 @@ func (self *_parser) parseArrayLiteral() ast.Expression {
 	}
 }
 
+func (self *_parser) parseSpreadOrAssignmentExpression() ast.Expression {
+	if self.token == token.ELLIPSIS {
+		idx := self.idx
+		self.next()
+		return &ast.SpreadElement{Spread: idx, Argument: self.parseAssignmentExpression()}
+	}
+	return self.parseAssignmentExpression()
+}
+
 func (self *_parser) parseArgumentList() (argumentList []ast.Expression, idx0, idx1 file.Idx) {
 	if self.mode&StoreComments != 0 {
 		self.comments.Unset()
@@ -392,7 +519,7 @@ func (self *_parser) parseArgumentList() (argumentList []ast.Expression, idx0, i
 	idx0 = self.expect(token.LEFT_PARENTHESIS)
 	if self.token != token.RIGHT_PARENTHESIS {
 		for {
-			exp := self.parseAssignmentExpression()
+			exp := self.parseSpreadOrAssignmentExpression()
 			if self.mode&StoreComments != 0 {
 				self.comments.SetExpression(exp)
 			}
@@ -553,6 +680,8 @@ func (self *_parser) parseLeftHandSideExpressionAllowCall() ast.Expression {
 			left = self.parseBracketMember(left)
 		} else if self.token == token.LEFT_PARENTHESIS {
 			left = self.parseCallExpression(left)
+		} else if self.token == token.BACKTICK {
+			left = self.parseTemplateLiteral(left)
 		} else {
 			break
 		}
@@ -561,6 +690,64 @@ func (self *_parser) parseLeftHandSideExpressionAllowCall() ast.Expression {
 	return left
 }
 
+// parseTemplateLiteral parses a template literal, the current token is its opening backtick.
+// The text is read directly from the source, the substitutions are parsed as expressions,
+// so they may contain braces and nested template literals.
+func (self *_parser) parseTemplateLiteral(tag ast.Expression) ast.Expression {
+	node := &ast.TemplateLiteral{
+		OpenQuote: self.idx,
+		Tag:       tag,
+	}
+
+	for {
+		start := self.chrOffset
+		for self.chr != '`' && !(self.chr == '$' && self.offset < self.length && self.str[self.offset] == '{') {
+			if self.chr < 0 {
+				self.error(node.OpenQuote, "Unterminated template literal")
+				self.next()
+				return &ast.BadExpression{From: node.OpenQuote, To: self.idx}
+			}
+			if self.chr == '\\' {
+				self.read()
+			}
+			self.read()
+		}
+
+		// line terminators are normalized to \n
+		raw := strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(self.str[start:self.chrOffset])
+		parsed, err := parseStringLiteral(raw)
+		if err != nil {
+			self.error(self.idxOf(start), err.Error())
+		}
+		node.Elements = append(node.Elements, &ast.TemplateElement{
+			Idx:     self.idxOf(start),
+			Literal: raw,
+			Parsed:  parsed,
+		})
+
+		if self.chr == '`' {
+			node.CloseQuote = self.idxOf(self.chrOffset)
+			self.read()
+			break
+		}
+
+		// ${ expression }
+		self.read()
+		self.read()
+		self.next()
+		node.Expressions = append(node.Expressions, self.parseExpression())
+		if self.token != token.RIGHT_BRACE {
+			idx := self.expect(token.RIGHT_BRACE)
+			self.nextStatement()
+			return &ast.BadExpression{From: idx, To: self.idx}
+		}
+	}
+
+	self.insertSemicolon = true
+	self.next()
+	return node
+}
+
 func (self *_parser) parsePostfixExpression() ast.Expression {
 	operand := self.parseLeftHandSideExpressionAllowCall()
 
@@ -204,6 +204,10 @@ func (self *_parser) scan() (tkn token.Token, literal string, idx file.Idx) {
 				if digitValue(self.chr) < 10 {
 					insertSemicolon = true
 					tkn, literal = self.scanNumericLiteral(true)
+				} else if self.chr == '.' && self.offset < self.length && self.str[self.offset] == '.' {
+					self.read()
+					self.read()
+					tkn = token.ELLIPSIS
 				} else {
 					tkn = token.PERIOD
 				}
@@ -293,7 +297,10 @@ func (self *_parser) scan() (tkn token.Token, literal string, idx file.Idx) {
 				tkn = token.BITWISE_NOT
 			case '?':
 				tkn = token.QUESTION_MARK
-			case '"', '\'', '`':
+			case '`':
+				// the parser reads the rest of the template literal, see parseTemplateLiteral
+				tkn = token.BACKTICK
+			case '"', '\'':
 				insertSemicolon = true
 				tkn = token.STRING
 				var err error
@@ -125,6 +125,13 @@ func testMarshalNode(node interface{}) interface{} {
 			"Body", marshal("", node.Body),
 		)
 
+	case *ast.ForOfStatement:
+		return marshal("ForOf",
+			"Into", marshal("", node.Into),
+			"Source", marshal("", node.Source),
+			"Body", marshal("", node.Body),
+		)
+
 	case *ast.FunctionLiteral:
 		return marshal("Function", testMarshalNode(node.Body))
 
@@ -284,10 +284,17 @@ func TestParserErr(t *testing.T) {
 
 	test("\u203f = 1", "(anonymous): Line 1:1 Unexpected token ILLEGAL")
 
+	test("const x = 12, y;", "(anonymous): Line 1:15 Missing initializer in const declaration")
+	test("const x, y = 12;", "(anonymous): Line 1:7 Missing initializer in const declaration")
+	test("const x;", "(anonymous): Line 1:7 Missing initializer in const declaration")
+	test("var let;", "(anonymous): Line 1:5 Unexpected token let")
+	test("var {a};", "(anonymous): Line 1:5 Missing initializer in destructuring declaration")
+	test("var [a, ...b, c] = d;", "(anonymous): Line 1:13 Unexpected token ,")
+	test("var {a: 1} = b;", "(anonymous): Line 1:9 Unexpected number")
+	test("function abc(...a, b) {}", "(anonymous): Line 1:18 Unexpected token ,")
+	test("[.. abc]", "(anonymous): Line 1:2 Unexpected token .")
+
 	// TODO
-	// const x = 12, y;
-	// const x, y = 12;
-	// const x;
 	// if(true) let a = 1;
 	// if(true) const  a = 1;
 
@@ -405,6 +412,34 @@ func TestParserErr(t *testing.T) {
 
 	test(`for (var abc=def, ghi=("abc" in {}); true;) {}`, nil)
 
+	test(`for (var abc of [1, 2]) {}`, nil)
+
+	test(`for (abc of def) {}`, nil)
+
+	test(`for (var of of def) {}`, nil)
+
+	test(`for (let abc = 0; abc < 2; abc++) { const def = abc; }`, nil)
+
+	test(`for (const abc of [1, 2]) {}`, nil)
+
+	test(`for (const abc in {}) {}`, nil)
+
+	test(`for (abc, def of []) {}`, "(anonymous): Line 1:1 Invalid left-hand side in for-of")
+
+	test(`[...abc, 1, ...[def]]`, nil)
+
+	test(`({...abc, def, ghi: 1, ...{}})`, nil)
+
+	test(`abc(...def, 1)`, nil)
+
+	test(`var {abc, def: {ghi = 1}, ...jkl} = mno`, nil)
+
+	test(`const [abc, , def = 2, ...ghi] = jkl`, nil)
+
+	test(`for (const [abc, def] of ghi) {}`, nil)
+
+	test(`function abc({def}, [ghi], jkl = 1, ...mno) {}`, nil)
+
 	{
 		// Semicolon insertion
 
@@ -427,9 +462,7 @@ func TestParserErr(t *testing.T) {
 		test("abc.class = 1", nil)
 		test("var class;", "(anonymous): Line 1:5 Unexpected reserved word")
 
-		test("const", "(anonymous): Line 1:1 Unexpected reserved word")
 		test("abc.const = 1", nil)
-		test("var const;", "(anonymous): Line 1:5 Unexpected reserved word")
 
 		test("enum", "(anonymous): Line 1:1 Unexpected reserved word")
 		test("abc.enum = 1", nil)
@@ -462,9 +495,7 @@ func TestParserErr(t *testing.T) {
 		test(`abc.interface = 1`, nil)
 		test(`var interface;`, nil)
 
-		test(`let`, nil)
 		test(`abc.let = 1`, nil)
-		test(`var let;`, nil)
 
 		test(`package`, nil)
 		test(`abc.package = 1`, nil)
@@ -1005,3 +1036,46 @@ func TestPosition(t *testing.T) {
 	assert.EqualValues(t, node.Idx0(), file.Idx(1))
 	assert.EqualValues(t, node.Idx1(), file.Idx(5))
 }
+
+func TestParseTemplateLiteral(t *testing.T) {
+	parse := func(source string) *ast.TemplateLiteral {
+		_, program, err := testParse(source)
+		assert.NoError(t, err)
+		return program.Body[0].(*ast.ExpressionStatement).Expression.(*ast.TemplateLiteral)
+	}
+
+	node := parse("`abc`")
+	assert.Len(t, node.Elements, 1)
+	assert.Empty(t, node.Expressions)
+	assert.Equal(t, "abc", node.Elements[0].Parsed)
+	assert.EqualValues(t, 1, node.Idx0())
+	assert.EqualValues(t, 6, node.Idx1())
+
+	node = parse("`a${ {b: 1}.b }c${`d${e}`}\\${f}\r\ng`")
+	assert.Len(t, node.Elements, 3)
+	assert.Equal(t, "a", node.Elements[0].Parsed)
+	assert.Equal(t, "c", node.Elements[1].Parsed)
+	assert.Equal(t, "\\${f}\ng", node.Elements[2].Literal)
+	assert.Equal(t, "${f}\ng", node.Elements[2].Parsed)
+	assert.IsType(t, &ast.DotExpression{}, node.Expressions[0])
+	nested := node.Expressions[1].(*ast.TemplateLiteral)
+	assert.Equal(t, "d", nested.Elements[0].Parsed)
+	assert.Equal(t, "e", nested.Expressions[0].(*ast.Identifier).Name)
+
+	node = parse("abc.def`x${1}y`")
+	assert.Equal(t, "def", node.Tag.(*ast.DotExpression).Identifier.Name)
+	assert.Equal(t, []string{"x", "y"}, []string{node.Elements[0].Parsed, node.Elements[1].Parsed})
+
+	_, program, err := testParse("var abc = `x`\nabc")
+	assert.NoError(t, err)
+	assert.Len(t, program.Body, 2)
+
+	for source, message := range map[string]string{
+		"`abc":        "(anonymous): Line 1:1 Unterminated template literal",
+		"`abc${def`":  "(anonymous): Line 1:10 Unterminated template literal",
+		"`abc${def)`": "(anonymous): Line 1:10 Unexpected token )",
+	} {
+		_, _, err := testParse(source)
+		assert.EqualError(t, firstErr(err), message, source)
+	}
+}
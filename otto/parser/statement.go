@@ -82,7 +82,7 @@ func (self *_parser) parseStatement() ast.Statement {
 		return self.parseDebuggerStatement()
 	case token.WITH:
 		return self.parseWithStatement()
-	case token.VAR:
+	case token.VAR, token.LET, token.CONST:
 		return self.parseVariableStatement()
 	case token.FUNCTION:
 		return self.parseFunctionStatement()
@@ -217,14 +217,15 @@ func (self *_parser) parseFunctionParameterList() *ast.ParameterList {
 	if self.mode&StoreComments != 0 {
 		self.comments.Unset()
 	}
-	var list []*ast.Identifier
+	var list []ast.Expression
+	var rest ast.Expression
 	for self.token != token.RIGHT_PARENTHESIS && self.token != token.EOF {
-		if self.token != token.IDENTIFIER {
-			self.expect(token.IDENTIFIER)
-		} else {
-			identifier := self.parseIdentifier()
-			list = append(list, identifier)
+		if self.token == token.ELLIPSIS {
+			self.next()
+			rest = self.parseBindingTarget()
+			break
 		}
+		list = append(list, self.parseBindingElement())
 		if self.token != token.RIGHT_PARENTHESIS {
 			if self.mode&StoreComments != 0 {
 				self.comments.Unset()
@@ -237,6 +238,7 @@ func (self *_parser) parseFunctionParameterList() *ast.ParameterList {
 	return &ast.ParameterList{
 		Opening: opening,
 		List:    list,
+		Rest:    rest,
 		Closing: closing,
 	}
 }
@@ -532,6 +534,23 @@ func (self *_parser) parseForIn(into ast.Expression) *ast.ForInStatement {
 	return forin
 }
 
+func (self *_parser) parseForOf(into ast.Expression) *ast.ForOfStatement {
+
+	// Already have consumed "<into> of"
+
+	source := self.parseAssignmentExpression()
+	self.expect(token.RIGHT_PARENTHESIS)
+	body := self.parseIterationStatement()
+
+	forof := &ast.ForOfStatement{
+		Into:   into,
+		Source: source,
+		Body:   body,
+	}
+
+	return forof
+}
+
 func (self *_parser) parseFor(initializer ast.Expression) *ast.ForStatement {
 
 	// Already have consumed "<initializer> ;"
@@ -577,19 +596,20 @@ func (self *_parser) parseForOrForInStatement() ast.Statement {
 	var left []ast.Expression
 
 	forIn := false
+	forOf := false
 	if self.token != token.SEMICOLON {
 
 		allowIn := self.scope.allowIn
 		self.scope.allowIn = false
-		if self.token == token.VAR {
-			var_ := self.idx
+		if self.token == token.VAR || self.token == token.LET || self.token == token.CONST {
+			var_, kind := self.idx, self.token
 			var varComments []*ast.Comment
 			if self.mode&StoreComments != 0 {
 				varComments = self.comments.FetchAll()
 				self.comments.Unset()
 			}
 			self.next()
-			list := self.parseVariableDeclarationList(var_)
+			list := self.parseVariableDeclarationList(var_, kind)
 			if len(list) == 1 && self.token == token.IN {
 				if self.mode&StoreComments != 0 {
 					self.comments.Unset()
@@ -597,6 +617,13 @@ func (self *_parser) parseForOrForInStatement() ast.Statement {
 				self.next() // in
 				forIn = true
 				left = []ast.Expression{list[0]} // There is only one declaration
+			} else if len(list) == 1 && self.isOf() {
+				if self.mode&StoreComments != 0 {
+					self.comments.Unset()
+				}
+				self.next() // of
+				forOf = true
+				left = []ast.Expression{list[0]} // There is only one declaration
 			} else {
 				left = list
 			}
@@ -608,11 +635,31 @@ func (self *_parser) parseForOrForInStatement() ast.Statement {
 			if self.token == token.IN {
 				self.next()
 				forIn = true
+			} else if self.isOf() {
+				self.next()
+				forOf = true
 			}
 		}
 		self.scope.allowIn = allowIn
 	}
 
+	if forOf {
+		switch left[0].(type) {
+		case *ast.Identifier, *ast.DotExpression, *ast.BracketExpression, *ast.VariableExpression:
+			// These are all acceptable
+		default:
+			self.error(idx, "Invalid left-hand side in for-of")
+			self.nextStatement()
+			return &ast.BadStatement{From: idx, To: self.idx}
+		}
+		forof := self.parseForOf(left[0])
+		if self.mode&StoreComments != 0 {
+			self.comments.CommentMap.AddComments(forof, comments, ast.LEADING)
+			self.comments.CommentMap.AddComments(forof, forComments, ast.FOR)
+		}
+		return forof
+	}
+
 	if forIn {
 		switch left[0].(type) {
 		case *ast.Identifier, *ast.DotExpression, *ast.BracketExpression, *ast.VariableExpression:
@@ -644,17 +691,24 @@ func (self *_parser) parseForOrForInStatement() ast.Statement {
 	return forstatement
 }
 
+// isOf checks for the contextual "of" keyword of for-of statements
+func (self *_parser) isOf() bool {
+	return self.token == token.IDENTIFIER && self.literal == "of"
+}
+
 func (self *_parser) parseVariableStatement() *ast.VariableStatement {
 	var comments []*ast.Comment
 	if self.mode&StoreComments != 0 {
 		comments = self.comments.FetchAll()
 	}
-	idx := self.expect(token.VAR)
+	idx, kind := self.idx, self.token
+	self.next()
 
-	list := self.parseVariableDeclarationList(idx)
+	list := self.parseVariableDeclarationList(idx, kind)
 
 	statement := &ast.VariableStatement{
 		Var:  idx,
+		Kind: kind,
 		List: list,
 	}
 	if self.mode&StoreComments != 0 {
@@ -910,7 +964,7 @@ func (self *_parser) nextStatement() {
 		switch self.token {
 		case token.BREAK, token.CONTINUE,
 			token.FOR, token.IF, token.RETURN, token.SWITCH,
-			token.VAR, token.DO, token.TRY, token.WITH,
+			token.VAR, token.LET, token.CONST, token.DO, token.TRY, token.WITH,
 			token.WHILE, token.THROW, token.CATCH, token.FINALLY:
 			// Return only if parser made some progress since last
 			// sync or if it has not reached 10 next calls without
@@ -78,14 +78,13 @@ type _keyword struct {
 }
 
 // IsKeyword returns the keyword token if literal is a keyword, a KEYWORD token
-// if the literal is a future keyword (const, let, class, super, ...), or 0 if the literal is not a keyword.
+// if the literal is a future keyword (class, super, ...), or 0 if the literal is not a keyword.
 //
 // If the literal is a keyword, IsKeyword returns a second value indicating if the literal
 // is considered a future keyword in strict-mode only.
 //
 // 7.6.1.2 Future Reserved Words:
 //
-//	const
 //	class
 //	enum
 //	export
@@ -97,7 +96,6 @@ type _keyword struct {
 //
 //	implements
 //	interface
-//	let
 //	package
 //	private
 //	protected
@@ -66,6 +66,7 @@ const (
 	LEFT_BRACE       // {
 	COMMA            // ,
 	PERIOD           // .
+	ELLIPSIS         // ...
 
 	RIGHT_PARENTHESIS // )
 	RIGHT_BRACKET     // ]
@@ -73,6 +74,7 @@ const (
 	SEMICOLON         // ;
 	COLON             // :
 	QUESTION_MARK     // ?
+	BACKTICK          // `
 
 	firstKeyword
 	IF
@@ -81,6 +83,7 @@ const (
 
 	VAR
 	FOR
+	LET
 	NEW
 	TRY
 
@@ -95,6 +98,7 @@ const (
 	CATCH
 	THROW
 
+	CONST
 	RETURN
 	TYPEOF
 	DELETE
@@ -165,17 +169,20 @@ var token2string = [...]string{
 	LEFT_BRACE:                  "{",
 	COMMA:                       ",",
 	PERIOD:                      ".",
+	ELLIPSIS:                    "...",
 	RIGHT_PARENTHESIS:           ")",
 	RIGHT_BRACKET:               "]",
 	RIGHT_BRACE:                 "}",
 	SEMICOLON:                   ";",
 	COLON:                       ":",
 	QUESTION_MARK:               "?",
+	BACKTICK:                    "`",
 	IF:                          "if",
 	IN:                          "in",
 	DO:                          "do",
 	VAR:                         "var",
 	FOR:                         "for",
+	LET:                         "let",
 	NEW:                         "new",
 	TRY:                         "try",
 	THIS:                        "this",
@@ -187,6 +194,7 @@ var token2string = [...]string{
 	BREAK:                       "break",
 	CATCH:                       "catch",
 	THROW:                       "throw",
+	CONST:                       "const",
 	RETURN:                      "return",
 	TYPEOF:                      "typeof",
 	DELETE:                      "delete",
@@ -279,8 +287,7 @@ var keywordTable = map[string]_keyword{
 		token: INSTANCEOF,
 	},
 	"const": _keyword{
-		token:         KEYWORD,
-		futureKeyword: true,
+		token: CONST,
 	},
 	"class": _keyword{
 		token:         KEYWORD,
@@ -317,9 +324,7 @@ var keywordTable = map[string]_keyword{
 		strict:        true,
 	},
 	"let": _keyword{
-		token:         KEYWORD,
-		futureKeyword: true,
-		strict:        true,
+		token: LET,
 	},
 	"package": _keyword{
 		token:         KEYWORD,
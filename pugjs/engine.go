@@ -29,19 +29,32 @@ import (
 type (
 	// renderState holds information about the pug abstract syntax tree
 	renderState struct {
-		path         string
-		mixin        map[string]string
-		mixincalls   map[string]struct{}
-		mixinorder   []string
-		mixincounter int
-		mixinblocks  []string
-		mixinblock   string
-		funcs        FuncMap
-		rawmode      bool
-		doctype      string
-		debug        bool
-		eventRouter  flamingo.EventRouter
-		logger       flamingo.Logger
+		path          string
+		mixin         map[string]string
+		mixincalls    map[string]struct{}
+		mixinorder    []string
+		mixincounter  int
+		mixinblocks   []string
+		namedblocks   map[string]struct{}
+		mixinblock    string
+		functions     map[string]string
+		functioncalls map[string]struct{}
+		functionorder []string
+		infunction    bool
+		codeblocks    []*Block
+		breakables    []string
+		labelcounter  int
+		tempcounter   int
+		scopes        []map[string]bool
+		line          int     // line of the code being compiled
+		exproffset    int     // offset of the expression in the parsed javascript source
+		errors        []error // compile errors, e.g. assignments to constants
+		funcs         FuncMap
+		rawmode       bool
+		doctype       string
+		debug         bool
+		eventRouter   flamingo.EventRouter
+		logger        flamingo.Logger
 	}
 
 	templateFuncProvider func() map[string]flamingo.TemplateFunc
@@ -54,18 +67,28 @@ type (
 		Basedir         string `inject:"config:pug_template.basedir"`
 		Debug           bool   `inject:"config:flamingo.debug.mode"`
 		Trace           bool   `inject:"config:pug_template.trace,optional"`
+		Locale          string `inject:"config:core.locale.locale,optional"`
 		Assetrewrites   map[string]string
 		templatesLoaded int32
 		templates       map[string]*Template
 		TemplateCode    map[string]string
 		// Webpackserver flag
 		// Deprecated: not used anymore
-		Webpackserver    bool
-		EventRouter      flamingo.EventRouter `inject:""`
-		FuncProvider     templateFuncProvider `inject:""`
-		Logger           flamingo.Logger      `inject:""`
-		ratelimit        chan struct{}
-		CheckWebpack1337 bool `inject:"config:pug_template.check_webpack_1337"`
+		Webpackserver     bool
+		EventRouter       flamingo.EventRouter  `inject:""`
+		FuncProvider      templateFuncProvider  `inject:""`
+		PrototypeProvider prototypeFuncProvider `inject:",optional"`
+		FieldNameResolver FieldNameResolver     `inject:",optional"`
+		GlobalsProviders  []GlobalsProvider     `inject:",optional"`
+		Logger            flamingo.Logger       `inject:""`
+		ratelimit         chan struct{}
+		maxDepth          int
+		partialsFolder    string
+		partialsFailFast  bool
+		fragmentCache     FragmentCache
+		fragmentCacheTTL  time.Duration
+		types             *objectTypes
+		CheckWebpack1337  bool `inject:"config:pug_template.check_webpack_1337"`
 	}
 
 	// EngineOption options to configure the Engine
@@ -82,6 +105,8 @@ type (
 const (
 	// PageKey is used as constant in WithValue function and in module.go
 	PageKey key = "page.template"
+	// LocaleKey is the context key for the locale used to format numbers
+	LocaleKey key = "pugtemplate.locale"
 )
 
 var (
@@ -93,6 +118,17 @@ var (
 	loggerInstance flamingo.Logger
 )
 
+// ErrTemplateNotFound is returned if the engine has no template of the name
+var ErrTemplateNotFound = errors.New("template not found")
+
+// templateNotFoundError is an ErrTemplateNotFound for the template name
+type templateNotFoundError string
+
+func (e templateNotFoundError) Error() string { return "Template " + string(e) + " not found!" }
+
+// Is ErrTemplateNotFound
+func (templateNotFoundError) Is(target error) bool { return target == ErrTemplateNotFound }
+
 func init() {
 	_ = opencensus.View("flamingo/pugtemplate/render", rt, view.Distribution(50, 100, 250, 500, 1000, 2000), templateKey)
 	_ = opencensus.View("flamingo/pugtemplate/ratelimit/waittime", statRateLimitWaitTime, view.Distribution(0.0001, 0.001, 0.01, 0.1, 1, 10, 100, 1000, 10000), templateKey)
@@ -152,11 +188,35 @@ func WithRateLimit(rateLimit int) EngineOption {
 	}
 }
 
+// WithMaxDepth limits the nesting of the data of a render, deeper values are undefined. A value of zero is unlimited.
+func WithMaxDepth(maxDepth int) EngineOption {
+	return func(e *Engine) {
+		e.maxDepth = maxDepth
+	}
+}
+
+// WithPartialsFolder sets the folder suffix of partial templates, e.g. "partials" for "{templatename}.partials/{partial}".
+// The folders "partials" and "partial" are always searched as fallback.
+func WithPartialsFolder(folder string) EngineOption {
+	return func(e *Engine) {
+		e.partialsFolder = strings.TrimPrefix(folder, ".")
+	}
+}
+
+// WithPartialsFailFast makes RenderPartials fail if one of the partials fails, instead of leaving it out
+func WithPartialsFailFast(failFast bool) EngineOption {
+	return func(e *Engine) {
+		e.partialsFailFast = failFast
+	}
+}
+
 // NewEngineWithOptions create a new Engine with options
 func NewEngineWithOptions(opt ...EngineOption) *Engine {
 	engine := &Engine{
-		RWMutex:      new(sync.RWMutex),
-		TemplateCode: make(map[string]string),
+		RWMutex:          new(sync.RWMutex),
+		TemplateCode:     make(map[string]string),
+		fragmentCache:    NewLRUFragmentCache(defaultFragmentCacheSize),
+		fragmentCacheTTL: defaultFragmentCacheTTL,
 	}
 
 	engine.applyOptions(opt...)
@@ -165,10 +225,30 @@ func NewEngineWithOptions(opt ...EngineOption) *Engine {
 
 // Inject injects dependencies
 func (e *Engine) Inject(cfg *struct {
-	RateLimit float64 `inject:"config:pug_template.ratelimit"`
+	RateLimit        float64       `inject:"config:pug_template.ratelimit"`
+	MaxDepth         float64       `inject:"config:pug_template.max_depth,optional"`
+	PartialsFolder   string        `inject:"config:pug_template.partials.folder,optional"`
+	PartialsFailFast bool          `inject:"config:pug_template.partials.fail_fast,optional"`
+	CacheSize        float64       `inject:"config:pug_template.fragment_cache.size,optional"`
+	CacheTTL         string        `inject:"config:pug_template.fragment_cache.ttl,optional"`
+	FragmentCache    FragmentCache `inject:",optional"`
 }) {
+	// an invalid ttl falls back to the default ttl, like other invalid options
+	cacheTTL, err := time.ParseDuration(cfg.CacheTTL)
+	if err != nil && cfg.CacheTTL != "" && e.Logger != nil {
+		e.Logger.Error(errors.Wrap(err, "pug_template.fragment_cache.ttl is invalid, using the default"))
+	}
+
 	// Also mind NewEngine regarding instance configuration
-	e.applyOptions(WithRateLimit(int(cfg.RateLimit)))
+	e.applyOptions(
+		WithRateLimit(int(cfg.RateLimit)),
+		WithMaxDepth(int(cfg.MaxDepth)),
+		WithPartialsFolder(cfg.PartialsFolder),
+		WithPartialsFailFast(cfg.PartialsFailFast),
+		WithFragmentCacheSize(int(cfg.CacheSize)),
+		WithFragmentCacheTTL(cacheTTL),
+		WithFragmentCache(cfg.FragmentCache),
+	)
 }
 
 func (e *Engine) applyOptions(opt ...EngineOption) {
@@ -181,12 +261,15 @@ func (e *Engine) applyOptions(opt ...EngineOption) {
 
 func newRenderState(path string, debug bool, eventRouter flamingo.EventRouter, logger flamingo.Logger) *renderState {
 	return &renderState{
-		path:        path,
-		mixin:       make(map[string]string),
-		mixincalls:  make(map[string]struct{}),
-		debug:       debug,
-		eventRouter: eventRouter,
-		logger:      logger,
+		path:          path,
+		mixin:         make(map[string]string),
+		mixincalls:    make(map[string]struct{}),
+		functions:     make(map[string]string),
+		functioncalls: make(map[string]struct{}),
+		namedblocks:   make(map[string]struct{}),
+		debug:         debug,
+		eventRouter:   eventRouter,
+		logger:        logger,
 	}
 }
 
@@ -206,6 +289,8 @@ func (e *Engine) LoadTemplates(filtername string) error {
 
 	start := time.Now()
 
+	e.types = e.objectTypes()
+
 	manifest, err := os.ReadFile(path.Join(e.Basedir, "manifest.json"))
 	if err == nil {
 		_ = json.Unmarshal(manifest, &e.Assetrewrites)
@@ -228,6 +313,15 @@ func (e *Engine) LoadTemplates(filtername string) error {
 	return nil
 }
 
+// objectTypes returns the prototype methods and field names bound for the engine
+func (e *Engine) objectTypes() *objectTypes {
+	types := &objectTypes{fields: newFieldNames(e.FieldNameResolver)}
+	if e.PrototypeProvider != nil {
+		types.prototypes = newPrototypes(e.PrototypeProvider())
+	}
+	return types
+}
+
 // compileDir returns a map of defined templates in directory dirname
 func (e *Engine) compileDir(root, dirname, filtername string) (map[string]*Template, error) {
 	result := make(map[string]*Template)
@@ -290,21 +384,92 @@ var _ flamingo.TemplateEngine = new(Engine)
 var _ flamingo.PartialTemplateEngine = new(Engine)
 
 // RenderPartials is used for progressive enhancements / rendering of partial template areas
-// usually this is requested via the appropriate javascript headers and taken care of in the framework renderer
+// usually this is requested via the appropriate javascript headers and taken care of in the framework renderer.
+// The partials are rendered concurrently within one rate limit slot. The data is converted once and shared by the
+// partials as copy-on-write views, so partials can modify the data without affecting each other. A failing partial
+// is logged and left out, unless the engine is configured to fail on partial errors, or all partials fail.
 func (e *Engine) RenderPartials(ctx context.Context, templateName string, data interface{}, partials []string) (map[string]io.Reader, error) {
-	res := make(map[string]io.Reader, len(partials))
+	ctx, span := trace.StartSpan(ctx, "pug/renderPartials")
+	defer span.End()
 
+	span.Annotate(nil, templateName)
+
+	ctx, options, release, err := e.begin(ctx, templateName)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	converted := newConverter(options, objectTypesFromContext(ctx)).convert(data, 0)
+
+	type result struct {
+		partial string
+		buf     *bytes.Buffer
+		err     error
+	}
+	results := make(chan result, len(partials))
 	for _, partial := range partials {
-		buf, err := e.Render(ctx, templateName+".partial/"+partial, data)
-		if err != nil {
-			return nil, err
+		go func(partial string) {
+			// template errors panic, which must not escape the goroutine
+			defer func() {
+				if r := recover(); r != nil {
+					err, ok := r.(error)
+					if !ok {
+						err = fmt.Errorf("%v", r)
+					}
+					results <- result{partial: partial, err: errors.Wrapf(err, "partial %s of template %s failed", partial, templateName)}
+				}
+			}()
+
+			name, ok := e.partialTemplate(templateName, partial)
+			if !ok {
+				results <- result{partial: partial, err: errors.Errorf(`Partial %s of template %s not found!`, partial, templateName)}
+				return
+			}
+			buf, err := e.execute(ctx, name, share(converted))
+			results <- result{partial: partial, buf: buf, err: err}
+		}(partial)
+	}
+
+	res := make(map[string]io.Reader, len(partials))
+	var firstErr error
+	for range partials {
+		r := <-results
+		if r.err != nil {
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			e.Logger.WithContext(ctx).Error(r.err)
+			continue
 		}
-		res[partial] = buf
+		res[r.partial] = r.buf
+	}
+
+	if firstErr != nil && (e.partialsFailFast || len(res) == 0) {
+		return nil, firstErr
 	}
 
 	return res, nil
 }
 
+// partialTemplate returns the name of the partial template, in the configured partials folder or in the
+// folders "{templatename}.partials" and "{templatename}.partial"
+func (e *Engine) partialTemplate(templateName, partial string) (string, bool) {
+	e.RLock()
+	defer e.RUnlock()
+
+	for _, folder := range []string{e.partialsFolder, "partials", "partial"} {
+		if folder == "" {
+			continue
+		}
+		name := templateName + "." + folder + "/" + partial
+		if _, ok := e.templates[name]; ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
 // Render via html/pug_template
 func (e *Engine) Render(ctx context.Context, templateName string, data interface{}) (io.Reader, error) {
 	ctx, span := trace.StartSpan(ctx, "pug/render")
@@ -312,27 +477,67 @@ func (e *Engine) Render(ctx context.Context, templateName string, data interface
 
 	span.Annotate(nil, templateName)
 
-	// block if buffered channel size is reached
-	if cap(e.ratelimit) > 0 {
-		start := time.Now()
-		select {
-		case <-ctx.Done():
-			e.Logger.Debugf("template %s wait failed: %s", templateName, ctx.Err().Error())
-			return nil, fmt.Errorf("template %s wait failed: %w", templateName, ctx.Err())
-		case e.ratelimit <- struct{}{}:
-		}
+	ctx, options, release, err := e.begin(ctx, templateName)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
-		ctx, _ = tag.New(ctx, tag.Upsert(templateKey, templateName))
-		waited := float64(time.Since(start).Nanoseconds() / 1000000.0)
-		stats.Record(ctx, statRateLimitWaitTime.M(waited))
+	converted := newConverter(options, objectTypesFromContext(ctx)).convert(data, 0)
 
-		e.Logger.Debugf("template %s waited %fmsec", templateName, waited)
-		defer func() {
-			// release one entry from channel (will release one block)
-			<-e.ratelimit
-		}()
+	return e.execute(ctx, templateName, converted)
+}
+
+// begin a render: acquire a rate limit slot, prepare the context and load the templates
+func (e *Engine) begin(ctx context.Context, templateName string) (context.Context, RenderOptions, func(), error) {
+	release, err := e.acquire(ctx, templateName)
+	if err != nil {
+		return nil, RenderOptions{}, nil, err
 	}
 
+	ctx, options, err := e.renderContext(ctx, templateName)
+	if err == nil {
+		err = e.load(ctx, templateName)
+	}
+	if err != nil {
+		release()
+		return nil, RenderOptions{}, nil, err
+	}
+
+	// the object types are set after loading, which builds them again in debug mode
+	e.RLock()
+	ctx = withObjectTypes(ctx, e.types)
+	e.RUnlock()
+	return ctx, options, release, nil
+}
+
+// acquire a rate limit slot, blocks if the buffered channel size is reached
+func (e *Engine) acquire(ctx context.Context, templateName string) (release func(), err error) {
+	if cap(e.ratelimit) == 0 {
+		return func() {}, nil
+	}
+
+	start := time.Now()
+	select {
+	case <-ctx.Done():
+		e.Logger.Debugf("template %s wait failed: %s", templateName, ctx.Err().Error())
+		return nil, fmt.Errorf("template %s wait failed: %w", templateName, ctx.Err())
+	case e.ratelimit <- struct{}{}:
+	}
+
+	ctx, _ = tag.New(ctx, tag.Upsert(templateKey, templateName))
+	waited := float64(time.Since(start).Nanoseconds() / 1000000.0)
+	stats.Record(ctx, statRateLimitWaitTime.M(waited))
+
+	e.Logger.Debugf("template %s waited %fmsec", templateName, waited)
+	return func() {
+		// release one entry from channel (will release one block)
+		<-e.ratelimit
+	}, nil
+}
+
+// renderContext adds the page, locale, globals and render options of the template to the context
+func (e *Engine) renderContext(ctx context.Context, templateName string) (context.Context, RenderOptions, error) {
 	p := strings.Split(templateName, "/")
 	for i, v := range p {
 		p[i] = strings.Title(v)
@@ -342,53 +547,86 @@ func (e *Engine) Render(ctx context.Context, templateName string, data interface
 		page = p[len(p)-2] + p[len(p)-1]
 	}
 	ctx = context.WithValue(ctx, PageKey, "page"+page)
+	if e.Locale != "" && LocaleFromContext(ctx) == "" {
+		ctx = WithLocale(ctx, e.Locale)
+	}
+	globals, err := e.globals(ctx)
+	if err != nil {
+		return nil, RenderOptions{}, errors.Wrapf(err, "template %s globals failed", templateName)
+	}
+	ctx = context.WithValue(ctx, globalsKey{}, globals)
+	if e.fragmentCache != nil {
+		ctx = withFragmentCache(ctx, &fragmentCache{store: e.fragmentCache, ttl: e.fragmentCacheTTL})
+	}
+
+	options, ok := RenderOptionsFromContext(ctx)
+	if !ok {
+		options = RenderOptions{MaxDepth: e.maxDepth}
+		ctx = WithRenderOptions(ctx, options)
+	}
+	return ctx, options, nil
+}
 
+// load the templates, in debug mode the template and its partials are recompiled
+func (e *Engine) load(ctx context.Context, templateName string) error {
 	// recompile, make sure to fully load only once!
 	if atomic.LoadInt32(&e.templatesLoaded) == 0 && !e.Debug {
 		_, spanLoad := trace.StartSpan(ctx, "pug/loadAllTemplates")
-		if err := e.LoadTemplates(""); err != nil {
-			spanLoad.End()
-			return nil, err
-		}
-		spanLoad.End()
+		defer spanLoad.End()
+		return e.LoadTemplates("")
 	} else if e.Debug {
 		_, spanLoad := trace.StartSpan(ctx, "pug/loadTemplate")
+		defer spanLoad.End()
 		spanLoad.Annotate(nil, templateName)
-		if err := e.LoadTemplates(templateName); err != nil {
-			spanLoad.End()
-			return nil, err
-		}
-		spanLoad.End()
+		return e.LoadTemplates(templateName)
 	}
+	return nil
+}
 
-	// make sure template loading has finished by now!
-	e.RLock()
-
+// execute the loaded template with the converted data
+func (e *Engine) execute(ctx context.Context, templateName string, data Object) (*bytes.Buffer, error) {
 	result := new(bytes.Buffer)
+	if err := e.executeTo(ctx, result, templateName, data); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
 
+// executeTo executes the loaded template with the converted data and writes the output to wr
+func (e *Engine) executeTo(ctx context.Context, wr io.Writer, templateName string, data Object) error {
+	return e.executeDefinition(ctx, wr, templateName, templateName, data)
+}
+
+// executeDefinition executes a definition of the loaded template, e.g. a mixin, and writes the output to wr
+func (e *Engine) executeDefinition(ctx context.Context, wr io.Writer, templateName, definition string, data Object) error {
+	// make sure template loading has finished by now!
+	e.RLock()
 	templateInstance, ok := e.templates[templateName]
 	e.RUnlock()
 	if !ok {
-		return nil, errors.Errorf(`Template %s not found!`, templateName)
+		return templateNotFoundError(templateName)
 	}
 
 	ctx, execSpan := trace.StartSpan(ctx, "pug/execute")
 	execSpan.Annotate(nil, templateName)
 	start := time.Now()
-	err := templateInstance.ExecuteTemplate(ctx, result, templateName, convert(data), e.Trace)
+	err := templateInstance.ExecuteTemplate(ctx, wr, definition, data, e.Trace)
 	execSpan.End()
 	ctx, _ = tag.New(ctx, tag.Upsert(templateKey, templateName))
 	stats.Record(ctx, rt.M(time.Since(start).Nanoseconds()/1000000))
 
 	if err != nil {
+		e.RLock()
+		code := e.TemplateCode[templateName]
+		e.RUnlock()
 		errstr := err.Error() + "\n"
-		for i, l := range strings.Split(e.TemplateCode[templateName], "\n") {
+		for i, l := range strings.Split(code, "\n") {
 			errstr += fmt.Sprintf("%03d: %s\n", i+1, strings.TrimSpace(strings.TrimSuffix(l, `{{- "" -}}`)))
 		}
-		return nil, errors.New(errstr)
+		return errors.New(errstr)
 	}
 
-	return result, nil
+	return nil
 }
 
 // setLoggerInfos - used to set the package variables used in the panicOrError method
@@ -0,0 +1,279 @@
+package pugjs
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+)
+
+type (
+	// FragmentCache stores the rendered output of cache blocks, e.g. in memory or in a shared store
+	FragmentCache interface {
+		// Get returns the fragment of the key, if it is stored and not expired
+		Get(ctx context.Context, key string) ([]byte, bool)
+		// Set stores the fragment for the ttl
+		Set(ctx context.Context, key string, fragment []byte, ttl time.Duration)
+	}
+
+	// LRUFragmentCache is an in-memory FragmentCache, which evicts the least recently used fragments
+	LRUFragmentCache struct {
+		mu      sync.Mutex
+		size    int
+		entries map[string]*list.Element
+		order   *list.List
+		now     func() time.Time
+	}
+
+	lruEntry struct {
+		key      string
+		fragment []byte
+		expires  time.Time
+	}
+
+	// fragmentCache is the fragment cache of a render with the default ttl of the engine
+	fragmentCache struct {
+		store FragmentCache
+		ttl   time.Duration
+	}
+
+	// cachedFragment is what is stored for a cache block: the output together with the metadata the block set
+	cachedFragment struct {
+		Fragment  []byte      `json:"fragment"`
+		Status    int         `json:"status,omitempty"`
+		Header    http.Header `json:"header,omitempty"`
+		CacheTags []string    `json:"cacheTags,omitempty"`
+	}
+
+	fragmentCacheKey struct{}
+)
+
+// cacheMixin is the name of the built-in mixin which caches its block, unless a template defines a mixin of that name
+const cacheMixin = "cache"
+
+const (
+	defaultFragmentCacheSize = 1000
+	defaultFragmentCacheTTL  = time.Minute
+)
+
+// NewLRUFragmentCache creates an in-memory fragment cache holding up to size fragments
+func NewLRUFragmentCache(size int) *LRUFragmentCache {
+	if size <= 0 {
+		size = defaultFragmentCacheSize
+	}
+	return &LRUFragmentCache{
+		size:    size,
+		entries: make(map[string]*list.Element, size),
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
+
+// Get returns the fragment of the key, expired fragments are removed
+func (c *LRUFragmentCache) Get(_ context.Context, key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*lruEntry)
+	if !c.now().Before(entry.expires) {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return entry.fragment, true
+}
+
+// Set stores the fragment, the least recently used fragment is evicted if the cache is full
+func (c *LRUFragmentCache) Set(_ context.Context, key string, fragment []byte, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &lruEntry{key: key, fragment: fragment, expires: c.now().Add(ttl)}
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// WithFragmentCache sets the store of cache blocks, by default fragments are cached in memory
+func WithFragmentCache(cache FragmentCache) EngineOption {
+	return func(e *Engine) {
+		if cache != nil {
+			e.fragmentCache = cache
+		}
+	}
+}
+
+// WithFragmentCacheSize limits the number of fragments of the default in-memory fragment cache
+func WithFragmentCacheSize(size int) EngineOption {
+	return func(e *Engine) {
+		if _, ok := e.fragmentCache.(*LRUFragmentCache); ok || e.fragmentCache == nil {
+			e.fragmentCache = NewLRUFragmentCache(size)
+		}
+	}
+}
+
+// WithFragmentCacheTTL sets the ttl of cache blocks without a ttl attribute
+func WithFragmentCacheTTL(ttl time.Duration) EngineOption {
+	return func(e *Engine) {
+		if ttl <= 0 {
+			ttl = defaultFragmentCacheTTL
+		}
+		e.fragmentCacheTTL = ttl
+	}
+}
+
+func withFragmentCache(ctx context.Context, cache *fragmentCache) context.Context {
+	return context.WithValue(ctx, fragmentCacheKey{}, cache)
+}
+
+func fragmentCacheFromContext(ctx context.Context) *fragmentCache {
+	if ctx == nil {
+		return nil
+	}
+	cache, _ := ctx.Value(fragmentCacheKey{}).(*fragmentCache)
+	return cache
+}
+
+// walkCache executes the cache mixin `+cache(key...)(ttl=60)`: the block bound to the call is replayed from the
+// fragment cache, or executed and stored under the key of the template, see cacheKey. The status code, headers
+// and cache tags set by the block are stored with the fragment and added to the render metadata on every hit.
+func (s *state) walkCache(dot reflect.Value) {
+	call, ok := s.conv.convert(dot, 0).(*Array)
+	if !ok || len(call.items) < 3 {
+		s.errorf("invalid call of mixin %s", cacheMixin)
+	}
+	args, _ := call.items[0].(*Array)
+	attributes, _ := call.items[1].(*Map)
+
+	blockName, ok := call.items[2].(String)
+	if !ok {
+		return
+	}
+	// the block is unbound in any case, so later blocks of the same name are not mixed up
+	scope, found := s.unfreeze(string(blockName))
+	tmpl := s.tmpl.tmpl[string(blockName)]
+	if tmpl == nil {
+		return
+	}
+	if !found {
+		scope = *s
+	}
+
+	key := s.cacheKey(args)
+	ctx := s.ctx.Interface().(context.Context)
+
+	metadata := RenderMetadataFromContext(ctx)
+	if s.cache != nil {
+		if stored, ok := s.cache.store.Get(ctx, key); ok {
+			var cached cachedFragment
+			if err := json.Unmarshal(stored, &cached); err == nil {
+				if _, err := s.wr.Write(cached.Fragment); err != nil {
+					s.writeError(err)
+				}
+				if metadata != nil {
+					metadata.merge(cached.metadata())
+				}
+				return
+			}
+		}
+	}
+
+	// the block collects its own metadata, so it can be stored with the fragment and replayed on hits
+	blockMetadata := newRenderMetadata()
+	fragment := new(bytes.Buffer)
+	scope.wr = io.MultiWriter(s.wr, fragment)
+	scope.ctx = reflect.ValueOf(context.WithValue(ctx, renderMetadataKey{}, blockMetadata))
+	scope.depth++
+	scope.tmpl = tmpl
+	scope.walk(reflect.Value{}, tmpl.Root)
+	if metadata != nil {
+		metadata.merge(blockMetadata)
+	}
+
+	if s.cache != nil {
+		stored, err := json.Marshal(cachedFragment{
+			Fragment:  fragment.Bytes(),
+			Status:    blockMetadata.Status(),
+			Header:    blockMetadata.Header(),
+			CacheTags: blockMetadata.CacheTags(),
+		})
+		if err != nil {
+			s.errorf("mixin %s: %v", cacheMixin, err)
+		}
+		s.cache.store.Set(ctx, key, stored, s.cacheTTL(attributes))
+	}
+}
+
+func (f cachedFragment) metadata() *RenderMetadata {
+	metadata := newRenderMetadata()
+	if f.Status != 0 {
+		_ = metadata.SetStatus(f.Status)
+	}
+	for key, values := range f.Header {
+		metadata.header[key] = values
+	}
+	metadata.AddCacheTag(f.CacheTags...)
+	return metadata
+}
+
+// cacheKey is the key of the cache mixin arguments, prefixed with the name of the template defining the block,
+// e.g. `pages/home:["footer","en"]`. Only strings, numbers, booleans and null are allowed as arguments,
+// so building the key never calls getters of the data.
+func (s *state) cacheKey(args *Array) string {
+	if args == nil || len(args.items) == 0 {
+		s.errorf("mixin %s needs a key", cacheMixin)
+	}
+	for _, arg := range args.items {
+		switch arg.(type) {
+		case String, Number, Bool, Nil, Undefined:
+		default:
+			s.errorf("mixin %s key must be strings, numbers or booleans, got %s", cacheMixin, runtimeTypeof(arg))
+		}
+	}
+	key, err := json.Marshal(args)
+	if err != nil {
+		s.errorf("mixin %s key: %v", cacheMixin, err)
+	}
+	return s.tmpl.root + ":" + string(key)
+}
+
+// cacheTTL is the ttl attribute of the cache mixin, in seconds or as duration string like "10m"
+func (s *state) cacheTTL(attributes *Map) time.Duration {
+	if attributes == nil || !attributes.HasMember("ttl") {
+		return s.cache.ttl
+	}
+	switch ttl := attributes.Member("ttl").(type) {
+	case Number:
+		return time.Duration(float64(ttl) * float64(time.Second))
+	case String:
+		d, err := time.ParseDuration(string(ttl))
+		if err != nil {
+			s.errorf("mixin %s ttl: %v", cacheMixin, err)
+		}
+		return d
+	}
+	s.errorf("mixin %s ttl must be a number of seconds or a duration", cacheMixin)
+	return 0
+}
@@ -0,0 +1,133 @@
+package pugjs
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"flamingo.me/flamingo/v3/framework/flamingo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_RenderFragmentCache(t *testing.T) {
+	renders := 0
+	cache := func(args string, ttl interface{}, nodes ...*Token) *Token {
+		token := &Token{Type: "Mixin", Name: "cache", Args: args, Call: true, Block: &Token{Type: "Block", Nodes: nodes}}
+		if ttl != nil {
+			token.Attrs = []*Attr{{Name: "ttl", Val: ttl}}
+		}
+		return token
+	}
+
+	e := testEngineTokens(t, FuncMap{"render": func() int { renders++; return renders }}, map[string]*Token{
+		"page": {Type: "Block", Nodes: []*Token{
+			codeToken("var title = 'menu ' + lang"),
+			cache("'menu', lang", 60, codeToken("title + ' ' + render()")),
+			{Type: "Text", Val: "|"},
+			cache("'footer'", "'0s'", codeToken("render()")),
+		}},
+		"nokey":     {Type: "Block", Nodes: []*Token{cache("", nil, codeToken("render()"))}},
+		"objectkey": {Type: "Block", Nodes: []*Token{cache("{lang: lang}", nil, codeToken("render()"))}},
+		"a":         {Type: "Block", Nodes: []*Token{cache("'footer'", nil, &Token{Type: "Text", Val: "footer a"})}},
+		"b":         {Type: "Block", Nodes: []*Token{cache("'footer'", nil, &Token{Type: "Text", Val: "footer b"})}},
+	})
+
+	render := func(name, lang string) (string, error) {
+		r, err := e.Render(context.Background(), name, map[string]interface{}{"lang": lang})
+		if err != nil {
+			return "", err
+		}
+		b, _ := io.ReadAll(r)
+		return string(b), nil
+	}
+
+	out, err := render("page", "en")
+	require.NoError(t, err)
+	assert.Equal(t, "menu en 1|2", out)
+
+	out, err = render("page", "en")
+	require.NoError(t, err)
+	assert.Equal(t, "menu en 1|3", out, "the menu is replayed, the footer with a ttl of 0s is not cached")
+
+	out, err = render("page", "de")
+	require.NoError(t, err)
+	assert.Equal(t, "menu de 4|5", out, "the key depends on the language")
+
+	assert.Panics(t, func() { _, _ = render("nokey", "en") }, "a cache block needs a key")
+	assert.Panics(t, func() { _, _ = render("objectkey", "en") }, "keys are strings, numbers or booleans")
+
+	for _, name := range []string{"a", "b", "a", "b"} {
+		out, err = render(name, "en")
+		require.NoError(t, err)
+		assert.Equal(t, "footer "+name, out, "the key is prefixed with the template")
+	}
+}
+
+func TestEngine_RenderFragmentCacheMetadata(t *testing.T) {
+	renders := 0
+	e := testEngineTokens(t, FuncMap{
+		"render": func() int { renders++; return renders },
+		"tag": func(ctx context.Context) interface{} {
+			return func(tag string) string {
+				if metadata := RenderMetadataFromContext(ctx); metadata != nil {
+					metadata.AddCacheTag(tag)
+					metadata.SetHeader("Cache-Control", "max-age=60")
+					_ = metadata.SetStatus(http.StatusGone)
+				}
+				return ""
+			}
+		},
+	}, map[string]*Token{
+		"page": {Type: "Block", Nodes: []*Token{{
+			Type: "Mixin", Name: "cache", Args: "'tagged'", Call: true,
+			Block: &Token{Type: "Block", Nodes: []*Token{codeToken("tag('product-' + render()) + render()")}},
+		}}},
+	})
+
+	for i := 0; i < 2; i++ {
+		body, metadata, err := e.RenderWithMetadata(context.Background(), "page", nil)
+		require.NoError(t, err)
+		b, _ := io.ReadAll(body)
+		assert.Equal(t, "2", string(b))
+		assert.Equal(t, []string{"product-1"}, metadata.CacheTags(), "the cache tags are replayed with the fragment")
+		assert.Equal(t, "max-age=60", metadata.Header().Get("Cache-Control"))
+		assert.Equal(t, http.StatusGone, metadata.Status())
+	}
+	assert.Equal(t, 2, renders, "the second render is a cache hit")
+}
+
+func TestLRUFragmentCache(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	c := NewLRUFragmentCache(2)
+	c.now = func() time.Time { return now }
+
+	c.Set(ctx, "a", []byte("A"), time.Minute)
+	c.Set(ctx, "b", []byte("B"), time.Second)
+	_, _ = c.Get(ctx, "a")
+	c.Set(ctx, "c", []byte("C"), time.Minute)
+
+	_, ok := c.Get(ctx, "b")
+	assert.False(t, ok, "the least recently used fragment is evicted")
+	fragment, ok := c.Get(ctx, "a")
+	assert.True(t, ok)
+	assert.Equal(t, "A", string(fragment))
+
+	now = now.Add(time.Minute)
+	_, ok = c.Get(ctx, "c")
+	assert.False(t, ok, "fragments expire after the ttl")
+}
+
+func TestEngine_InjectInvalidFragmentCacheTTL(t *testing.T) {
+	e := NewEngineWithOptions()
+	e.Logger = flamingo.NullLogger{}
+
+	cfg := reflect.New(reflect.TypeOf(e.Inject).In(0).Elem())
+	cfg.Elem().FieldByName("CacheTTL").SetString("ten minutes")
+	assert.NotPanics(t, func() { reflect.ValueOf(e.Inject).Call([]reflect.Value{cfg}) })
+	assert.Equal(t, defaultFragmentCacheTTL, e.fragmentCacheTTL, "an invalid ttl falls back to the default")
+}
@@ -0,0 +1,68 @@
+package pugjs
+
+import (
+	"context"
+	"reflect"
+)
+
+type (
+	// GlobalsProvider contributes values to the global map of every render, pages and partials alike,
+	// which templates access as global, e.g. global.cart.count. Modules bind it as multibinding:
+	//   injector.BindMulti((*pugjs.GlobalsProvider)(nil)).To(CartGlobals{})
+	GlobalsProvider interface {
+		// Globals returns the named values for the render context, which also holds the web request
+		Globals(ctx context.Context) (map[string]interface{}, error)
+	}
+
+	globalsKey struct{}
+)
+
+// WithGlobals adds values to the global map of renders with the context, they take precedence over the values
+// of the GlobalsProvider bindings
+func WithGlobals(ctx context.Context, globals map[string]interface{}) context.Context {
+	merged := make(map[string]interface{}, len(globals))
+	for k, v := range GlobalsFromContext(ctx) {
+		merged[k] = v
+	}
+	for k, v := range globals {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, globalsKey{}, merged)
+}
+
+// GlobalsFromContext returns the global values of the context
+func GlobalsFromContext(ctx context.Context) map[string]interface{} {
+	if ctx == nil {
+		return nil
+	}
+	globals, _ := ctx.Value(globalsKey{}).(map[string]interface{})
+	return globals
+}
+
+// globals collects the values of the providers, later bindings override the values of earlier ones
+func (e *Engine) globals(ctx context.Context) (map[string]interface{}, error) {
+	globals := make(map[string]interface{})
+	for _, provider := range e.GlobalsProviders {
+		values, err := provider.Globals(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range values {
+			globals[k] = v
+		}
+	}
+	for k, v := range GlobalsFromContext(ctx) {
+		globals[k] = v
+	}
+	return globals, nil
+}
+
+// globalMap creates the global map of an execution with the values of the context
+func (c *converter) globalMap(ctx context.Context) Object {
+	globals := GlobalsFromContext(ctx)
+	m := &Map{items: make(map[string]Object, len(globals)+10)}
+	for k, v := range globals {
+		m.items[k] = c.convert(reflect.ValueOf(v), 1)
+	}
+	return m
+}
@@ -0,0 +1,84 @@
+package pugjs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"flamingo.me/flamingo/v3/framework/flamingo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testGlobalsProvider map[string]interface{}
+
+func (p testGlobalsProvider) Globals(ctx context.Context) (map[string]interface{}, error) {
+	if err, ok := p["error"].(error); ok {
+		return nil, err
+	}
+	return p, nil
+}
+
+// testEngine creates an engine with the code templates, loaded already
+func testEngine(t *testing.T, funcs FuncMap, templates map[string]string) *Engine {
+	t.Helper()
+
+	tokens := make(map[string]*Token, len(templates))
+	for name, code := range templates {
+		tokens[name] = &Token{Type: "Block", Nodes: []*Token{codeToken(code)}}
+	}
+	return testEngineTokens(t, funcs, tokens)
+}
+
+// testEngineTokens creates an engine with the token templates, loaded already
+func testEngineTokens(t *testing.T, funcs FuncMap, templates map[string]*Token) *Engine {
+	t.Helper()
+
+	e := NewEngineWithOptions()
+	e.Logger = flamingo.NullLogger{}
+	e.templates = make(map[string]*Template)
+	e.templatesLoaded = 1
+	for name, token := range templates {
+		s := newRenderState("/", false, nil, flamingo.NullLogger{})
+		s.funcs = FuncMap{}
+		for k, f := range funcs {
+			s.funcs[k] = f
+		}
+		tpl, _, err := s.TokenToTemplate(name, token)
+		require.NoError(t, err)
+		e.templates[name] = tpl
+	}
+	return e
+}
+
+func TestEngine_RenderGlobals(t *testing.T) {
+	e := testEngine(t, nil, map[string]string{
+		"page":                  "global.site + ' ' + global.cart.count + ' ' + global.user",
+		"page.partial/minicart": "global.cart.count",
+	})
+	e.GlobalsProviders = []GlobalsProvider{
+		testGlobalsProvider{"site": "shop", "user": "guest"},
+		testGlobalsProvider{"cart": struct{ Count int }{Count: 2}, "user": "jane"},
+	}
+
+	render := func(ctx context.Context, name string) string {
+		t.Helper()
+		r, err := e.Render(ctx, name, nil)
+		require.NoError(t, err)
+		b, _ := io.ReadAll(r)
+		return string(b)
+	}
+
+	assert.Equal(t, "shop 2 jane", render(context.Background(), "page"), "later providers override earlier ones")
+	assert.Equal(t, "shop 2 joe", render(WithGlobals(context.Background(), map[string]interface{}{"user": "joe"}), "page"))
+
+	partials, err := e.RenderPartials(context.Background(), "page", nil, []string{"minicart"})
+	require.NoError(t, err)
+	b, _ := io.ReadAll(partials["minicart"])
+	assert.Equal(t, "2", string(b))
+
+	e.GlobalsProviders = append(e.GlobalsProviders, testGlobalsProvider{"error": errors.New("cart unavailable")})
+	_, err = e.Render(context.Background(), "page", nil)
+	assert.ErrorContains(t, err, "cart unavailable")
+}
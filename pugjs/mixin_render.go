@@ -0,0 +1,49 @@
+package pugjs
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// ErrMixinNotFound is returned by RenderMixin if the template does not define the mixin
+var ErrMixinNotFound = errors.New("mixin not found")
+
+// RenderMixin renders a mixin defined or included by a page, like the call `+name(args...)&attributes(attributes)`.
+// Like in the page the mixin sees the global map, but not the page's data.
+func (e *Engine) RenderMixin(ctx context.Context, templateName, mixin string, args []interface{}, attributes map[string]interface{}) (io.Reader, error) {
+	ctx, options, release, err := e.begin(ctx, templateName)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	e.RLock()
+	templateInstance, ok := e.templates[templateName]
+	e.RUnlock()
+	if !ok {
+		return nil, templateNotFoundError(templateName)
+	}
+	definition := "mixin_" + mixin
+	if templateInstance.Lookup(definition) == nil {
+		return nil, errors.Wrapf(ErrMixinNotFound, "mixin %s of template %s", mixin, templateName)
+	}
+
+	if args == nil {
+		args = []interface{}{}
+	}
+	if attributes == nil {
+		attributes = map[string]interface{}{}
+	}
+	conv := newConverter(options, objectTypesFromContext(ctx))
+	// the arguments of a mixin call are the arguments, the attributes and the block
+	data := &Array{items: []Object{conv.convert(args, 0), conv.convert(attributes, 0), Nil{}}}
+
+	result := new(bytes.Buffer)
+	if err := e.executeDefinition(ctx, result, templateName, definition, data); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,46 @@
+package pugjs
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_RenderMixin(t *testing.T) {
+	e := testEngineTokens(t, nil, map[string]*Token{
+		"page": {Type: "Block", Nodes: []*Token{
+			{Type: "Mixin", Name: "button", Args: "label, count", Block: &Token{Type: "Block", Nodes: []*Token{
+				codeToken("label + ': ' + count + ' ' + attributes.class"),
+			}}},
+			{Type: "Text", Val: "page"},
+		}},
+	})
+
+	read := func(r io.Reader) string {
+		b, _ := io.ReadAll(r)
+		return string(b)
+	}
+
+	t.Run("mixin", func(t *testing.T) {
+		r, err := e.RenderMixin(context.Background(), "page", "button", []interface{}{"buy", 2}, map[string]interface{}{"class": "primary"})
+		require.NoError(t, err)
+		assert.Equal(t, "buy: 2 primary", read(r))
+	})
+
+	t.Run("unknown mixin", func(t *testing.T) {
+		_, err := e.RenderMixin(context.Background(), "page", "teaser", nil, nil)
+		assert.True(t, errors.Is(err, ErrMixinNotFound))
+		assert.EqualError(t, err, "mixin teaser of template page: mixin not found")
+	})
+
+	t.Run("unknown template", func(t *testing.T) {
+		_, err := e.RenderMixin(context.Background(), "missing", "button", nil, nil)
+		assert.EqualError(t, err, "Template missing not found!")
+		assert.True(t, errors.Is(err, ErrTemplateNotFound))
+		assert.False(t, errors.Is(err, ErrMixinNotFound))
+	})
+}
@@ -0,0 +1,168 @@
+package pugjs
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+
+	"flamingo.me/flamingo/v3/framework/web"
+	"github.com/pkg/errors"
+)
+
+type (
+	// blockCapture collects the output of the requested named blocks of one render
+	blockCapture struct {
+		buffers map[string]*bytes.Buffer
+	}
+
+	blockCaptureKey struct{}
+)
+
+// BlockHeader requests named blocks of a page, comma separated, instead of the whole page
+const BlockHeader = "X-Pug-Block"
+
+func newBlockCapture(blocks []string) *blockCapture {
+	capture := &blockCapture{buffers: make(map[string]*bytes.Buffer, len(blocks))}
+	for _, name := range blocks {
+		capture.buffers[name] = new(bytes.Buffer)
+	}
+	return capture
+}
+
+func withBlockCapture(ctx context.Context, capture *blockCapture) context.Context {
+	return context.WithValue(ctx, blockCaptureKey{}, capture)
+}
+
+func blockCaptureFromContext(ctx context.Context) *blockCapture {
+	if ctx == nil {
+		return nil
+	}
+	capture, _ := ctx.Value(blockCaptureKey{}).(*blockCapture)
+	return capture
+}
+
+// BlocksFromRequest returns the named blocks requested by the BlockHeader of the web request. Only the controller
+// rendering the page reads them, and passes them to RenderBlocks, see RenderPage.
+func BlocksFromRequest(r *web.Request) []string {
+	if r == nil || r.Request() == nil {
+		return nil
+	}
+	return splitBlocks(r.Request().Header)
+}
+
+// VaryByBlocks adds the BlockHeader to the Vary header of the response, so caches keep a page and its blocks apart
+func VaryByBlocks(response *web.Response) {
+	if response.Header == nil {
+		response.Header = make(http.Header)
+	}
+	for _, value := range response.Header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if http.CanonicalHeaderKey(strings.TrimSpace(name)) == BlockHeader {
+				return
+			}
+		}
+	}
+	response.Header.Add("Vary", BlockHeader)
+}
+
+func splitBlocks(header http.Header) []string {
+	var blocks []string
+	for _, name := range strings.Split(header.Get(BlockHeader), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			blocks = append(blocks, name)
+		}
+	}
+	return blocks
+}
+
+// beginBlock starts a named block, the output of a requested block is captured as well
+func (s *state) beginBlock(name string) {
+	s.writers = append(s.writers, s.wr)
+	if buf, ok := s.blocks.buffer(name); ok {
+		s.wr = io.MultiWriter(s.wr, buf)
+	}
+}
+
+// endBlock ends the current named block
+func (s *state) endBlock() {
+	if len(s.writers) == 0 {
+		s.errorf("named block ended but not started")
+	}
+	s.wr = s.writers[len(s.writers)-1]
+	s.writers = s.writers[:len(s.writers)-1]
+}
+
+func (c *blockCapture) buffer(name string) (*bytes.Buffer, bool) {
+	if c == nil {
+		return nil, false
+	}
+	buf, ok := c.buffers[name]
+	return buf, ok
+}
+
+// RenderBlocks renders the named blocks of a page with the page's data, e.g. `block content`,
+// the rest of the page is executed but not returned
+func (e *Engine) RenderBlocks(ctx context.Context, templateName string, data interface{}, blocks []string) (map[string]io.Reader, error) {
+	ctx, options, release, err := e.begin(ctx, templateName)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return e.executeBlocks(ctx, templateName, newConverter(options, objectTypesFromContext(ctx)).convert(data, 0), blocks)
+}
+
+// RenderPage renders the page for the web request. If the request asks for named blocks with the BlockHeader,
+// only these blocks are returned, one after another in the requested order. The response varies by the BlockHeader.
+func (e *Engine) RenderPage(ctx context.Context, r *web.Request, templateName string, data interface{}) (*web.Response, error) {
+	var body io.Reader
+	if blocks := BlocksFromRequest(r); len(blocks) > 0 {
+		res, err := e.RenderBlocks(ctx, templateName, data, blocks)
+		if err != nil {
+			return nil, err
+		}
+		readers := make([]io.Reader, len(blocks))
+		for i, name := range blocks {
+			readers[i] = res[name]
+		}
+		body = io.MultiReader(readers...)
+	} else {
+		res, err := e.Render(ctx, templateName, data)
+		if err != nil {
+			return nil, err
+		}
+		body = res
+	}
+
+	response := &web.Response{Status: http.StatusOK, Body: body, Header: make(http.Header)}
+	VaryByBlocks(response)
+	return response, nil
+}
+
+// executeBlocks executes the page and returns the output of the named blocks
+func (e *Engine) executeBlocks(ctx context.Context, templateName string, data Object, blocks []string) (map[string]io.Reader, error) {
+	e.RLock()
+	templateInstance, ok := e.templates[templateName]
+	e.RUnlock()
+	if !ok {
+		return nil, templateNotFoundError(templateName)
+	}
+	for _, name := range blocks {
+		if !templateInstance.HasNamedBlock(name) {
+			return nil, errors.Errorf(`Block %s of template %s not found!`, name, templateName)
+		}
+	}
+
+	capture := newBlockCapture(blocks)
+	if err := e.executeTo(withBlockCapture(ctx, capture), io.Discard, templateName, data); err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]io.Reader, len(blocks))
+	for name, buf := range capture.buffers {
+		res[name] = buf
+	}
+	return res, nil
+}
@@ -0,0 +1,89 @@
+package pugjs
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"flamingo.me/flamingo/v3/framework/web"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_RenderBlocks(t *testing.T) {
+	text := func(val string) *Token { return &Token{Type: "Text", Val: val} }
+	block := func(name string, nodes ...*Token) *Token {
+		return &Token{Type: "NamedBlock", Name: name, Nodes: nodes}
+	}
+
+	e := testEngineTokens(t, nil, map[string]*Token{
+		"page": {Type: "Block", Nodes: []*Token{
+			codeToken("var greeting = 'hello ' + user"),
+			text("<header>"),
+			block("header", text("title")),
+			text("</header><main>"),
+			block("content",
+				codeToken("greeting"),
+				block("teaser", text(" teaser")),
+			),
+			text("</main>"),
+		}},
+	})
+	data := map[string]interface{}{"user": "jane"}
+
+	read := func(r io.Reader) string {
+		b, _ := io.ReadAll(r)
+		return string(b)
+	}
+
+	t.Run("page", func(t *testing.T) {
+		r, err := e.Render(context.Background(), "page", data)
+		require.NoError(t, err)
+		assert.Equal(t, "<header>title</header><main>hello jane teaser</main>", read(r))
+	})
+
+	t.Run("blocks", func(t *testing.T) {
+		blocks, err := e.RenderBlocks(context.Background(), "page", data, []string{"content", "teaser"})
+		require.NoError(t, err)
+		assert.Equal(t, "hello jane teaser", read(blocks["content"]), "blocks see the variables of the page")
+		assert.Equal(t, " teaser", read(blocks["teaser"]))
+		assert.Len(t, blocks, 2)
+	})
+
+	t.Run("unknown block", func(t *testing.T) {
+		_, err := e.RenderBlocks(context.Background(), "page", data, []string{"footer"})
+		assert.EqualError(t, err, "Block footer of template page not found!")
+	})
+
+	t.Run("header", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(BlockHeader, "teaser, header")
+		request := web.CreateRequest(req, nil)
+
+		response, err := e.RenderPage(context.Background(), request, "page", data)
+		require.NoError(t, err)
+		assert.Equal(t, " teasertitle", read(response.Body))
+		assert.Equal(t, BlockHeader, response.Header.Get("Vary"))
+
+		r, err := e.Render(web.ContextWithRequest(context.Background(), request), "page", data)
+		require.NoError(t, err)
+		assert.Equal(t, "<header>title</header><main>hello jane teaser</main>", read(r), "Render ignores the header")
+
+		req, _ = http.NewRequest(http.MethodGet, "/", nil)
+		response, err = e.RenderPage(context.Background(), web.CreateRequest(req, nil), "page", data)
+		require.NoError(t, err)
+		assert.Equal(t, "<header>title</header><main>hello jane teaser</main>", read(response.Body))
+		assert.Equal(t, BlockHeader, response.Header.Get("Vary"), "the whole page varies by the header as well")
+	})
+
+	t.Run("vary", func(t *testing.T) {
+		response := &web.Response{Header: http.Header{"Vary": []string{"Accept-Encoding, x-pug-block"}}}
+		VaryByBlocks(response)
+		assert.Equal(t, []string{"Accept-Encoding, x-pug-block"}, response.Header.Values("Vary"))
+
+		response = &web.Response{}
+		VaryByBlocks(response)
+		assert.Equal(t, []string{BlockHeader}, response.Header.Values("Vary"))
+	})
+}
@@ -38,6 +38,7 @@ type itemType int
 
 const (
 	itemError        itemType = iota // error occurred; value is text of error
+	itemAssign                       // equals ('=') introducing an assignment
 	itemBool                         // boolean constant
 	itemChar                         // printable ASCII character; grab bag for comma etc.
 	itemCharConstant                 // character constant
@@ -72,22 +73,36 @@ const (
 	itemTry
 	itemCatch
 	itemFinally
+	itemWhile    // __while keyword
+	itemStep     // __step keyword
+	itemBreak    // __break keyword
+	itemContinue // __continue keyword
+	itemLabel    // __label keyword
+	itemScope    // __scope keyword
+	itemReturn   // __return keyword
 )
 
 var key = map[string]itemType{
-	".":        itemDot,
-	"block":    itemBlock,
-	"define":   itemDefine,
-	"else":     itemElse,
-	"end":      itemEnd,
-	"if":       itemIf,
-	"range":    itemRange,
-	"nil":      itemNil,
-	"template": itemTemplate,
-	"with":     itemWith,
-	"try":      itemTry,
-	"catch":    itemCatch,
-	"finally":  itemFinally,
+	".":          itemDot,
+	"block":      itemBlock,
+	"define":     itemDefine,
+	"else":       itemElse,
+	"end":        itemEnd,
+	"if":         itemIf,
+	"range":      itemRange,
+	"nil":        itemNil,
+	"template":   itemTemplate,
+	"with":       itemWith,
+	"try":        itemTry,
+	"catch":      itemCatch,
+	"finally":    itemFinally,
+	"__while":    itemWhile,
+	"__step":     itemStep,
+	"__break":    itemBreak,
+	"__continue": itemContinue,
+	"__label":    itemLabel,
+	"__scope":    itemScope,
+	"__return":   itemReturn,
 }
 
 const eof = -1
@@ -377,6 +392,8 @@ func lexInsideAction(l *lexer) stateFn {
 			return l.errorf("expected :=")
 		}
 		l.emit(itemColonEquals)
+	case r == '=':
+		l.emit(itemAssign)
 	case r == '|':
 		l.emit(itemPipe)
 	case r == '"':
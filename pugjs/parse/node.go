@@ -74,6 +74,13 @@ const (
 	NodeWith                       // NodeWith - A with action.
 	NodeTry
 	NodeCatch
+	NodeWhile    // NodeWhile - A while loop.
+	nodeStep     // nodeStep - A step action. Not added to tree.
+	NodeBreak    // NodeBreak - A break action.
+	NodeContinue // NodeContinue - A continue action.
+	NodeLabel    // NodeLabel - A labelled list.
+	NodeScope    // NodeScope - A list with its own variable scope.
+	NodeReturn   // NodeReturn - A return action.
 )
 
 // Nodes.
@@ -154,10 +161,11 @@ func (t *TextNode) Copy() Node {
 type PipeNode struct {
 	NodeType
 	Pos
-	tr   *Tree
-	Line int             // The line number in the input. Deprecated: Kept for compatibility.
-	Decl []*VariableNode // Variable declarations in lexical order.
-	Cmds []*CommandNode  // The commands in lexical order.
+	tr       *Tree
+	Line     int             // The line number in the input. Deprecated: Kept for compatibility.
+	IsAssign bool            // The variables are being assigned, not declared.
+	Decl     []*VariableNode // Variable declarations in lexical order.
+	Cmds     []*CommandNode  // The commands in lexical order.
 }
 
 func (t *Tree) newPipeline(pos Pos, line int, decl []*VariableNode) *PipeNode {
@@ -178,7 +186,11 @@ func (p *PipeNode) String() string {
 			}
 			s += v.String()
 		}
-		s += " := "
+		if p.IsAssign {
+			s += " = "
+		} else {
+			s += " := "
+		}
 	}
 	for i, c := range p.Cmds {
 		if i > 0 {
@@ -203,6 +215,7 @@ func (p *PipeNode) CopyPipe() *PipeNode {
 		decl = append(decl, d.Copy().(*VariableNode))
 	}
 	n := p.tr.newPipeline(p.Pos, p.Line, decl)
+	n.IsAssign = p.IsAssign
 	for _, c := range p.Cmds {
 		n.append(c.Copy().(*CommandNode))
 	}
@@ -940,3 +953,210 @@ func (t *CatchNode) Copy() Node {
 func (t *CatchNode) String() string {
 	return "{{ catch " + t.Exception + " }}"
 }
+
+// WhileNode represents a {{__while}} loop. The Step list is executed after every iteration, even on continue.
+type WhileNode struct {
+	NodeType
+	Pos
+	tr   *Tree
+	Line int       // The line number in the input.
+	Pipe *PipeNode // The condition, evaluated before every iteration.
+	List *ListNode // The loop body.
+	Step *ListNode // What to execute after every iteration (nil if absent).
+}
+
+func (t *Tree) newWhile(pos Pos, line int, pipe *PipeNode, list, step *ListNode) *WhileNode {
+	return &WhileNode{tr: t, NodeType: NodeWhile, Pos: pos, Line: line, Pipe: pipe, List: list, Step: step}
+}
+
+// String formatter
+func (w *WhileNode) String() string {
+	if w.Step != nil {
+		return fmt.Sprintf("{{__while %s}}%s{{__step}}%s{{end}}", w.Pipe, w.List, w.Step)
+	}
+	return fmt.Sprintf("{{__while %s}}%s{{end}}", w.Pipe, w.List)
+}
+
+func (w *WhileNode) tree() *Tree {
+	return w.tr
+}
+
+// Copy a node
+func (w *WhileNode) Copy() Node {
+	var step *ListNode
+	if w.Step != nil {
+		step = w.Step.CopyList()
+	}
+	return w.tr.newWhile(w.Pos, w.Line, w.Pipe.CopyPipe(), w.List.CopyList(), step)
+}
+
+// stepNode represents a {{__step}} action. Does not appear in the final tree.
+type stepNode struct {
+	NodeType
+	Pos
+	tr *Tree
+}
+
+func (t *Tree) newStep(pos Pos) *stepNode {
+	return &stepNode{tr: t, NodeType: nodeStep, Pos: pos}
+}
+
+// String formatter
+func (s *stepNode) String() string {
+	return "{{__step}}"
+}
+
+func (s *stepNode) tree() *Tree {
+	return s.tr
+}
+
+// Copy a node
+func (s *stepNode) Copy() Node {
+	return s.tr.newStep(s.Pos)
+}
+
+// BreakNode represents a {{__break}} action, with an optional label.
+type BreakNode struct {
+	NodeType
+	Pos
+	tr    *Tree
+	Line  int
+	Label string
+}
+
+func (t *Tree) newBreak(pos Pos, line int, label string) *BreakNode {
+	return &BreakNode{tr: t, NodeType: NodeBreak, Pos: pos, Line: line, Label: label}
+}
+
+// String formatter
+func (b *BreakNode) String() string {
+	if b.Label != "" {
+		return fmt.Sprintf("{{__break %q}}", b.Label)
+	}
+	return "{{__break}}"
+}
+
+func (b *BreakNode) tree() *Tree {
+	return b.tr
+}
+
+// Copy a node
+func (b *BreakNode) Copy() Node {
+	return b.tr.newBreak(b.Pos, b.Line, b.Label)
+}
+
+// ContinueNode represents a {{__continue}} action, with an optional label.
+type ContinueNode struct {
+	NodeType
+	Pos
+	tr    *Tree
+	Line  int
+	Label string
+}
+
+func (t *Tree) newContinue(pos Pos, line int, label string) *ContinueNode {
+	return &ContinueNode{tr: t, NodeType: NodeContinue, Pos: pos, Line: line, Label: label}
+}
+
+// String formatter
+func (c *ContinueNode) String() string {
+	if c.Label != "" {
+		return fmt.Sprintf("{{__continue %q}}", c.Label)
+	}
+	return "{{__continue}}"
+}
+
+func (c *ContinueNode) tree() *Tree {
+	return c.tr
+}
+
+// Copy a node
+func (c *ContinueNode) Copy() Node {
+	return c.tr.newContinue(c.Pos, c.Line, c.Label)
+}
+
+// LabelNode represents a {{__label}} list, which can be left with a labelled break.
+// Loops directly inside the list carry the label for labelled continues.
+type LabelNode struct {
+	NodeType
+	Pos
+	tr    *Tree
+	Line  int
+	Label string
+	List  *ListNode
+}
+
+func (t *Tree) newLabel(pos Pos, line int, label string, list *ListNode) *LabelNode {
+	return &LabelNode{tr: t, NodeType: NodeLabel, Pos: pos, Line: line, Label: label, List: list}
+}
+
+// String formatter
+func (l *LabelNode) String() string {
+	return fmt.Sprintf("{{__label %q}}%s{{end}}", l.Label, l.List)
+}
+
+func (l *LabelNode) tree() *Tree {
+	return l.tr
+}
+
+// Copy a node
+func (l *LabelNode) Copy() Node {
+	return l.tr.newLabel(l.Pos, l.Line, l.Label, l.List.CopyList())
+}
+
+// ScopeNode represents a {{__scope}} list, variables declared in the list are dropped at its end.
+type ScopeNode struct {
+	NodeType
+	Pos
+	tr   *Tree
+	Line int
+	List *ListNode
+}
+
+func (t *Tree) newScope(pos Pos, line int, list *ListNode) *ScopeNode {
+	return &ScopeNode{tr: t, NodeType: NodeScope, Pos: pos, Line: line, List: list}
+}
+
+// String formatter
+func (s *ScopeNode) String() string {
+	return fmt.Sprintf("{{__scope}}%s{{end}}", s.List)
+}
+
+func (s *ScopeNode) tree() *Tree {
+	return s.tr
+}
+
+// Copy a node
+func (s *ScopeNode) Copy() Node {
+	return s.tr.newScope(s.Pos, s.Line, s.List.CopyList())
+}
+
+// ReturnNode represents a {{__return}} action, with an optional pipeline for the return value.
+type ReturnNode struct {
+	NodeType
+	Pos
+	tr   *Tree
+	Line int
+	Pipe *PipeNode
+}
+
+func (t *Tree) newReturn(pos Pos, line int, pipe *PipeNode) *ReturnNode {
+	return &ReturnNode{tr: t, NodeType: NodeReturn, Pos: pos, Line: line, Pipe: pipe}
+}
+
+// String formatter
+func (r *ReturnNode) String() string {
+	if r.Pipe != nil {
+		return fmt.Sprintf("{{__return %s}}", r.Pipe)
+	}
+	return "{{__return}}"
+}
+
+func (r *ReturnNode) tree() *Tree {
+	return r.tr
+}
+
+// Copy a node
+func (r *ReturnNode) Copy() Node {
+	return r.tr.newReturn(r.Pos, r.Line, r.Pipe.CopyPipe())
+}
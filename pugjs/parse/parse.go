@@ -29,6 +29,8 @@ type Tree struct {
 	peekCount int
 	vars      []string // variables defined at the moment.
 	treeSet   map[string]*Tree
+	loopDepth int      // nesting level of range and while loops.
+	labels    []string // labels defined at the moment.
 }
 
 // Copy returns a copy of the Tree. Any parsing state is discarded.
@@ -224,6 +226,8 @@ func (t *Tree) stopParse() {
 	t.vars = nil
 	t.funcs = nil
 	t.treeSet = nil
+	t.loopDepth = 0
+	t.labels = nil
 }
 
 // Parse parses the template definition string to construct a representation of
@@ -272,6 +276,13 @@ func IsEmptyTree(n Node) bool {
 	case *TextNode:
 		return len(bytes.TrimSpace(n.Text)) == 0
 	case *WithNode:
+	case *TryNode:
+	case *WhileNode:
+	case *BreakNode:
+	case *ContinueNode:
+	case *LabelNode:
+	case *ScopeNode:
+	case *ReturnNode:
 	default:
 		panic("unknown node: " + n.String())
 	}
@@ -297,7 +308,7 @@ func (t *Tree) parse() {
 			t.backup2(delim)
 		}
 		switch n := t.textOrAction(); n.Type() {
-		case nodeEnd, nodeElse:
+		case nodeEnd, nodeElse, nodeStep:
 			t.errorf("unexpected %s", n)
 		default:
 			t.Root.append(n)
@@ -336,7 +347,7 @@ func (t *Tree) itemList() (list *ListNode, next Node) {
 	for t.peekNonSpace().typ != itemEOF {
 		n := t.textOrAction()
 		switch n.Type() {
-		case nodeEnd, nodeElse, NodeCatch:
+		case nodeEnd, nodeElse, NodeCatch, nodeStep:
 			return list, n
 		}
 		list.append(n)
@@ -389,6 +400,20 @@ func (t *Tree) action() (n Node) {
 		return t.catchControl()
 		//case itemFinally:
 		//t.finallyControl()
+	case itemWhile:
+		return t.whileControl()
+	case itemStep:
+		return t.stepControl()
+	case itemBreak:
+		return t.breakControl(token.pos, token.line)
+	case itemContinue:
+		return t.continueControl(token.pos, token.line)
+	case itemLabel:
+		return t.labelControl(token.pos, token.line)
+	case itemScope:
+		return t.scopeControl(token.pos, token.line)
+	case itemReturn:
+		return t.returnControl(token.pos, token.line)
 	}
 	t.backup()
 	token := t.peek()
@@ -401,9 +426,10 @@ func (t *Tree) action() (n Node) {
 //	declarations? command ('|' command)*
 func (t *Tree) pipeline(context string) (pipe *PipeNode) {
 	var decl []*VariableNode
+	var isAssign bool
 	token := t.peekNonSpace()
 	pos := token.pos
-	// Are there declarations?
+	// Are there declarations or assignments?
 	for {
 		if v := t.peekNonSpace(); v.typ == itemVariable {
 			t.next()
@@ -412,8 +438,9 @@ func (t *Tree) pipeline(context string) (pipe *PipeNode) {
 			// argument variable rather than a declaration. So remember the token
 			// adjacent to the variable so we can push it back if necessary.
 			tokenAfterVariable := t.peek()
-			if next := t.peekNonSpace(); next.typ == itemColonEquals || (next.typ == itemChar && next.val == ",") {
+			if next := t.peekNonSpace(); next.typ == itemColonEquals || next.typ == itemAssign || (next.typ == itemChar && next.val == ",") {
 				t.nextNonSpace()
+				isAssign = next.typ == itemAssign
 				variable := t.newVariable(v.pos, v.val)
 				decl = append(decl, variable)
 				t.vars = append(t.vars, v.val)
@@ -432,6 +459,7 @@ func (t *Tree) pipeline(context string) (pipe *PipeNode) {
 		break
 	}
 	pipe = t.newPipeline(pos, token.line, decl)
+	pipe.IsAssign = isAssign
 	for {
 		switch token := t.nextNonSpace(); token.typ {
 		case itemRightDelim, itemRightParen:
@@ -516,9 +544,142 @@ func (t *Tree) ifControl() Node {
 //
 // Range keyword is past.
 func (t *Tree) rangeControl() Node {
+	t.loopDepth++
+	defer func() { t.loopDepth-- }()
 	return t.newRange(t.parseControl(false, "range"))
 }
 
+// While:
+//
+//	{{__while pipeline}} itemList {{end}}
+//	{{__while pipeline}} itemList {{__step}} itemList {{end}}
+//
+// While keyword is past.
+func (t *Tree) whileControl() Node {
+	defer t.popVars(len(t.vars))
+	pipe := t.pipeline("__while")
+	t.loopDepth++
+	list, next := t.itemList()
+	var step *ListNode
+	if next.Type() == nodeStep {
+		step, next = t.itemList()
+	}
+	t.loopDepth--
+	if next.Type() != nodeEnd {
+		t.errorf("expected end; found %s", next)
+	}
+	return t.newWhile(pipe.Position(), pipe.Line, pipe, list, step)
+}
+
+// Step:
+//
+//	{{__step}}
+//
+// Step keyword is past.
+func (t *Tree) stepControl() Node {
+	return t.newStep(t.expect(itemRightDelim, "__step").pos)
+}
+
+// Break:
+//
+//	{{__break}}
+//	{{__break "label"}}
+//
+// Break keyword is past.
+func (t *Tree) breakControl(pos Pos, line int) Node {
+	return t.newBreak(pos, line, t.branchLabel("__break"))
+}
+
+// Continue:
+//
+//	{{__continue}}
+//	{{__continue "label"}}
+//
+// Continue keyword is past.
+func (t *Tree) continueControl(pos Pos, line int) Node {
+	label := t.branchLabel("__continue")
+	if t.loopDepth == 0 {
+		t.errorf("{{__continue}} outside {{range}} or {{__while}}")
+	}
+	return t.newContinue(pos, line, label)
+}
+
+// branchLabel parses the optional label of a break or continue and checks that it is defined
+func (t *Tree) branchLabel(context string) string {
+	token := t.nextNonSpace()
+	if token.typ == itemRightDelim {
+		if t.loopDepth == 0 {
+			t.errorf("{{%s}} outside {{range}} or {{__while}}", context)
+		}
+		return ""
+	}
+	if token.typ != itemString && token.typ != itemRawString {
+		t.unexpected(token, context)
+	}
+	label, err := strconv.Unquote(token.val)
+	if err != nil {
+		t.error(err)
+	}
+	t.expect(itemRightDelim, context)
+	for _, l := range t.labels {
+		if l == label {
+			return label
+		}
+	}
+	t.errorf("undefined label %q in %s", label, context)
+	return ""
+}
+
+// Label:
+//
+//	{{__label "name"}} itemList {{end}}
+//
+// Label keyword is past.
+func (t *Tree) labelControl(pos Pos, line int) Node {
+	const context = "__label"
+	token := t.expectOneOf(itemString, itemRawString, context)
+	label, err := strconv.Unquote(token.val)
+	if err != nil {
+		t.error(err)
+	}
+	t.expect(itemRightDelim, context)
+	t.labels = append(t.labels, label)
+	list, next := t.itemList()
+	t.labels = t.labels[:len(t.labels)-1]
+	if next.Type() != nodeEnd {
+		t.errorf("expected end; found %s", next)
+	}
+	return t.newLabel(pos, line, label, list)
+}
+
+// Scope:
+//
+//	{{__scope}} itemList {{end}}
+//
+// Scope keyword is past.
+func (t *Tree) scopeControl(pos Pos, line int) Node {
+	t.expect(itemRightDelim, "__scope")
+	list, next := t.itemList()
+	if next.Type() != nodeEnd {
+		t.errorf("expected end; found %s", next)
+	}
+	return t.newScope(pos, line, list)
+}
+
+// Return:
+//
+//	{{__return}}
+//	{{__return pipeline}}
+//
+// Return keyword is past.
+func (t *Tree) returnControl(pos Pos, line int) Node {
+	if t.peekNonSpace().typ == itemRightDelim {
+		t.nextNonSpace()
+		return t.newReturn(pos, line, nil)
+	}
+	return t.newReturn(pos, line, t.pipeline("__return"))
+}
+
 // With:
 //
 //	{{with pipeline}} itemList {{end}}
@@ -0,0 +1,74 @@
+package pugjs
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"flamingo.me/flamingo/v3/framework/flamingo"
+)
+
+type (
+	// PartialDataTemplateEngine renders partials together with the data the partial templates set with
+	// setPartialData, for the "data" object of the partials JSON response
+	PartialDataTemplateEngine interface {
+		flamingo.PartialTemplateEngine
+		RenderPartialsWithData(ctx context.Context, templateName string, data interface{}, partials []string) (map[string]io.Reader, map[string]interface{}, error)
+	}
+
+	// PartialData collects the data of one partials render, it is safe for concurrent use
+	PartialData struct {
+		mu     sync.Mutex
+		values map[string]interface{}
+	}
+
+	partialDataKey struct{}
+)
+
+var _ PartialDataTemplateEngine = new(Engine)
+
+// WithPartialData adds a new partial data collector to the context, unless it has one already
+func WithPartialData(ctx context.Context) (context.Context, *PartialData) {
+	if data := PartialDataFromContext(ctx); data != nil {
+		return ctx, data
+	}
+	data := &PartialData{values: make(map[string]interface{})}
+	return context.WithValue(ctx, partialDataKey{}, data), data
+}
+
+// PartialDataFromContext returns the partial data collector of the context, or nil
+func PartialDataFromContext(ctx context.Context) *PartialData {
+	if ctx == nil {
+		return nil
+	}
+	data, _ := ctx.Value(partialDataKey{}).(*PartialData)
+	return data
+}
+
+// Set the value of the key
+func (d *PartialData) Set(key string, value interface{}) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.values[key] = value
+}
+
+// Values returns a copy of the collected values
+func (d *PartialData) Values() map[string]interface{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	values := make(map[string]interface{}, len(d.values))
+	for k, v := range d.values {
+		values[k] = v
+	}
+	return values
+}
+
+// RenderPartialsWithData renders the partials like RenderPartials, and returns the data set by setPartialData
+func (e *Engine) RenderPartialsWithData(ctx context.Context, templateName string, data interface{}, partials []string) (map[string]io.Reader, map[string]interface{}, error) {
+	ctx, partialData := WithPartialData(ctx)
+	res, err := e.RenderPartials(ctx, templateName, data, partials)
+	if err != nil {
+		return nil, nil, err
+	}
+	return res, partialData.Values(), nil
+}
@@ -0,0 +1,117 @@
+package pugjs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartialData_Concurrent(t *testing.T) {
+	_, data := WithPartialData(context.Background())
+	done := make(chan struct{})
+	for i := 0; i < 10; i++ {
+		go func(i int) {
+			data.Set(string(rune('a'+i)), i)
+			_ = data.Values()
+			done <- struct{}{}
+		}(i)
+	}
+	for i := 0; i < 10; i++ {
+		<-done
+	}
+	assert.Len(t, data.Values(), 10)
+}
+
+type partialsTestProduct struct {
+	Name  string
+	Price float64
+}
+
+func TestEngine_RenderPartials(t *testing.T) {
+	templates := map[string]string{
+		"product":                  "product.name",
+		"product.partials/name":    "product.name",
+		"product.partial/price":    "product.price",
+		"product.fragments/teaser": "product.name + ' ' + product.price",
+		"product.partials/broken":  "fail()",
+	}
+	data := map[string]interface{}{"product": &partialsTestProduct{Name: "shoe", Price: 9.5}}
+
+	funcs := FuncMap{"fail": func() (string, error) { return "", errors.New("broken") }}
+
+	read := func(partials map[string]io.Reader) map[string]string {
+		result := make(map[string]string, len(partials))
+		for k, r := range partials {
+			b, _ := io.ReadAll(r)
+			result[k] = string(b)
+		}
+		return result
+	}
+
+	t.Run("partials folders", func(t *testing.T) {
+		e := testEngine(t, funcs, templates)
+		e.applyOptions(WithRateLimit(1), WithPartialsFolder(".fragments"))
+
+		partials, err := e.RenderPartials(context.Background(), "product", data, []string{"name", "price", "teaser"})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"name": "shoe", "price": "9.5", "teaser": "shoe 9.5"}, read(partials))
+	})
+
+	t.Run("failing partials are left out", func(t *testing.T) {
+		e := testEngine(t, funcs, templates)
+
+		partials, err := e.RenderPartials(context.Background(), "product", data, []string{"name", "broken", "unknown"})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"name": "shoe"}, read(partials))
+
+		_, err = e.RenderPartials(context.Background(), "product", data, []string{"broken", "unknown"})
+		assert.Error(t, err, "all partials failed")
+	})
+
+	t.Run("fail fast", func(t *testing.T) {
+		e := testEngine(t, funcs, templates)
+		e.applyOptions(WithPartialsFailFast(true))
+
+		_, err := e.RenderPartials(context.Background(), "product", data, []string{"name", "unknown"})
+		assert.EqualError(t, err, "Partial unknown of template product not found!")
+	})
+
+	t.Run("many partials", func(t *testing.T) {
+		e := testEngine(t, funcs, templates)
+
+		names := make([]string, 20)
+		for i := range names {
+			names[i] = "name"
+		}
+		for i := 0; i < 10; i++ {
+			partials, err := e.RenderPartials(context.Background(), "product", data, append(names, "price"))
+			require.NoError(t, err)
+			assert.Len(t, partials, 2)
+		}
+	})
+	t.Run("partials modify their own data", func(t *testing.T) {
+		cartTemplates := map[string]*Token{"cart": {Type: "Block"}}
+		names := make([]string, 10)
+		for i := range names {
+			names[i] = fmt.Sprintf("p%d", i)
+			cartTemplates["cart.partials/"+names[i]] = &Token{Type: "Block", Nodes: []*Token{
+				codeToken(fmt.Sprintf("cart.lines.push(%d); cart.meta.k = %d", i, i)),
+				codeToken("cart.lines.join(',') + '-' + cart.meta.k"),
+			}}
+		}
+		e := testEngineTokens(t, nil, cartTemplates)
+		cart := map[string]interface{}{"cart": map[string]interface{}{"lines": []int{}, "meta": map[string]int{}}}
+
+		partials, err := e.RenderPartials(context.Background(), "cart", cart, names)
+		require.NoError(t, err)
+		result := read(partials)
+		for i, name := range names {
+			assert.Equal(t, fmt.Sprintf("%d-%d", i, i), result[name])
+		}
+	})
+}
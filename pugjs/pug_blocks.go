@@ -26,6 +26,12 @@ type (
 		Nodes []Node
 	}
 
+	// NamedBlock is a block with a name, e.g. `block content`, which can be rendered on its own
+	NamedBlock struct {
+		Block
+		Name string
+	}
+
 	// Abstract Node Types
 
 	// AttributedNode extends a node with attributes
@@ -130,6 +136,7 @@ type (
 		Buffer     bool  // Buffer if the value of the piece of code is buffered in the template
 		MustEscape bool  // MustEscape if the value must be HTML-escaped before being buffered
 		IsInline   *bool // IsInline whether the node is the result of a string interpolation
+		Line       int   // Line of the code in the pug file, for compile errors
 	}
 
 	// Code Helpers
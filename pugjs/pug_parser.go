@@ -114,6 +114,20 @@ func (p *renderState) TokenToTemplate(name string, t *Token) (*Template, string,
 		wr.WriteString("\n" + p.mixin[b])
 	}
 
+	if len(p.errors) > 0 {
+		return nil, "", errors.Errorf("%s:%s", name, p.errors[0])
+	}
+
+	for _, f := range p.functionorder {
+		wr.WriteString("\n" + p.functions[f])
+	}
+
+	for call := range p.functioncalls {
+		if _, ok := p.functions[call]; !ok {
+			return nil, "", fmt.Errorf("function %q called but not defined", call)
+		}
+	}
+
 	template, err := template.Parse(wr.String())
 
 	if err != nil {
@@ -125,7 +139,7 @@ func (p *renderState) TokenToTemplate(name string, t *Token) (*Template, string,
 	}
 
 	for call := range p.mixincalls {
-		if _, ok := p.mixin[call]; !ok {
+		if _, ok := p.mixin[call]; !ok && call != cacheMixin {
 			if p.debug {
 				return nil, "", fmt.Errorf("mixin %q called but not found", call)
 			}
@@ -133,6 +147,8 @@ func (p *renderState) TokenToTemplate(name string, t *Token) (*Template, string,
 		}
 	}
 
+	template.namedBlocks = p.namedblocks
+
 	return template, wr.String(), nil
 }
 
@@ -218,6 +234,7 @@ func (p *renderState) buildNode(t *Token) (res Node) {
 		code.Block = Block{Nodes: p.build(t.Block)}
 		code.IsInline = t.IsInline
 		code.MustEscape = t.MustEscape
+		code.Line = t.Line
 		return code
 
 	case "Conditional":
@@ -251,7 +268,13 @@ func (p *renderState) buildNode(t *Token) (res Node) {
 
 		return doctype
 
-	case "NamedBlock", "Block":
+	case "NamedBlock":
+		if t.Name == "" {
+			return &Block{Nodes: p.build(t)}
+		}
+		return &NamedBlock{Block: Block{Nodes: p.build(t)}, Name: t.Name}
+
+	case "Block":
 		return &Block{Nodes: p.build(t)}
 
 	case "Comment":
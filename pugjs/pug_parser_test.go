@@ -16,6 +16,7 @@ func TestRenderState_buildNode(t *testing.T) {
 		{&Token{Type: "Each"}, &Each{}},
 		{&Token{Type: "While"}, &While{}},
 		{&Token{Type: "NamedBlock"}, &Block{}},
+		{&Token{Type: "NamedBlock", Name: "content"}, &NamedBlock{Name: "content"}},
 		{&Token{Type: "Block"}, &Block{}},
 		{&Token{Type: "Case"}, &Case{}},
 		{&Token{Type: "When"}, &When{}},
@@ -0,0 +1,157 @@
+package pugjs
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"flamingo.me/flamingo/v3/framework/flamingo"
+	"flamingo.me/flamingo/v3/framework/web"
+	"github.com/pkg/errors"
+)
+
+type (
+	// MetadataTemplateEngine renders templates together with the metadata the templates set with setStatus,
+	// setHeader and addCacheTag
+	MetadataTemplateEngine interface {
+		flamingo.TemplateEngine
+		RenderWithMetadata(ctx context.Context, templateName string, data interface{}) (io.Reader, *RenderMetadata, error)
+	}
+
+	// RenderMetadata collects the status code, headers and cache tags of one render, it is safe for concurrent use
+	RenderMetadata struct {
+		mu        sync.Mutex
+		status    int
+		header    http.Header
+		cacheTags []string
+	}
+
+	renderMetadataKey struct{}
+)
+
+// CacheTagHeader is the response header of the cache tags, space separated, as understood by Fastly and Varnish
+const CacheTagHeader = "Surrogate-Key"
+
+var _ MetadataTemplateEngine = new(Engine)
+
+// WithRenderMetadata adds a new render metadata collector to the context, unless it has one already
+func WithRenderMetadata(ctx context.Context) (context.Context, *RenderMetadata) {
+	if metadata := RenderMetadataFromContext(ctx); metadata != nil {
+		return ctx, metadata
+	}
+	metadata := newRenderMetadata()
+	return context.WithValue(ctx, renderMetadataKey{}, metadata), metadata
+}
+
+func newRenderMetadata() *RenderMetadata {
+	return &RenderMetadata{header: make(http.Header)}
+}
+
+// RenderMetadataFromContext returns the render metadata collector of the context, or nil
+func RenderMetadataFromContext(ctx context.Context) *RenderMetadata {
+	if ctx == nil {
+		return nil
+	}
+	metadata, _ := ctx.Value(renderMetadataKey{}).(*RenderMetadata)
+	return metadata
+}
+
+// SetStatus sets the status code of the response
+func (m *RenderMetadata) SetStatus(status int) error {
+	if status < 100 || status > 599 {
+		return errors.Errorf("invalid status code %d", status)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.status = status
+	return nil
+}
+
+// Status returns the status code set by the templates, or zero
+func (m *RenderMetadata) Status() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.status
+}
+
+// SetHeader sets the response header, replacing values set before
+func (m *RenderMetadata) SetHeader(key, value string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.header.Set(key, value)
+}
+
+// Header returns a copy of the headers set by the templates
+func (m *RenderMetadata) Header() http.Header {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.header.Clone()
+}
+
+// AddCacheTag adds cache tags, e.g. the ids of the products shown, to purge cached pages by
+func (m *RenderMetadata) AddCacheTag(tags ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, tag := range tags {
+		if tag = strings.TrimSpace(tag); tag != "" && !m.hasCacheTag(tag) {
+			m.cacheTags = append(m.cacheTags, tag)
+		}
+	}
+}
+
+func (m *RenderMetadata) hasCacheTag(tag string) bool {
+	for _, t := range m.cacheTags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// CacheTags returns the cache tags in the order they were added
+func (m *RenderMetadata) CacheTags() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.cacheTags...)
+}
+
+// merge adds the status code, headers and cache tags set in other, e.g. by a cached fragment
+func (m *RenderMetadata) merge(other *RenderMetadata) {
+	if status := other.Status(); status != 0 {
+		_ = m.SetStatus(status)
+	}
+	for key, values := range other.Header() {
+		m.mu.Lock()
+		m.header[key] = values
+		m.mu.Unlock()
+	}
+	m.AddCacheTag(other.CacheTags()...)
+}
+
+// Apply sets the status code, the headers and the cache tag header of the response
+func (m *RenderMetadata) Apply(response *web.Response) {
+	if status := m.Status(); status != 0 {
+		response.Status = uint(status)
+	}
+	if response.Header == nil {
+		response.Header = make(http.Header)
+	}
+	for key, values := range m.Header() {
+		response.Header[key] = values
+	}
+	if tags := m.CacheTags(); len(tags) > 0 {
+		response.Header.Set(CacheTagHeader, strings.Join(tags, " "))
+	}
+}
+
+// RenderWithMetadata renders the template like Render, and returns the metadata set by the template
+func (e *Engine) RenderWithMetadata(ctx context.Context, templateName string, data interface{}) (io.Reader, *RenderMetadata, error) {
+	ctx, metadata := WithRenderMetadata(ctx)
+	res, err := e.Render(ctx, templateName, data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return res, metadata, nil
+}
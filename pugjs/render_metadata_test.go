@@ -0,0 +1,58 @@
+package pugjs
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"flamingo.me/flamingo/v3/framework/web"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_RenderWithMetadata(t *testing.T) {
+	metadataFunc := func(set func(m *RenderMetadata, args []string)) func(ctx context.Context) interface{} {
+		return func(ctx context.Context) interface{} {
+			return func(args ...string) string {
+				if metadata := RenderMetadataFromContext(ctx); metadata != nil {
+					set(metadata, args)
+				}
+				return ""
+			}
+		}
+	}
+	e := testEngine(t, FuncMap{
+		"notFound":    metadataFunc(func(m *RenderMetadata, _ []string) { _ = m.SetStatus(http.StatusNotFound) }),
+		"setHeader":   metadataFunc(func(m *RenderMetadata, args []string) { m.SetHeader(args[0], args[1]) }),
+		"addCacheTag": metadataFunc(func(m *RenderMetadata, args []string) { m.AddCacheTag(args...) }),
+	}, map[string]string{
+		"listing": "notFound() + setHeader('Cache-Control', 'max-age=60') + addCacheTag('category-1', 'product-2') + addCacheTag('category-1') + 'empty'",
+	})
+
+	body, metadata, err := e.RenderWithMetadata(context.Background(), "listing", nil)
+	require.NoError(t, err)
+	b, _ := io.ReadAll(body)
+	assert.Equal(t, "empty", string(b))
+	assert.Equal(t, http.StatusNotFound, metadata.Status())
+	assert.Equal(t, []string{"category-1", "product-2"}, metadata.CacheTags())
+
+	response := &web.Response{Status: http.StatusOK, Header: http.Header{"Vary": []string{"Accept"}}}
+	metadata.Apply(response)
+	assert.Equal(t, uint(http.StatusNotFound), response.Status)
+	assert.Equal(t, http.Header{
+		"Vary":          []string{"Accept"},
+		"Cache-Control": []string{"max-age=60"},
+		CacheTagHeader:  []string{"category-1 product-2"},
+	}, response.Header)
+
+	_, err = e.Render(context.Background(), "listing", nil)
+	require.NoError(t, err, "metadata is ignored outside of metadata renders")
+}
+
+func TestRenderMetadata_SetStatus(t *testing.T) {
+	_, metadata := WithRenderMetadata(context.Background())
+	assert.EqualError(t, metadata.SetStatus(42), "invalid status code 42")
+	assert.EqualError(t, metadata.SetStatus(600), "invalid status code 600")
+	assert.Equal(t, 0, metadata.Status())
+}
@@ -1,9 +1,12 @@
 package pugjs
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
+	"math"
 	"reflect"
 	"strconv"
 	"strings"
@@ -31,36 +34,52 @@ var funcmap = FuncMap{
 	"__op__slash": runtimeQuo,
 	"__op__mod":   runtimeRem,
 	"__op__eql":   runtimeEql,
-
-	"__op__lt":  runtimeLss,
-	"__op__gt":  func(x, y interface{}) bool { return !runtimeLss(x, y) && !runtimeEql(x, y) },
-	"__op__gte": func(x, y interface{}) bool { return !runtimeLss(x, y) },
-	"__op__lte": func(x, y interface{}) bool { return runtimeLss(x, y) || runtimeEql(x, y) },
-	"__op__neq": func(x, y interface{}) bool { return !runtimeEql(x, y) },
-
-	"__tryindex": func(obj, key interface{}) interface{} {
+	"__op__seql":  runtimeStrictEql,
+
+	"__op__lt":   runtimeLss,
+	"__op__gt":   runtimeGtr,
+	"__op__gte":  runtimeGeq,
+	"__op__lte":  runtimeLeq,
+	"__op__neq":  func(x, y interface{}) bool { return !runtimeEql(x, y) },
+	"__op__sneq": func(x, y interface{}) bool { return !runtimeStrictEql(x, y) },
+
+	"__op__typeof":     runtimeTypeof,
+	"__op__void":       func(interface{}) Object { return Undefined{} },
+	"__op__in":         runtimeIn,
+	"__op__instanceof": runtimeInstanceof,
+
+	"__op__template":     runtimeTemplate,
+	"__op__new_Date":     runtimeNewDate,
+	"__op__array_spread": runtimeArraySpread,
+	"__op__map_spread":   runtimeMapSpread,
+	"__op__destructure":  runtimeDestructure,
+	"__op__default":      runtimeDefault,
+	"__op__array_rest":   runtimeArrayRest,
+	"__op__object_rest":  runtimeObjectRest,
+
+	"__tryindex": func(ctx context.Context, obj, key interface{}) interface{} {
 		arr, ok := obj.(*Array)
 		idx, ok2 := key.(int)
 		if ok && ok2 {
-			if len(arr.items) <= idx {
-				return Nil{}
+			if idx < 0 || len(arr.items) <= idx {
+				return Undefined{}
 			}
 			return arr.items[idx]
 		}
 
 		if obj, ok := obj.(Object); ok {
-			return obj.Member(convert(key).String())
+			return objectTypesFromContext(ctx).member(obj, convert(key).String())
 		}
 
 		vo, _ := indirect(reflect.ValueOf(obj))
 		k := int(reflect.ValueOf(key).Int())
 		if !vo.IsValid() {
-			return nil
+			return Undefined{}
 		}
-		if vo.Len() > k {
+		if k >= 0 && vo.Len() > k {
 			return vo.Index(k).Interface()
 		}
-		return nil
+		return Undefined{}
 	},
 
 	"__Range": func(args ...Number) Object {
@@ -89,9 +108,19 @@ var funcmap = FuncMap{
 			return o.items
 		case String:
 			return string(o)
+		case *Iterator:
+			// ranged lazily
+			return o
 		}
 		return nil
 	},
+	"__range_helper_values__": func(ctx context.Context, o Object) (interface{}, error) {
+		if it, ok := o.(*Iterator); ok {
+			// ranged lazily
+			return it, nil
+		}
+		return iterableValues(ctx, o)
+	},
 	"__range_helper_keys__": func(o Object) []interface{} {
 		var res []interface{}
 		switch o := o.(type) {
@@ -153,7 +182,8 @@ var funcmap = FuncMap{
 		if v, ok := v.(bool); ok {
 			return []Attribute{{Name: k, BoolVal: &v}}
 		}
-		if _, ok := v.(Nil); ok {
+		switch v.(type) {
+		case Nil, Undefined:
 			b := false
 			return []Attribute{{Name: k, BoolVal: &b}}
 		}
@@ -257,7 +287,7 @@ var funcmap = FuncMap{
 		return
 	},
 	"__if": func(test, left, right interface{}) interface{} {
-		if t, ok := IsTrue(test); ok && t {
+		if toBoolean(test) {
 			return left
 		}
 		return right
@@ -266,6 +296,24 @@ var funcmap = FuncMap{
 	"__freeze": func(name string) Nil {
 		return Nil{}
 	},
+
+	// __pug__block_begin and __pug__block_end are evaluated by the template state, which captures the named block
+	"__pug__block_begin": func(name string) Nil {
+		return Nil{}
+	},
+	"__pug__block_end": func(name string) Nil {
+		return Nil{}
+	},
+
+	// __pug__call is evaluated by the template state, which calls the template function
+	"__pug__call": func(name string, args ...interface{}) Object {
+		return Undefined{}
+	},
+
+	// __pug__apply is evaluated by the template state, which calls the function with an array of arguments
+	"__pug__apply": func(args ...interface{}) Object {
+		return Undefined{}
+	},
 }
 
 func runtimeAdd(l, r interface{}) Object {
@@ -334,6 +382,13 @@ func runtimeSub(i ...interface{}) interface{} {
 		y = 0
 	}
 	x, y = y, x
+	// dates subtract as milliseconds
+	if d, ok := x.(*Date); ok {
+		x = float64(d.ValueOf())
+	}
+	if d, ok := y.(*Date); ok {
+		y = float64(d.ValueOf())
+	}
 	vx, vy := reflect.ValueOf(x), reflect.ValueOf(y)
 	switch vx.Kind() {
 	case reflect.Int, reflect.Int32, reflect.Int64, reflect.Int16, reflect.Int8:
@@ -435,123 +490,438 @@ func runtimeRem(x, y interface{}) interface{} {
 	return "<nil>"
 }
 
-func runtimeEql(x, y interface{}) bool {
-	_, xnil := x.(Nil)
-	_, ynil := y.(Nil)
-	if xnil && ynil {
-		return true
+// toBoolean implements the ECMAScript ToBoolean abstract operation.
+// Objects are always true, regardless of their content.
+func toBoolean(x interface{}) bool {
+	switch x := convert(x).(type) {
+	case Nil, Undefined:
+		return false
+	case Bool:
+		return bool(x)
+	case Number:
+		return x != 0 && !math.IsNaN(float64(x))
+	case String:
+		return x != ""
+	}
+	return true
+}
+
+// toPrimitive implements the ECMAScript ToPrimitive abstract operation.
+// Arrays are joined by comma, all other objects use their string representation.
+func toPrimitive(x Object) Object {
+	switch x := x.(type) {
+	case Nil, Undefined, Bool, Number, String:
+		return x
+	case *Array:
+		tmp := make([]string, len(x.items))
+		for i, v := range x.items {
+			tmp[i] = toPrimitive(v).String()
+		}
+		return String(strings.Join(tmp, ","))
 	}
-	if xnil {
-		x = 0
+	return String(x.String())
+}
+
+// ToNumber converts a value to a number, the same way as JavaScript's Number(value)
+func ToNumber(x interface{}) float64 {
+	return toNumber(convert(x))
+}
+
+// toNumericPrimitive is toPrimitive with hint number, which only differs for dates
+func toNumericPrimitive(x Object) Object {
+	if d, ok := x.(*Date); ok {
+		return d.ValueOf()
 	}
-	if ynil {
-		y = 0
+	return toPrimitive(x)
+}
+
+// toNumber implements the ECMAScript ToNumber abstract operation.
+func toNumber(x Object) float64 {
+	switch x := toNumericPrimitive(x).(type) {
+	case Nil:
+		return 0
+	case Undefined:
+		return math.NaN()
+	case Bool:
+		if x {
+			return 1
+		}
+		return 0
+	case Number:
+		return float64(x)
+	case String:
+		return stringToNumber(string(x))
 	}
-	vx, vy := reflect.ValueOf(x), reflect.ValueOf(y)
-	switch vx.Kind() {
-	case reflect.Int, reflect.Int32, reflect.Int64, reflect.Int16, reflect.Int8:
-		{
-			switch vy.Kind() {
-			case reflect.Int, reflect.Int32, reflect.Int64, reflect.Int16, reflect.Int8:
-				return vx.Int() == vy.Int()
-			case reflect.Float32, reflect.Float64:
-				return float64(vx.Int()) == vy.Float()
-			case reflect.String:
-				return fmt.Sprintf("%d", vx.Int()) == vy.String()
+	return math.NaN()
+}
+
+// stringToNumber parses a string as a StringNumericLiteral, everything not parseable is NaN
+func stringToNumber(s string) float64 {
+	s = strings.TrimSpace(s)
+	switch s {
+	case "":
+		return 0
+	case "Infinity", "+Infinity":
+		return math.Inf(1)
+	case "-Infinity":
+		return math.Inf(-1)
+	}
+
+	if len(s) > 2 && s[0] == '0' {
+		base := 0
+		switch s[1] {
+		case 'x', 'X':
+			base = 16
+		case 'o', 'O':
+			base = 8
+		case 'b', 'B':
+			base = 2
+		}
+		if base != 0 {
+			n, err := strconv.ParseUint(s[2:], base, 64)
+			if err != nil {
+				return math.NaN()
 			}
+			return float64(n)
 		}
-	case reflect.Float32, reflect.Float64:
-		{
-			switch vy.Kind() {
-			case reflect.Int, reflect.Int32, reflect.Int64, reflect.Int16, reflect.Int8:
-				return vx.Float() == float64(vy.Int())
-			case reflect.Float32, reflect.Float64:
-				return vx.Float() == vy.Float()
-			case reflect.String:
-				return fmt.Sprintf("%f", vx.Float()) == vy.String()
-			}
+	}
+
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789.eE+-", c) {
+			return math.NaN()
 		}
-	case reflect.String:
-		{
-			switch vy.Kind() {
-			case reflect.Int, reflect.Int32, reflect.Int64, reflect.Int16, reflect.Int8:
-				return vx.String() == fmt.Sprintf("%d", vy.Int())
-			case reflect.Float32, reflect.Float64:
-				return vx.String() == fmt.Sprintf("%f", vy.Float())
-			case reflect.String:
-				return vx.String() == fmt.Sprintf("%s", vy.String())
-			}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		return math.NaN()
+	}
+	return f
+}
+
+func isPrimitive(x Object) bool {
+	switch x.(type) {
+	case Nil, Undefined, Bool, Number, String:
+		return true
+	}
+	return false
+}
+
+// strictEquals implements the ECMAScript Strict Equality Comparison (===)
+func strictEquals(x, y Object) bool {
+	switch x := x.(type) {
+	case Nil:
+		_, ok := y.(Nil)
+		return ok
+	case Undefined:
+		_, ok := y.(Undefined)
+		return ok
+	case Bool:
+		y, ok := y.(Bool)
+		return ok && x == y
+	case Number:
+		// NaN is never equal and +0 equals -0, which is exactly the float comparison
+		y, ok := y.(Number)
+		return ok && x == y
+	case String:
+		y, ok := y.(String)
+		return ok && x == y
+	}
+	if isPrimitive(y) {
+		return false
+	}
+	return sameObject(x, y)
+}
+
+// sameObject checks for object identity
+func sameObject(x, y Object) bool {
+	switch x := x.(type) {
+	case *Array:
+		y, ok := y.(*Array)
+		return ok && x == y
+	case *Map:
+		y, ok := y.(*Map)
+		return ok && x == y
+	case *Func:
+		y, ok := y.(*Func)
+		return ok && (x == y || x.fnc.Kind() == reflect.Func && y.fnc.Kind() == reflect.Func && x.fnc.Pointer() == y.fnc.Pointer())
+	}
+	return x == y
+}
+
+// looseEquals implements the ECMAScript Abstract Equality Comparison (==)
+func looseEquals(x, y Object) bool {
+	if reflect.TypeOf(x) == reflect.TypeOf(y) {
+		return strictEquals(x, y)
+	}
+
+	// null and undefined only equal each other
+	switch xv := x.(type) {
+	case Nil:
+		_, ok := y.(Undefined)
+		return ok
+	case Undefined:
+		_, ok := y.(Nil)
+		return ok
+	case Bool:
+		return looseEquals(Number(toNumber(xv)), y)
+	case Number:
+		switch y.(type) {
+		case String:
+			return float64(xv) == toNumber(y)
+		case Bool:
+			return looseEquals(x, Number(toNumber(y)))
+		case Nil, Undefined:
+			return false
 		}
-	case reflect.Bool:
-		{
-			switch vy.Kind() {
-			case reflect.Int, reflect.Int32, reflect.Int64, reflect.Int16, reflect.Int8:
-				return vx.Bool() && vy.Int() != 0
-			case reflect.Bool:
-				return vx.Bool() == vy.Bool()
-			}
+		return looseEquals(x, toPrimitive(y))
+	case String:
+		switch y.(type) {
+		case Number:
+			return toNumber(x) == float64(y.(Number))
+		case Bool:
+			return looseEquals(x, Number(toNumber(y)))
+		case Nil, Undefined:
+			return false
 		}
+		return looseEquals(x, toPrimitive(y))
 	}
 
-	l, lok := x.(Object)
-	r, rok := y.(Object)
-	if lok && rok {
-		return l.String() == r.String()
+	// x is an object
+	switch y.(type) {
+	case Nil, Undefined:
+		return false
+	case Bool:
+		return looseEquals(x, Number(toNumber(y)))
+	case Number, String:
+		return looseEquals(toPrimitive(x), y)
 	}
+	return sameObject(x, y)
+}
 
-	return false
+// lessThan implements the ECMAScript Abstract Relational Comparison (x < y).
+// The second return value is false if the result is undefined, e.g. because of NaN.
+func lessThan(x, y Object) (less bool, defined bool) {
+	px, py := toNumericPrimitive(x), toNumericPrimitive(y)
+	if sx, ok := px.(String); ok {
+		if sy, ok := py.(String); ok {
+			return sx < sy, true
+		}
+	}
+
+	nx, ny := toNumber(px), toNumber(py)
+	if math.IsNaN(nx) || math.IsNaN(ny) {
+		return false, false
+	}
+	return nx < ny, true
+}
+
+func runtimeEql(x, y interface{}) bool {
+	return looseEquals(convert(x), convert(y))
+}
+
+func runtimeStrictEql(x, y interface{}) bool {
+	return strictEquals(convert(x), convert(y))
 }
 
 func runtimeLss(x, y interface{}) bool {
-	_, xnil := x.(Nil)
-	_, ynil := y.(Nil)
-	if xnil && ynil {
-		return false
+	less, _ := lessThan(convert(x), convert(y))
+	return less
+}
+
+func runtimeGtr(x, y interface{}) bool {
+	greater, _ := lessThan(convert(y), convert(x))
+	return greater
+}
+
+func runtimeLeq(x, y interface{}) bool {
+	greater, defined := lessThan(convert(y), convert(x))
+	return defined && !greater
+}
+
+func runtimeGeq(x, y interface{}) bool {
+	less, defined := lessThan(convert(x), convert(y))
+	return defined && !less
+}
+
+// runtimeTypeof returns the javascript type name of x
+func runtimeTypeof(x interface{}) string {
+	switch convert(x).(type) {
+	case Undefined:
+		return "undefined"
+	case Bool:
+		return "boolean"
+	case Number:
+		return "number"
+	case String:
+		return "string"
+	case *Func:
+		return "function"
 	}
-	if xnil {
-		x = 0
+	// null is an object as well
+	return "object"
+}
+
+// runtimeIn checks if key is a member of a Map or a valid index of an Array
+func runtimeIn(key, obj interface{}) (bool, error) {
+	k := convert(key)
+	switch o := convert(obj).(type) {
+	case *Map:
+		if o.HasMember(k.String()) {
+			return true, nil
+		}
+		_, undefined := o.Member(k.String()).(Undefined)
+		return !undefined, nil
+
+	case *Array:
+		if k.String() == "length" {
+			return true, nil
+		}
+		n := toNumber(k)
+		return n == math.Trunc(n) && n >= 0 && int(n) < len(o.items), nil
 	}
-	if ynil {
-		y = 0
+	return false, fmt.Errorf("cannot use 'in' operator to search for %q in %s", k.String(), runtimeTypeof(obj))
+}
+
+// instanceofChecks maps constructor names to checks for the instanceof operator
+var instanceofChecks = map[string]func(Object) bool{
+	"Object": func(o Object) bool { return !isPrimitive(o) },
+	"Array": func(o Object) bool {
+		_, ok := o.(*Array)
+		return ok
+	},
+	"Function": func(o Object) bool {
+		_, ok := o.(*Func)
+		return ok
+	},
+	"Date": func(o Object) bool {
+		_, ok := o.(*Date)
+		return ok
+	},
+	// primitives are never instances of their wrapper types
+	"String":  func(Object) bool { return false },
+	"Number":  func(Object) bool { return false },
+	"Boolean": func(Object) bool { return false },
+}
+
+// runtimeInstanceof checks if x is an instance of the named constructor
+func runtimeInstanceof(x interface{}, constructor string) (bool, error) {
+	check, ok := instanceofChecks[constructor]
+	if !ok {
+		return false, fmt.Errorf("right-hand side of 'instanceof' is not a known constructor: %s", constructor)
 	}
-	vx, vy := reflect.ValueOf(x), reflect.ValueOf(y)
-	switch vx.Kind() {
-	case reflect.Int, reflect.Int32, reflect.Int64, reflect.Int16, reflect.Int8:
-		{
-			switch vy.Kind() {
-			case reflect.Int, reflect.Int32, reflect.Int64, reflect.Int16, reflect.Int8:
-				return vx.Int() < vy.Int()
-			case reflect.Float32, reflect.Float64:
-				return float64(vx.Int()) < vy.Float()
-			case reflect.String:
-				return fmt.Sprintf("%d", vx.Int()) < vy.String()
-			}
+	return check(convert(x)), nil
+}
+
+// runtimeTemplate concatenates the strings and values of a template literal
+func runtimeTemplate(parts ...interface{}) string {
+	var res strings.Builder
+	for _, part := range parts {
+		res.WriteString(convert(part).String())
+	}
+	return res.String()
+}
+
+// iterableValues returns the values of an iterable, which are the items of an array or the characters of a string,
+// iterators are consumed until the context is done
+func iterableValues(ctx context.Context, o Object) ([]Object, error) {
+	switch o := o.(type) {
+	case *Array:
+		return o.items, nil
+	case String:
+		var res []Object
+		for _, c := range string(o) {
+			res = append(res, String(c))
 		}
-	case reflect.Float32, reflect.Float64:
-		{
-			switch vy.Kind() {
-			case reflect.Int, reflect.Int32, reflect.Int64, reflect.Int16, reflect.Int8:
-				return vx.Float() < float64(vy.Int())
-			case reflect.Float32, reflect.Float64:
-				return vx.Float() < vy.Float()
-			case reflect.String:
-				return fmt.Sprintf("%f", vx.Float()) < vy.String()
-			}
+		return res, nil
+	case *Iterator:
+		return o.values(ctx)
+	}
+	return nil, fmt.Errorf("%s is not iterable", runtimeTypeof(o))
+}
+
+// runtimeArraySpread concatenates the values of the iterables, for [a, ...b]
+func runtimeArraySpread(ctx context.Context, parts ...interface{}) (Object, error) {
+	res := &Array{items: []Object{}}
+	for _, part := range parts {
+		values, err := iterableValues(ctx, convert(part))
+		if err != nil {
+			return nil, err
 		}
-	case reflect.String:
-		{
-			switch vy.Kind() {
-			case reflect.Int, reflect.Int32, reflect.Int64, reflect.Int16, reflect.Int8:
-				return vx.String() < fmt.Sprintf("%d", vy.Int())
-			case reflect.Float32, reflect.Float64:
-				return vx.String() < fmt.Sprintf("%f", vy.Float())
-			case reflect.String:
-				return vx.String() < vy.String()
+		res.items = append(res.items, values...)
+	}
+	return res, nil
+}
+
+// runtimeMapSpread merges the members of the objects into a new map, later keys win, for {...a, b: 1}
+// null and undefined are skipped, arrays and strings are spread with their indices as keys
+func runtimeMapSpread(parts ...interface{}) Object {
+	res := &Map{items: make(map[string]Object), order: []string{}}
+	for _, part := range parts {
+		switch o := convert(part).(type) {
+		case *Map:
+			for _, k := range o.Keys() {
+				res.set(k, o.items[k])
+			}
+		case *Array, String:
+			values, _ := iterableValues(context.Background(), o)
+			for i, v := range values {
+				res.set(strconv.Itoa(i), v)
 			}
 		}
 	}
+	return res
+}
 
-	return false
+// runtimeDestructure checks that x can be destructured
+func runtimeDestructure(x interface{}) (Object, error) {
+	o := convert(x)
+	switch o.(type) {
+	case Nil:
+		return nil, errors.New("cannot destructure null")
+	case Undefined:
+		return nil, errors.New("cannot destructure undefined")
+	}
+	return o, nil
+}
+
+// runtimeDefault returns the default value d if x is undefined.
+// Unlike javascript the default value is always evaluated.
+func runtimeDefault(x, d interface{}) Object {
+	if _, ok := convert(x).(Undefined); ok {
+		return convert(d)
+	}
+	return convert(x)
+}
+
+// runtimeArrayRest returns the values of an iterable starting at index n, for [a, ...rest]
+func runtimeArrayRest(ctx context.Context, x interface{}, n int) (Object, error) {
+	values, err := iterableValues(ctx, convert(x))
+	if err != nil {
+		return nil, err
+	}
+	if n > len(values) {
+		n = len(values)
+	}
+	return &Array{items: append([]Object{}, values[n:]...)}, nil
+}
+
+// runtimeObjectRest returns a new map with the members of x, except the given keys, for {a, ...rest}
+func runtimeObjectRest(x interface{}, keys ...string) Object {
+	res := &Map{items: make(map[string]Object), order: []string{}}
+	m, ok := convert(x).(*Map)
+	if !ok {
+		return res
+	}
+outer:
+	for _, k := range m.Keys() {
+		for _, key := range keys {
+			if k == key {
+				continue outer
+			}
+		}
+		res.set(k, m.items[k])
+	}
+	return res
 }
 
 func runtimeJSON(x interface{}) (res template.JS, err error) {
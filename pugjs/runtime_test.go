@@ -0,0 +1,315 @@
+package pugjs
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuntimeEquality(t *testing.T) {
+	arr := convert([]int{1, 2})
+	obj := convert(map[string]interface{}{"a": 1})
+
+	tests := []struct {
+		x, y          interface{}
+		loose, strict bool
+	}{
+		{4, "4", true, false},
+		{4, 4, true, true},
+		{0, "", true, false},
+		{0, "0", true, false},
+		{"0", false, true, false},
+		{"", false, true, false},
+		{1, true, true, false},
+		{2, true, false, false},
+		{"1", true, true, false},
+		{nil, nil, true, true},
+		{nil, 0, false, false},
+		{nil, false, false, false},
+		{nil, "", false, false},
+		{math.NaN(), math.NaN(), false, false},
+		{"a", "a", true, true},
+		{"a", "b", false, false},
+		{"1e3", 1000, true, false},
+		{"0x10", 16, true, false},
+		{" 12 ", 12, true, false},
+		{"Infinity", math.Inf(1), true, false},
+		{"abc", 0, false, false},
+		{arr, "1,2", true, false},
+		{arr, arr, true, true},
+		{arr, convert([]int{1, 2}), false, false},
+		{obj, obj, true, true},
+		{obj, "[object Object]", false, false},
+		{convert([]int{}), "", true, false},
+		{convert([]int{}), 0, true, false},
+		{convert([]int{0}), false, true, false},
+	}
+
+	for _, tt := range tests {
+		name := fmt.Sprintf("%v == %v", tt.x, tt.y)
+		assert.Equal(t, tt.loose, runtimeEql(tt.x, tt.y), name)
+		assert.Equal(t, tt.loose, runtimeEql(tt.y, tt.x), name+" (commutative)")
+		assert.Equal(t, tt.strict, runtimeStrictEql(tt.x, tt.y), name+" (strict)")
+		assert.Equal(t, tt.strict, runtimeStrictEql(tt.y, tt.x), name+" (strict, commutative)")
+	}
+}
+
+func TestRuntimeRelational(t *testing.T) {
+	tests := []struct {
+		x, y             interface{}
+		lt, gt, lte, gte bool
+	}{
+		{1, 2, true, false, true, false},
+		{2, 2, false, false, true, true},
+		{"10", "9", true, false, true, false},
+		{"10", 9, false, true, false, true},
+		{"a", "b", true, false, true, false},
+		{nil, 0, false, false, true, true},
+		{nil, 1, true, false, true, false},
+		{true, 0, false, true, false, true},
+		{math.NaN(), 1, false, false, false, false},
+		{"abc", 1, false, false, false, false},
+		{convert([]int{5}), 4, false, true, false, true},
+	}
+
+	for _, tt := range tests {
+		name := fmt.Sprintf("%v ? %v", tt.x, tt.y)
+		assert.Equal(t, tt.lt, runtimeLss(tt.x, tt.y), name+" <")
+		assert.Equal(t, tt.gt, runtimeGtr(tt.x, tt.y), name+" >")
+		assert.Equal(t, tt.lte, runtimeLeq(tt.x, tt.y), name+" <=")
+		assert.Equal(t, tt.gte, runtimeGeq(tt.x, tt.y), name+" >=")
+	}
+}
+
+func TestToBoolean(t *testing.T) {
+	tests := []struct {
+		x        interface{}
+		expected bool
+	}{
+		{nil, false},
+		{false, false},
+		{true, true},
+		{0, false},
+		{-0.0, false},
+		{math.NaN(), false},
+		{1, true},
+		{"", false},
+		{"0", true},
+		{"false", true},
+		{[]int{}, true},
+		{map[string]string{}, true},
+		{struct{ Foo string }{}, true},
+		{Nil{}, false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, toBoolean(tt.x), fmt.Sprintf("%#v", tt.x))
+		truth, ok := IsTrue(convert(tt.x))
+		assert.True(t, ok)
+		assert.Equal(t, tt.expected, truth, fmt.Sprintf("IsTrue(%#v)", tt.x))
+	}
+}
+
+func TestToNumber(t *testing.T) {
+	tests := []struct {
+		x        interface{}
+		expected float64
+	}{
+		{nil, 0},
+		{true, 1},
+		{false, 0},
+		{"", 0},
+		{"  ", 0},
+		{"42", 42},
+		{"-1.5", -1.5},
+		{"1e3", 1000},
+		{"1e400", math.Inf(1)},
+		{"0x1f", 31},
+		{"0b101", 5},
+		{"0o17", 15},
+		{"-Infinity", math.Inf(-1)},
+		{[]int{}, 0},
+		{[]int{7}, 7},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, toNumber(convert(tt.x)), fmt.Sprintf("%#v", tt.x))
+	}
+
+	for _, x := range []interface{}{"abc", "1a", "0x", []int{1, 2}, map[string]int{}} {
+		assert.True(t, math.IsNaN(toNumber(convert(x))), fmt.Sprintf("%#v", x))
+	}
+}
+
+func TestRuntimeUndefined(t *testing.T) {
+	assert.True(t, runtimeEql(Undefined{}, nil))
+	assert.True(t, runtimeEql(Undefined{}, Undefined{}))
+	assert.False(t, runtimeStrictEql(Undefined{}, nil))
+	assert.True(t, runtimeStrictEql(Undefined{}, Undefined{}))
+	assert.False(t, runtimeEql(Undefined{}, 0))
+	assert.False(t, runtimeEql(Undefined{}, ""))
+	assert.False(t, runtimeEql(Undefined{}, false))
+	assert.False(t, toBoolean(Undefined{}))
+	assert.True(t, math.IsNaN(toNumber(Undefined{})))
+	assert.False(t, runtimeGeq(Undefined{}, 0))
+
+	tryindex := funcmap["__tryindex"].(func(ctx context.Context, obj, key interface{}) interface{})
+	assert.Equal(t, Undefined{}, tryindex(context.Background(), convert([]int{1}), 3))
+	assert.Equal(t, Undefined{}, tryindex(context.Background(), convert(map[string]int{"a": 1}), "b"))
+	assert.Equal(t, Undefined{}, tryindex(context.Background(), nil, 0))
+
+	attr := funcmap["__attr"].(func(k string, v interface{}, e bool) []Attribute)
+	assert.False(t, *attr("href", Undefined{}, true)[0].BoolVal)
+}
+
+func TestRuntimeTypeof(t *testing.T) {
+	tests := []struct {
+		x        interface{}
+		expected string
+	}{
+		{Undefined{}, "undefined"},
+		{nil, "object"},
+		{true, "boolean"},
+		{1.5, "number"},
+		{"a", "string"},
+		{func() {}, "function"},
+		{[]int{}, "object"},
+		{map[string]int{}, "object"},
+		{struct{}{}, "object"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, runtimeTypeof(tt.x), fmt.Sprintf("%#v", tt.x))
+	}
+}
+
+func TestRuntimeIn(t *testing.T) {
+	obj := convert(map[string]interface{}{"a": 1, "b": nil})
+	arr := convert([]int{1, 2})
+
+	tests := []struct {
+		key      interface{}
+		obj      interface{}
+		expected bool
+	}{
+		{"a", obj, true},
+		{"b", obj, true},
+		{"c", obj, false},
+		{0, arr, true},
+		{"1", arr, true},
+		{2, arr, false},
+		{-1, arr, false},
+		{1.5, arr, false},
+		{"length", arr, true},
+	}
+
+	for _, tt := range tests {
+		res, err := runtimeIn(tt.key, tt.obj)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expected, res, fmt.Sprintf("%v in %v", tt.key, tt.obj))
+	}
+
+	_, err := runtimeIn("a", "abc")
+	assert.Error(t, err)
+}
+
+func TestRuntimeInstanceof(t *testing.T) {
+	tests := []struct {
+		x           interface{}
+		constructor string
+		expected    bool
+	}{
+		{[]int{}, "Array", true},
+		{[]int{}, "Object", true},
+		{map[string]int{}, "Object", true},
+		{map[string]int{}, "Array", false},
+		{func() {}, "Function", true},
+		{"a", "String", false},
+		{"a", "Object", false},
+		{nil, "Object", false},
+		{time.Now(), "Date", true},
+		{time.Now(), "Object", true},
+		{"2020-01-01", "Date", false},
+	}
+
+	for _, tt := range tests {
+		res, err := runtimeInstanceof(tt.x, tt.constructor)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expected, res, fmt.Sprintf("%#v instanceof %s", tt.x, tt.constructor))
+	}
+
+	_, err := runtimeInstanceof(1, "Unknown")
+	assert.Error(t, err)
+}
+
+func TestRuntimeNewDate(t *testing.T) {
+	assert.Equal(t, "2020-02-03T00:00:00.000Z", mustISO(t, runtimeNewDate("2020-02-03")))
+	assert.Equal(t, "2020-02-03T10:20:30.400Z", mustISO(t, runtimeNewDate("2020-02-03T11:20:30.4+01:00")))
+	assert.Equal(t, "1970-01-01T00:00:01.000Z", mustISO(t, runtimeNewDate(1000)))
+	assert.Equal(t, "Invalid Date", runtimeNewDate("foo").String())
+
+	d := runtimeNewDate(2020, 1, 30, 10)
+	assert.Equal(t, Number(2), d.GetMonth(), "overflowing days roll over")
+	assert.Equal(t, Number(1), d.GetDate())
+	assert.Equal(t, Number(10), d.GetHours())
+	assert.Equal(t, Number(1999), runtimeNewDate(99, 0).GetFullYear())
+
+	c := runtimeNewDate(d)
+	assert.Equal(t, d.GetTime(), c.GetTime())
+	assert.NotSame(t, d, c)
+
+	assert.Equal(t, float64(1000), runtimeSub(runtimeNewDate(2000), runtimeNewDate(1000)))
+	assert.True(t, runtimeLss(runtimeNewDate(1000), runtimeNewDate(2000)))
+}
+
+func mustISO(t *testing.T, d *Date) string {
+	t.Helper()
+	s, err := d.ToISOString()
+	assert.NoError(t, err)
+	return s
+}
+
+func TestRuntimeSpread(t *testing.T) {
+	arr, err := runtimeArraySpread(context.Background(), convert([]int{1}), String("ab"), convert([]int{}))
+	assert.NoError(t, err)
+	assert.Equal(t, "1 a b", arr.String())
+
+	_, err = runtimeArraySpread(context.Background(), convert(map[string]int{}))
+	assert.Error(t, err)
+
+	arr, err = runtimeArraySpread(context.Background(), convert(slices.Values([]int{1, 2})))
+	assert.NoError(t, err)
+	assert.Equal(t, "1 2", arr.String(), "iterators are spread")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = runtimeArraySpread(ctx, convert(make(chan int)))
+	assert.ErrorIs(t, err, context.Canceled)
+
+	m := runtimeMapSpread(convert(map[string]int{"a": 1}), Nil{}, Undefined{}, convert([]string{"x"}), convert(map[string]int{"a": 2})).(*Map)
+	assert.Equal(t, []string{"a", "0"}, m.Keys())
+	assert.Equal(t, Number(2), m.Member("a"))
+
+	rest := runtimeObjectRest(runtimeMapSpread(convert(map[string]int{"a": 1}), convert(map[string]int{"b": 2})), "a").(*Map)
+	assert.Equal(t, []string{"b"}, rest.Keys())
+
+	items, err := runtimeArrayRest(context.Background(), convert([]int{1, 2, 3}), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "2 3", items.String())
+	items, err = runtimeArrayRest(context.Background(), convert([]int{1}), 3)
+	assert.NoError(t, err)
+	assert.Equal(t, "", items.String())
+
+	assert.Equal(t, String("d"), runtimeDefault(Undefined{}, "d"))
+	assert.Equal(t, Nil{}, runtimeDefault(nil, "d"))
+
+	_, err = runtimeDestructure(nil)
+	assert.EqualError(t, err, "cannot destructure null")
+	_, err = runtimeDestructure(Undefined{})
+	assert.EqualError(t, err, "cannot destructure undefined")
+}
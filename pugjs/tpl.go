@@ -13,6 +13,7 @@ import (
 
 // common holds the information shared by related templates.
 type common struct {
+	root string               // Name of the template the set was created for, e.g. the page.
 	tmpl map[string]*Template // Map from name to defined templates.
 	// We use two maps, one for parsing and one for execution.
 	// This separation makes the API cleaner since it doesn'e
@@ -31,6 +32,8 @@ type Template struct {
 	*common
 	leftDelim  string
 	rightDelim string
+	// namedBlocks are the names of the named blocks of a compiled page
+	namedBlocks map[string]struct{}
 }
 
 // New allocates a new, undefined template with the given name.
@@ -39,6 +42,7 @@ func New(name string) *Template {
 		name: name,
 	}
 	t.init()
+	t.root = name
 	return t
 }
 
@@ -223,3 +227,9 @@ func (t *Template) associate(new *Template, tree *parse.Tree) (bool, error) {
 	t.tmpl[new.name] = new
 	return true, nil
 }
+
+// HasNamedBlock checks if the compiled page has a named block with the name
+func (t *Template) HasNamedBlock(name string) bool {
+	_, ok := t.namedBlocks[name]
+	return ok
+}
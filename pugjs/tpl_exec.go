@@ -11,7 +11,6 @@ import (
 	"io"
 	"reflect"
 	"sort"
-	"strconv"
 	"strings"
 	"unicode"
 	"unicode/utf8"
@@ -33,12 +32,29 @@ type state struct {
 	tmpl        *Template
 	wr          io.Writer
 	node        parse.Node // current node, for errors
-	vars        []variable // push-down stack of variable values.
+	vars        []variable // push-down stack of variable values, block scoped.
+	fnvars      []variable // function scoped variables of the current template, declared by var.
+	parent      *state     // the scope a template function closes over, nil outside of functions.
+	visible     int        // the height of the parent's variable stack outside of its blocks.
+	scopes      int        // the number of blocks being walked, which declare variables on the stack.
+	top         int        // the height of the variable stack outside of blocks.
 	depth       int        // the height of the stack of executing templates.
 	globals     []variable
 	boundBlocks []*boundBlock
 	ctx         reflect.Value
 	trace       bool
+	conv        *converter     // converts values for this render
+	types       *objectTypes   // prototype methods and field names of the engine
+	blocks      *blockCapture  // captures the output of requested named blocks
+	cache       *fragmentCache // stores the output of cache blocks
+	writers     []io.Writer    // writers outside of the named blocks being executed
+	argument    argument       // the function argument being evaluated, for errors
+}
+
+// argument identifies a function argument by the function name and the position
+type argument struct {
+	name  string
+	index int
 }
 
 type boundBlock struct {
@@ -52,41 +68,76 @@ type variable struct {
 	value reflect.Value
 }
 
-// push pushes a new variable on the stack.
+// push declares a new block scoped variable on the stack.
 func (s *state) push(name string, value reflect.Value) {
 	s.vars = append(s.vars, variable{name, value})
 }
 
-// mark returns the length of the variable stack.
+// mark enters a block and returns the length of the variable stack.
 func (s *state) mark() int {
+	if s.scopes == 0 {
+		s.top = len(s.vars)
+	}
+	s.scopes++
 	return len(s.vars)
 }
 
-// pop pops the variable stack up to the mark.
+// pop leaves the block and pops the variable stack up to the mark.
 func (s *state) pop(mark int) {
-	// log.Println("popping", s.vars[mark:])
-	// s.vars = s.vars[0:mark]
+	s.scopes--
+	s.vars = s.vars[0:mark]
 }
 
-// varValue returns the value of the named variable.
-func (s *state) varValue(name string) reflect.Value {
-	for i := s.mark() - 1; i >= 0; i-- {
+// outside returns the height of the variable stack outside of blocks.
+func (s *state) outside() int {
+	if s.scopes == 0 {
+		return len(s.vars)
+	}
+	return s.top
+}
+
+// lookup returns the nearest variable with the given name, block scoped variables shadow function scoped ones,
+// and variables of template functions shadow the variables of the scope they close over.
+func (s *state) lookup(name string) *variable {
+	return s.find(name, len(s.vars))
+}
+
+// find looks the variable up in the lowest height variables of the stack, the function scope and the closure.
+// Like in javascript a template function sees the variables declared outside of blocks, not the ones of its caller.
+func (s *state) find(name string, height int) *variable {
+	for i := height - 1; i >= 0; i-- {
 		if s.vars[i].name == name {
-			return s.vars[i].value
+			return &s.vars[i]
+		}
+	}
+	for i := len(s.fnvars) - 1; i >= 0; i-- {
+		if s.fnvars[i].name == name {
+			return &s.fnvars[i]
 		}
 	}
+	if s.parent != nil {
+		return s.parent.find(name, s.visible)
+	}
+	return nil
+}
+
+// varValue returns the value of the named variable.
+func (s *state) varValue(name string) reflect.Value {
+	if v := s.lookup(name); v != nil {
+		return v.value
+	}
 	// s.errorf("undefined variable: %s", name)
 	return zero
 }
 
+// setVarValue assigns to the nearest variable with the given name,
+// and declares it in the function scope if there is none.
 func (s *state) setVarValue(name string, value reflect.Value) {
-	for i := s.mark() - 1; i >= 0; i-- {
-		if s.vars[i].name == name {
-			s.vars[i].value = value
-			return
-		}
+	if v := s.lookup(name); v != nil {
+		v.value = value
+		return
 	}
-	s.push(name, value)
+	s.fnvars = append(s.fnvars, variable{name, value})
 }
 
 var zero reflect.Value
@@ -118,6 +169,10 @@ func (e ExecError) Error() string {
 	return e.Err.Error()
 }
 
+func (e ExecError) Unwrap() error {
+	return e.Err
+}
+
 // errorf records an ExecError and terminates processing.
 func (s *state) errorf(format string, args ...interface{}) {
 	name := doublePercent(s.tmpl.Name())
@@ -202,12 +257,23 @@ func (t *Template) execute(ctx context.Context, wr io.Writer, data interface{},
 		value = d.ValueOf()
 	}
 
+	options, _ := RenderOptionsFromContext(ctx)
+	types := objectTypesFromContext(ctx)
+	conv := newConverter(options, types)
+	if d, ok := data.(*Map); ok && d.conv != nil {
+		conv = d.conv
+	}
+
 	state := &state{
-		tmpl:  t,
-		wr:    wr,
-		vars:  []variable{{"$", value}},
-		ctx:   reflect.ValueOf(ctx),
-		trace: trace,
+		tmpl:   t,
+		wr:     wr,
+		vars:   []variable{{"$", value}},
+		ctx:    reflect.ValueOf(ctx),
+		trace:  trace,
+		conv:   conv,
+		types:  types,
+		blocks: blockCaptureFromContext(ctx),
+		cache:  fragmentCacheFromContext(ctx),
 	}
 	if t.Tree == nil || t.Root == nil {
 		state.errorf("%q is an incomplete or empty template", t.Name())
@@ -234,7 +300,7 @@ func (t *Template) execute(ctx context.Context, wr io.Writer, data interface{},
 		}
 	}
 
-	state.globals = append(state.globals, variable{`$global`, reflect.ValueOf(Object(&Map{items: make(map[string]Object, 10)}))})
+	state.globals = append(state.globals, variable{`$global`, reflect.ValueOf(conv.globalMap(ctx))})
 
 	for _, v := range state.globals {
 		state.vars = append(state.vars, v)
@@ -265,7 +331,7 @@ func (s *state) walk(dot reflect.Value, node parse.Node) {
 			s.walk(dot, node)
 		}
 	case *parse.RangeNode:
-		s.walkRange(dot, node)
+		s.walkRange(dot, node, "")
 	case *parse.TemplateNode:
 		s.walkTemplate(dot, node)
 	case *parse.TextNode:
@@ -276,6 +342,21 @@ func (s *state) walk(dot reflect.Value, node parse.Node) {
 		s.walkIfOrWith(parse.NodeWith, dot, node.Pipe, node.List, node.ElseList)
 	case *parse.TryNode:
 		s.walkTry(dot, node)
+	case *parse.WhileNode:
+		s.walkWhile(dot, node, "")
+	case *parse.BreakNode:
+		panic(loopControl{brk: true, label: node.Label})
+	case *parse.ContinueNode:
+		panic(loopControl{label: node.Label})
+	case *parse.LabelNode:
+		s.walkLabel(dot, node)
+	case *parse.ScopeNode:
+		s.walkScope(dot, node.List, "")
+	case *parse.ReturnNode:
+		if node.Pipe == nil {
+			panic(returnValue{reflect.ValueOf(Undefined{})})
+		}
+		panic(returnValue{s.evalPipeline(dot, node.Pipe)})
 	default:
 		s.errorf("unknown node: %s", node)
 	}
@@ -284,7 +365,7 @@ func (s *state) walk(dot reflect.Value, node parse.Node) {
 // walkIfOrWith walks an 'if' or 'with' node. The two control structures
 // are identical in behavior except that 'with' sets dot.
 func (s *state) walkIfOrWith(typ parse.NodeType, dot reflect.Value, pipe *parse.PipeNode, list, elseList *parse.ListNode) {
-	// defer s.pop(s.mark())
+	defer s.pop(s.mark())
 	val := s.evalPipeline(dot, pipe)
 	truth, ok := isTrue(val)
 	if !ok {
@@ -313,8 +394,10 @@ func isTrue(val reflect.Value) (truth, ok bool) {
 		// Something like var x interface{}, never set. It's a form of nil.
 		return false, true
 	}
-	if o, ok := val.Interface().(truer); ok {
-		return o.True(), true
+	if val.CanInterface() {
+		if o, ok := val.Interface().(Object); ok {
+			return toBoolean(o), true
+		}
 	}
 	switch val.Kind() {
 	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
@@ -339,16 +422,46 @@ func isTrue(val reflect.Value) (truth, ok bool) {
 	return truth, true
 }
 
-func (s *state) walkRange(dot reflect.Value, r *parse.RangeNode) {
+// loopControl is raised by break and continue to unwind the stack up to the loop (or label) in charge
+type loopControl struct {
+	brk   bool
+	label string
+}
+
+// returnValue is raised by return to unwind the stack up to the called template function
+type returnValue struct {
+	value reflect.Value
+}
+
+// maxLoopIterations limits loops which depend on a condition
+const maxLoopIterations = 10000
+
+// walkLoopBody walks one iteration of a loop, the result reports if the loop should be stopped.
+// Labelled breaks are left to the surrounding label, which also stops the loop.
+func (s *state) walkLoopBody(dot reflect.Value, list *parse.ListNode, label string) (brk bool) {
+	mark := s.mark()
+	defer func() {
+		s.pop(mark)
+		if r := recover(); r != nil {
+			lc, ok := r.(loopControl)
+			if !ok || (lc.label != "" && (lc.brk || lc.label != label)) {
+				panic(r)
+			}
+			brk = lc.brk
+		}
+	}()
+	s.walk(dot, list)
+	return false
+}
+
+func (s *state) walkRange(dot reflect.Value, r *parse.RangeNode, label string) {
 	s.at(r)
 	defer s.pop(s.mark())
-	for _, v := range r.Pipe.Decl {
-		s.push(v.Ident[0], zero)
-	}
+	// the declared variables are pushed by the pipeline, and set on every iteration
 	val := s.evalPipeline(dot, r.Pipe)
 	// mark top of stack before any variables in the body are pushed.
 	// mark := s.mark()
-	oneIteration := func(index, elem reflect.Value) {
+	oneIteration := func(index, elem reflect.Value) bool {
 		// Set next var (lexically the first if there are two) to the index.
 		if len(r.Pipe.Decl) > 1 {
 			s.setVarValue(r.Pipe.Decl[0].Ident[0], index)
@@ -356,7 +469,7 @@ func (s *state) walkRange(dot reflect.Value, r *parse.RangeNode) {
 		} else if len(r.Pipe.Decl) > 0 {
 			s.setVarValue(r.Pipe.Decl[0].Ident[0], elem)
 		}
-		s.walk(elem, r.List)
+		return !s.walkLoopBody(elem, r.List, label)
 		// s.pop(mark)
 	}
 
@@ -373,14 +486,31 @@ func (s *state) walkRange(dot reflect.Value, r *parse.RangeNode) {
 				if len(obj.order) > 0 {
 					for _, index := range obj.order {
 						if obj.HasMember(index) {
-							oneIteration(reflect.ValueOf(index), reflect.ValueOf(obj.Member(index)))
+							if !oneIteration(reflect.ValueOf(index), reflect.ValueOf(obj.Member(index))) {
+								break
+							}
 						}
 					}
 
 					return
 				}
 
-			case Nil:
+			case *Iterator:
+				ctx, _ := s.ctx.Interface().(context.Context)
+				iterations := 0
+				err := obj.Iterate(ctx, func(key, value Object) bool {
+					iterations++
+					return oneIteration(reflect.ValueOf(key), reflect.ValueOf(value))
+				})
+				if err != nil {
+					s.errorf("iteration stopped: %w", err)
+				}
+				if iterations > 0 {
+					return
+				}
+				val = reflect.ValueOf(nil)
+
+			case Nil, Undefined:
 				val = reflect.ValueOf(nil)
 			}
 		} else {
@@ -394,7 +524,9 @@ func (s *state) walkRange(dot reflect.Value, r *parse.RangeNode) {
 			break
 		}
 		for i := 0; i < val.Len(); i++ {
-			oneIteration(reflect.ValueOf(i), val.Index(i))
+			if !oneIteration(reflect.ValueOf(i), val.Index(i)) {
+				break
+			}
 		}
 		return
 	case reflect.Map:
@@ -402,7 +534,9 @@ func (s *state) walkRange(dot reflect.Value, r *parse.RangeNode) {
 			break
 		}
 		for _, key := range sortKeys(val.MapKeys()) {
-			oneIteration(key, val.MapIndex(key))
+			if !oneIteration(key, val.MapIndex(key)) {
+				break
+			}
 		}
 		return
 	case reflect.Chan:
@@ -412,10 +546,9 @@ func (s *state) walkRange(dot reflect.Value, r *parse.RangeNode) {
 		i := 0
 		for ; ; i++ {
 			elem, ok := val.Recv()
-			if !ok {
+			if !ok || !oneIteration(reflect.ValueOf(i), elem) {
 				break
 			}
-			oneIteration(reflect.ValueOf(i), elem)
 		}
 		if i == 0 {
 			break
@@ -424,11 +557,13 @@ func (s *state) walkRange(dot reflect.Value, r *parse.RangeNode) {
 	case reflect.Bool:
 		i := 0
 		for val.Bool() {
-			oneIteration(reflect.ValueOf(i), val)
+			if !oneIteration(reflect.ValueOf(i), val) {
+				break
+			}
 			val = s.evalPipeline(dot, r.Pipe)
 			i++
-			if i > 10000 {
-				s.errorf("max iteration of 10000 in while loop")
+			if i > maxLoopIterations {
+				s.errorf("max iteration of %d in while loop", maxLoopIterations)
 			}
 		}
 		return
@@ -442,14 +577,79 @@ func (s *state) walkRange(dot reflect.Value, r *parse.RangeNode) {
 	}
 }
 
+// walkWhile evaluates the condition before every iteration, and runs the step list after it.
+// The step list may break the loop as well.
+func (s *state) walkWhile(dot reflect.Value, w *parse.WhileNode, label string) {
+	s.at(w)
+	defer s.pop(s.mark())
+	for i := 0; ; i++ {
+		if i >= maxLoopIterations {
+			s.at(w)
+			s.errorf("max iteration of %d in while loop", maxLoopIterations)
+		}
+		truth, ok := isTrue(s.evalPipeline(dot, w.Pipe))
+		if !ok || !truth {
+			return
+		}
+		if s.walkLoopBody(dot, w.List, label) {
+			return
+		}
+		if w.Step != nil && s.walkLoopBody(dot, w.Step, label) {
+			return
+		}
+	}
+}
+
+// walkLabel walks the labelled list, and stops on a break with the same label
+func (s *state) walkLabel(dot reflect.Value, l *parse.LabelNode) {
+	s.at(l)
+	defer func() {
+		if r := recover(); r != nil {
+			if lc, ok := r.(loopControl); ok && lc.brk && lc.label == l.Label {
+				return
+			}
+			panic(r)
+		}
+	}()
+
+	s.walkScope(dot, l.List, l.Label)
+}
+
+// walkScope walks a list, and drops the variables declared in it afterwards.
+// Loops directly inside the list, or inside a nested scope, carry the label.
+func (s *state) walkScope(dot reflect.Value, list *parse.ListNode, label string) {
+	defer s.pop(s.mark())
+
+	for _, node := range list.Nodes {
+		switch node := node.(type) {
+		case *parse.RangeNode:
+			s.walkRange(dot, node, label)
+		case *parse.WhileNode:
+			s.walkWhile(dot, node, label)
+		case *parse.ScopeNode:
+			s.walkScope(dot, node.List, label)
+		default:
+			s.walk(dot, node)
+		}
+	}
+}
+
 func (s *state) walkTry(dot reflect.Value, r *parse.TryNode) {
 	s.at(r)
-	defer s.pop(s.mark())
+	mark := s.mark()
+	defer s.pop(mark)
 
 	defer func() {
 		if exception := recover(); exception != nil {
+			// break, continue and return are not exceptions
+			switch exception.(type) {
+			case loopControl, returnValue:
+				panic(exception)
+			}
+			// the catch block does not see the variables of the try block
+			s.vars = s.vars[0:mark]
 			if r.Exception != "" {
-				s.setVarValue(`$`+r.Exception, reflect.ValueOf(exception))
+				s.push(`$`+r.Exception, reflect.ValueOf(exception))
 			}
 			s.walk(dot, r.Catch)
 		}
@@ -458,6 +658,18 @@ func (s *state) walkTry(dot reflect.Value, r *parse.TryNode) {
 	s.walk(dot, r.List)
 }
 
+// unfreeze returns the scope the block was bound to by __freeze, the block is unbound
+func (s *state) unfreeze(name string) (state, bool) {
+	for i := len(s.boundBlocks) - 1; i >= 0; i-- {
+		if s.boundBlocks[i].name == name {
+			scope := *s.boundBlocks[i].scope
+			s.boundBlocks = append(s.boundBlocks[:i], s.boundBlocks[i+1:]...)
+			return scope, true
+		}
+	}
+	return state{}, false
+}
+
 func (s *state) walkTemplate(dot reflect.Value, t *parse.TemplateNode) {
 	s.at(t)
 	name := t.Name
@@ -466,6 +678,9 @@ func (s *state) walkTemplate(dot reflect.Value, t *parse.TemplateNode) {
 	}
 	tmpl := s.tmpl.tmpl[name]
 	if tmpl == nil {
+		if name == "mixin_"+cacheMixin {
+			s.walkCache(s.evalPipeline(dot, t.Pipe))
+		}
 		// s.errorf("template %q not defined", name)
 		return
 	}
@@ -475,21 +690,14 @@ func (s *state) walkTemplate(dot reflect.Value, t *parse.TemplateNode) {
 	// Variables declared by the pipeline persist.
 	dot = s.evalPipeline(dot, t.Pipe)
 
-	var newState state
-	var found bool
-	for i := len(s.boundBlocks) - 1; i >= 0; i-- {
-		if s.boundBlocks[i].name == name {
-			newState = *s.boundBlocks[i].scope
-			s.boundBlocks = append(s.boundBlocks[:i], s.boundBlocks[i+1:]...)
-			found = true
-			break
-		}
-	}
-
+	newState, found := s.unfreeze(name)
 	if !found {
 		newState = *s
 		newState.vars = make([]variable, len(s.globals))
 		copy(newState.vars, s.globals)
+		newState.fnvars = nil
+		newState.parent = nil
+		newState.scopes = 0
 	}
 
 	if s.trace {
@@ -509,6 +717,54 @@ func (s *state) walkTemplate(dot reflect.Value, t *parse.TemplateNode) {
 	newState.walk(dot, tmpl.Root)
 }
 
+// callFunction calls a template function with the arguments as dot, and returns its return value.
+// The function closes over the variables of the calling template, functions called by functions share the
+// scope of the outermost function's caller. The output of the function is discarded.
+func (s *state) callFunction(name string, args []reflect.Value) reflect.Value {
+	tmpl := s.tmpl.tmpl["function_"+name]
+	if tmpl == nil {
+		s.errorf("function %q not defined", name)
+	}
+	if s.depth == maxExecDepth {
+		s.errorf("exceeded maximum template depth (%v)", maxExecDepth)
+	}
+
+	params := make([]Object, len(args))
+	for i, arg := range args {
+		params[i] = convert(arg)
+	}
+
+	newState := *s
+	newState.wr = io.Discard
+	newState.vars = nil
+	newState.fnvars = nil
+	newState.parent, newState.visible = s, s.outside()
+	if s.parent != nil {
+		newState.parent, newState.visible = s.parent, s.visible
+	}
+	newState.scopes = 0
+	newState.depth++
+	newState.tmpl = tmpl
+
+	return reflect.ValueOf(convert(newState.walkFunction(reflect.ValueOf(&Array{items: params}), tmpl.Root)))
+}
+
+// walkFunction walks the body of a template function until it returns
+func (s *state) walkFunction(dot reflect.Value, root *parse.ListNode) (result reflect.Value) {
+	defer func() {
+		if r := recover(); r != nil {
+			ret, ok := r.(returnValue)
+			if !ok {
+				panic(r)
+			}
+			result = ret.value
+		}
+	}()
+
+	s.walk(dot, root)
+	return reflect.ValueOf(Undefined{})
+}
+
 // Eval functions evaluate pipelines, commands, and their elements and extract
 // values from the data structure by examining fields, calling methods, and so on.
 // The printing of those values happens only through walk functions.
@@ -517,6 +773,7 @@ func (s *state) walkTemplate(dot reflect.Value, t *parse.TemplateNode) {
 // pipeline has a variable declaration, the variable will be pushed on the
 // stack. Callers should therefore pop the stack after they are finished
 // executing commands depending on the pipeline value.
+// An assignment sets the nearest existing variable instead, or declares it in the function scope.
 func (s *state) evalPipeline(dot reflect.Value, pipe *parse.PipeNode) (value reflect.Value) {
 	if pipe == nil {
 		return
@@ -530,9 +787,11 @@ func (s *state) evalPipeline(dot reflect.Value, pipe *parse.PipeNode) (value ref
 		}
 	}
 	for _, variable := range pipe.Decl {
-		// s.push(variable.Ident[0], value)
-		// log.Println("setting", variable.Ident[0], value)
-		s.setVarValue(variable.Ident[0], value)
+		if pipe.IsAssign {
+			s.setVarValue(variable.Ident[0], value)
+		} else {
+			s.push(variable.Ident[0], value)
+		}
 	}
 	return value
 }
@@ -629,6 +888,10 @@ func (s *state) evalVariableNode(dot reflect.Value, variable *parse.VariableNode
 	// $x.Member has $x as the first ident, Member as the second. Eval the var, then the fields.
 	s.at(variable)
 	value := s.varValue(variable.Ident[0])
+	if !value.IsValid() {
+		// variables which have never been set are undefined
+		value = reflect.ValueOf(Undefined{})
+	}
 	if len(variable.Ident) == 1 {
 		s.notAFunction(args, final)
 		return value
@@ -669,7 +932,7 @@ func (s *state) evalField(dot reflect.Value, fieldName string, node parse.Node,
 	// log.Println("evalField", "\n\tdot", dot, "\n\tfieldName", fieldName, "\n\tnode", fmt.Sprintf("%#v", node), "\n\targs", args, "\n\tfinal", final, "\n\treceiver", fmt.Sprintf("%#v", receiver))
 
 	if obj, ok := receiver.Interface().(Object); ok {
-		res := reflect.ValueOf(obj.Member(fieldName))
+		res := reflect.ValueOf(s.types.member(obj, fieldName))
 		if fnc, ok := res.Interface().(*Func); ok {
 			return s.evalCall(dot, fnc.fnc, node, fieldName, args, final)
 		}
@@ -687,9 +950,14 @@ func (s *state) evalField(dot reflect.Value, fieldName string, node parse.Node,
 		ptr = ptr.Addr()
 	}
 
+	// plain strings and numbers have the methods of their JavaScript types
 	switch receiver.Kind() {
 	case reflect.String:
-		ptr = reflect.ValueOf(String(receiver.String()))
+		return s.evalField(dot, fieldName, node, args, final, reflect.ValueOf(String(receiver.String())))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return s.evalField(dot, fieldName, node, args, final, reflect.ValueOf(convert(receiver)))
 	}
 
 	if method := ptr.MethodByName(fieldName); method.IsValid() {
@@ -755,8 +1023,20 @@ var (
 	errorType        = reflect.TypeOf((*error)(nil)).Elem()
 	fmtStringerType  = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
 	reflectValueType = reflect.TypeOf((*reflect.Value)(nil)).Elem()
+	objectType       = reflect.TypeOf((*Object)(nil)).Elem()
+	contextType      = reflect.TypeOf((*context.Context)(nil)).Elem()
 )
 
+// contextArgs returns 1 if the render context is passed as first argument, for functions and methods which take
+// a context.Context as first argument. This applies to template funcs as well: templates never pass the context
+// themselves, it is prepended and the remaining parameters are filled from the call arguments.
+func (s *state) contextArgs(typ reflect.Type) int {
+	if typ.NumIn() == 0 || typ.In(0) != contextType || !s.ctx.IsValid() {
+		return 0
+	}
+	return 1
+}
+
 // evalCall executes a function or method call. If it's a method, fun already has the receiver bound, so
 // it looks just like a function call. The arg list, if non-nil, includes (in the manner of the shell), arg[0]
 // as the function itself.
@@ -769,42 +1049,46 @@ func (s *state) evalCall(dot, fun reflect.Value, node parse.Node, name string, a
 		args = args[1:] // Zeroth arg is function name/node; not passed to function.
 	}
 	typ := fun.Type()
-	numIn := len(args)
+	injected := s.contextArgs(typ)
+	numIn := injected + len(args)
 	if final.IsValid() {
 		numIn++
 	}
 	numFixed := len(args)
 	if typ.IsVariadic() {
-		numFixed = typ.NumIn() - 1 // last arg is the variadic one.
-		if numIn < numFixed {
-			s.errorf("wrong number of args for %s: want at least %d got %d", name, typ.NumIn()-1, len(args))
+		numFixed = typ.NumIn() - injected - 1 // last arg is the variadic one.
+		if numIn-injected < numFixed {
+			s.errorf("wrong number of args for %s: want at least %d got %d", name, typ.NumIn()-injected-1, len(args))
 		}
 	} else if numIn < typ.NumIn()-1 || !typ.IsVariadic() && numIn != typ.NumIn() {
-		s.errorf("wrong number of args for %s: want %d got %d", name, typ.NumIn(), len(args))
+		s.errorf("wrong number of args for %s: want %d got %d", name, typ.NumIn()-injected, len(args))
 	}
 	if !goodFunc(typ) {
 		// TODO: This could still be a confusing error; maybe goodFunc should provide info.
 		s.errorf("can'e call method/function %q with %d results", name, typ.NumOut())
 	}
-	// Build the arg list.
+	// Build the arg list, the render context first.
 	argv := make([]reflect.Value, numIn)
+	if injected > 0 {
+		argv[0] = s.ctx
+	}
 	// Args must be evaluated. Fixed args first.
 	i := 0
 	for ; i < numFixed && i < len(args); i++ {
-		argv[i] = s.evalArg(dot, typ.In(i), args[i])
+		argv[injected+i] = s.evalCallArg(dot, name, i, typ.In(injected+i), args[i])
 	}
 	// Now the ... args.
 	if typ.IsVariadic() {
 		argType := typ.In(typ.NumIn() - 1).Elem() // Argument is a slice.
 		for ; i < len(args); i++ {
-			argv[i] = s.evalArg(dot, argType, args[i])
+			argv[injected+i] = s.evalCallArg(dot, name, i, argType, args[i])
 		}
 	}
 	// Add final value if necessary.
 	if final.IsValid() {
 		t := typ.In(typ.NumIn() - 1)
 		if typ.IsVariadic() {
-			if numIn-1 < numFixed {
+			if numIn-injected-1 < numFixed {
 				// The added final argument corresponds to a fixed parameter of the function.
 				// Validate against the type of the actual parameter.
 				t = typ.In(numIn - 1)
@@ -814,7 +1098,7 @@ func (s *state) evalCall(dot, fun reflect.Value, node parse.Node, name string, a
 				t = t.Elem()
 			}
 		}
-		argv[i] = s.validateType(final, t)
+		argv[injected+i] = s.validateArg(name, i, final, t)
 	}
 
 	if name == "__freeze" {
@@ -822,6 +1106,104 @@ func (s *state) evalCall(dot, fun reflect.Value, node parse.Node, name string, a
 		return reflect.ValueOf(Nil{})
 	}
 
+	if name == "__pug__block_begin" {
+		s.beginBlock(argv[0].String())
+		return reflect.ValueOf(Nil{})
+	}
+
+	if name == "__pug__block_end" {
+		s.endBlock()
+		return reflect.ValueOf(Nil{})
+	}
+
+	if name == "__pug__call" {
+		return s.callFunction(argv[0].String(), argv[1:])
+	}
+
+	if name == "__pug__apply" {
+		return s.applyFunction(node, argv)
+	}
+
+	return s.call(fun, node, name, argv)
+}
+
+// applyFunction calls a function with the items of an array as arguments, as needed for spread arguments.
+// The arguments are either the function name and the array, or a receiver, the method name and the array.
+func (s *state) applyFunction(node parse.Node, argv []reflect.Value) reflect.Value {
+	if len(argv) != 2 && len(argv) != 3 {
+		s.errorf("wrong number of args for __pug__apply: want 2 or 3 got %d", len(argv))
+	}
+	arr, ok := convert(argv[len(argv)-1]).(*Array)
+	if !ok {
+		s.errorf("spread arguments must be an array")
+	}
+	args := make([]reflect.Value, len(arr.items))
+	for i, item := range arr.items {
+		args[i] = reflect.ValueOf(item)
+	}
+
+	name := convert(argv[len(argv)-2]).String()
+	if len(argv) == 2 {
+		fun, ok := s.findFunction(name, s.tmpl)
+		if !ok {
+			return s.callFunction(name, args)
+		}
+		return s.callValues(fun, node, name, args)
+	}
+
+	fnc, ok := s.types.member(convert(argv[0]), name).(*Func)
+	if !ok {
+		s.errorf("%s is not a function", name)
+	}
+	return s.callValues(fnc.fnc, node, name, args)
+}
+
+// evalCallArg evaluates the i-th argument of a call of the function name
+func (s *state) evalCallArg(dot reflect.Value, name string, i int, typ reflect.Type, n parse.Node) reflect.Value {
+	outer := s.argument
+	s.argument = argument{name: name, index: i}
+	defer func() { s.argument = outer }()
+	return s.evalArg(dot, typ, n)
+}
+
+// validateArg validates the i-th argument of a call of the function name
+func (s *state) validateArg(name string, i int, value reflect.Value, typ reflect.Type) reflect.Value {
+	outer := s.argument
+	s.argument = argument{name: name, index: i}
+	defer func() { s.argument = outer }()
+	return s.validateType(value, typ)
+}
+
+// callValues calls a function with evaluated arguments, validating them against the parameter types
+func (s *state) callValues(fun reflect.Value, node parse.Node, name string, args []reflect.Value) reflect.Value {
+	typ := fun.Type()
+	injected := s.contextArgs(typ)
+	numFixed := typ.NumIn() - injected
+	if typ.IsVariadic() {
+		numFixed--
+	}
+	if len(args) < numFixed || !typ.IsVariadic() && len(args) != numFixed {
+		s.errorf("wrong number of args for %s: want %d got %d", name, typ.NumIn()-injected, len(args))
+	}
+	if !goodFunc(typ) {
+		s.errorf("can'e call method/function %q with %d results", name, typ.NumOut())
+	}
+	argv := make([]reflect.Value, injected+len(args))
+	if injected > 0 {
+		argv[0] = s.ctx
+	}
+	for i, arg := range args {
+		if i < numFixed {
+			argv[injected+i] = s.validateArg(name, i, arg, typ.In(injected+i))
+		} else {
+			argv[injected+i] = s.validateArg(name, i, arg, typ.In(injected+numFixed).Elem())
+		}
+	}
+	return s.call(fun, node, name, argv)
+}
+
+// call calls the function and converts its result
+func (s *state) call(fun reflect.Value, node parse.Node, name string, argv []reflect.Value) reflect.Value {
 	result := fun.Call(argv)
 	// If we have an error that is not nil, stop execution and return that error to the caller.
 	if len(result) == 2 && !result[1].IsNil() {
@@ -833,7 +1215,7 @@ func (s *state) evalCall(dot, fun reflect.Value, node parse.Node, name string, a
 		v = v.Interface().(reflect.Value)
 	}
 	if v.IsValid() {
-		v = reflect.ValueOf(convert(v))
+		v = reflect.ValueOf(s.conv.convert(v, 0))
 	}
 	return v
 }
@@ -882,47 +1264,14 @@ func (s *state) validateType(value reflect.Value, typ reflect.Type) reflect.Valu
 		case reflect.PtrTo(value.Type()).AssignableTo(typ) && value.CanAddr():
 			value = value.Addr()
 		default:
-			if m, ok := value.Interface().(*Map); ok {
-				if typ.Kind() == reflect.Map {
-					if typ == reflect.TypeOf(map[string]string{}) {
-						return s.validateType(reflect.ValueOf(m.AsStringMap()), typ)
-					} else if typ == reflect.TypeOf(map[string]interface{}{}) {
-						return s.validateType(reflect.ValueOf(m.AsStringIfaceMap()), typ)
-					}
+			coerced, err := s.conv.coerce(s.conv.convert(value, 0), typ)
+			if err != nil {
+				if s.argument.name != "" {
+					s.errorf("wrong type for argument %d of %s: %s", s.argument.index+1, s.argument.name, err)
 				}
-				return s.validateType(reflect.ValueOf(m.o), typ)
-			}
-			if o, ok := value.Interface().(Object); ok {
-				switch typ.Kind() {
-				case reflect.String:
-					return reflect.ValueOf(o.String())
-				case reflect.Float64:
-					if valueNumber, ok := value.Interface().(Number); ok {
-						return reflect.ValueOf(float64(valueNumber))
-					}
-					if valueString, ok := value.Interface().(String); ok {
-						parsedFloat, err := strconv.ParseFloat(string(valueString), 64)
-						if err != nil {
-							return reflect.ValueOf(parsedFloat)
-						}
-					}
-				}
-			}
-			if _, ok := value.Interface().(Nil); ok {
-				return reflect.Zero(typ)
+				s.errorf("wrong type for value; %s", err)
 			}
-			if a, ok := value.Interface().(*Array); ok {
-				return s.validateType(reflect.ValueOf(a.o), typ)
-			}
-			// before failing see if we can make it to a string
-			switch typ.Kind() {
-			case reflect.String:
-				if str, ok := value.Interface().(fmt.Stringer); ok {
-					return reflect.ValueOf(str.String())
-				}
-			}
-
-			s.errorf("wrong type for value; expected %s; got %s", typ, value.Type())
+			return coerced
 		}
 	}
 	return value
@@ -994,6 +1343,25 @@ func (s *state) evalArg(dot reflect.Value, typ reflect.Type, n parse.Node) refle
 	panic("not reached")
 }
 
+// evalLiteral coerces a literal of another type, e.g. the string '2' for an int parameter
+func (s *state) evalLiteral(typ reflect.Type, n parse.Node) (reflect.Value, bool) {
+	var literal Object
+	switch n := n.(type) {
+	case *parse.BoolNode:
+		literal = Bool(n.True)
+	case *parse.NumberNode:
+		if !n.IsFloat {
+			return reflect.Value{}, false
+		}
+		literal = Number(n.Float64)
+	case *parse.StringNode:
+		literal = String(n.Text)
+	default:
+		return reflect.Value{}, false
+	}
+	return s.validateType(reflect.ValueOf(literal), typ), true
+}
+
 func (s *state) evalBool(typ reflect.Type, n parse.Node) reflect.Value {
 	s.at(n)
 	if n, ok := n.(*parse.BoolNode); ok {
@@ -1001,6 +1369,9 @@ func (s *state) evalBool(typ reflect.Type, n parse.Node) reflect.Value {
 		value.SetBool(n.True)
 		return value
 	}
+	if value, ok := s.evalLiteral(typ, n); ok {
+		return value
+	}
 	s.errorf("expected bool; found %s", n)
 	panic("not reached")
 }
@@ -1012,6 +1383,9 @@ func (s *state) evalString(typ reflect.Type, n parse.Node) reflect.Value {
 		value.SetString(n.Text)
 		return value
 	}
+	if value, ok := s.evalLiteral(typ, n); ok {
+		return value
+	}
 	s.errorf("expected string; found %s", n)
 	panic("not reached")
 }
@@ -1023,6 +1397,9 @@ func (s *state) evalInteger(typ reflect.Type, n parse.Node) reflect.Value {
 		value.SetInt(n.Int64)
 		return value
 	}
+	if value, ok := s.evalLiteral(typ, n); ok {
+		return value
+	}
 	s.errorf("expected integer; found %s", n)
 	panic("not reached")
 }
@@ -1034,6 +1411,9 @@ func (s *state) evalUnsignedInteger(typ reflect.Type, n parse.Node) reflect.Valu
 		value.SetUint(n.Uint64)
 		return value
 	}
+	if value, ok := s.evalLiteral(typ, n); ok {
+		return value
+	}
 	s.errorf("expected unsigned integer; found %s", n)
 	panic("not reached")
 }
@@ -1045,6 +1425,9 @@ func (s *state) evalFloat(typ reflect.Type, n parse.Node) reflect.Value {
 		value.SetFloat(n.Float64)
 		return value
 	}
+	if value, ok := s.evalLiteral(typ, n); ok {
+		return value
+	}
 	s.errorf("expected float; found %s", n)
 	panic("not reached")
 }
@@ -1139,6 +1522,11 @@ func printableValue(v reflect.Value) (interface{}, bool) {
 		return "<no value>", true
 	}
 
+	// floats are printed like JavaScript numbers
+	if v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64 {
+		return Number(v.Float()), true
+	}
+
 	if !v.Type().Implements(errorType) && !v.Type().Implements(fmtStringerType) {
 		if v.CanAddr() && (reflect.PtrTo(v.Type()).Implements(errorType) || reflect.PtrTo(v.Type()).Implements(fmtStringerType)) {
 			v = v.Addr()
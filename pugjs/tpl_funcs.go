@@ -121,7 +121,7 @@ func (s *state) findFunction(name string, tmpl *Template) (reflect.Value, bool)
 }
 
 // prepareArg checks if value can be used as an argument of type argType, and
-// converts an invalid value to appropriate zero if possible.
+// converts an invalid value to appropriate zero and objects to the Go type if possible.
 func prepareArg(value reflect.Value, argType reflect.Type) (reflect.Value, error) {
 	if !value.IsValid() {
 		if !canBeNil(argType) {
@@ -135,6 +135,9 @@ func prepareArg(value reflect.Value, argType reflect.Type) (reflect.Value, error
 	if argType == reflect.TypeOf((*Object)(nil)).Elem() {
 		return reflect.ValueOf(convert(value)), nil
 	}
+	if obj, ok := value.Interface().(Object); ok && !value.Type().AssignableTo(argType) {
+		return coerce(obj, argType)
+	}
 	if !value.Type().AssignableTo(argType) {
 		return reflect.Value{}, fmt.Errorf("value has type %s; should be %s", value.Type(), argType)
 	}
@@ -160,6 +163,8 @@ func index(item reflect.Value, indices ...reflect.Value) (reflect.Value, error)
 		v = reflect.ValueOf(string(obj))
 	} else if _, ok := item.Interface().(Nil); ok {
 		return item, nil
+	} else if _, ok := item.Interface().(Undefined); ok {
+		return item, nil
 	}
 
 	for _, i := range indices {
@@ -211,7 +216,7 @@ func index(item reflect.Value, indices ...reflect.Value) (reflect.Value, error)
 			}
 			if x < 0 || x >= int64(v.Len()) {
 				// return reflect.Value{}, fmt.Errorf("index out of range: %d", x)
-				return reflect.ValueOf(Nil{}), nil
+				return reflect.ValueOf(Undefined{}), nil
 			}
 			v = v.Index(int(x))
 		case reflect.Map:
@@ -225,6 +230,8 @@ func index(item reflect.Value, indices ...reflect.Value) (reflect.Value, error)
 			}
 			if x := v.MapIndex(index); x.IsValid() {
 				v = x
+			} else if v.Type().Elem() == objectType {
+				return reflect.ValueOf(Undefined{}), nil
 			} else {
 				v = reflect.Zero(v.Type().Elem())
 			}
@@ -245,8 +252,10 @@ func truth(arg reflect.Value) bool {
 		return false
 	}
 
-	if t, ok := arg.Interface().(truer); ok {
-		return t.True()
+	if arg.CanInterface() {
+		if o, ok := arg.Interface().(Object); ok {
+			return toBoolean(o)
+		}
 	}
 	t, _ := isTrue(indirectInterface(arg))
 	return t
@@ -1,6 +1,9 @@
 package pugjs
 
-import "bytes"
+import (
+	"bytes"
+	"fmt"
+)
 
 // Render renders a Block, and intends every sub-block if necessary
 func (b *Block) Render(s *renderState, wr *bytes.Buffer) error {
@@ -12,3 +15,14 @@ func (b *Block) Render(s *renderState, wr *bytes.Buffer) error {
 	}
 	return nil
 }
+
+// Render renders a NamedBlock between markers, so the block can be rendered on its own
+func (b *NamedBlock) Render(s *renderState, wr *bytes.Buffer) error {
+	s.namedblocks[b.Name] = struct{}{}
+	fmt.Fprintf(wr, `{{ __pug__block_begin %q }}`, b.Name)
+	if err := b.Block.Render(s, wr); err != nil {
+		return err
+	}
+	fmt.Fprintf(wr, `{{ __pug__block_end %q }}`, b.Name)
+	return nil
+}
@@ -43,5 +43,7 @@ func (c *Case) Render(s *renderState, wr *bytes.Buffer) error {
 
 // Render a when node
 func (w *When) Render(s *renderState, wr *bytes.Buffer) error {
+	s.pushScope()
+	defer s.popScope()
 	return w.Block.Render(s, wr)
 }
@@ -2,9 +2,37 @@ package pugjs
 
 import "bytes"
 
+// codeBlockPlaceholder marks the position of the nested pug block in a code statement
+const codeBlockPlaceholder = "__pug_code_block__"
+
 // Render renders a code block
 func (c *Code) Render(p *renderState, wr *bytes.Buffer) error {
 	p.rawmode = !c.MustEscape
-	_, err := wr.WriteString(p.JsExpr(JavaScriptExpression(c.Val), true, true))
+	line := p.line
+	p.line = c.Line
+	defer func() { p.line = line }()
+
+	if len(c.Block.Nodes) == 0 {
+		_, err := wr.WriteString(p.JsExpr(JavaScriptExpression(c.Val), true, true))
+		return err
+	}
+
+	// statements such as `- for (...)` get the nested pug block as their body
+	p.codeblocks = append(p.codeblocks, &c.Block)
+	defer func() { p.codeblocks = p.codeblocks[:len(p.codeblocks)-1] }()
+	_, err := wr.WriteString(p.JsExpr(JavaScriptExpression(c.Val+" {"+codeBlockPlaceholder+"}"), true, true))
 	return err
 }
+
+// renderCodeBlock renders the nested pug block of the current code statement
+func (p *renderState) renderCodeBlock() string {
+	block := p.codeblocks[len(p.codeblocks)-1]
+	rawmode := p.rawmode
+	defer func() { p.rawmode = rawmode }()
+
+	buf := new(bytes.Buffer)
+	if err := block.Render(p, buf); err != nil {
+		panic(err)
+	}
+	return buf.String()
+}
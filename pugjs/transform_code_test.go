@@ -2,9 +2,13 @@ package pugjs
 
 import (
 	"bytes"
+	"context"
+	"fmt"
 	"testing"
 
+	"flamingo.me/flamingo/v3/framework/flamingo"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCode_Render(t *testing.T) {
@@ -14,5 +18,394 @@ func TestCode_Render(t *testing.T) {
 	node.Val = "var foo = 1"
 
 	assert.NoError(t, node.Render(new(renderState), buffer))
-	assert.Equal(t, "{{ $foo := 1 -}}", buffer.String())
+	assert.Equal(t, "{{ $foo = 1 -}}", buffer.String())
+}
+
+func TestCode_RenderInstanceof(t *testing.T) {
+	assert.Equal(t, "truefalse", renderCode(t, codeToken("[] instanceof Array"), codeToken("'' instanceof Object")))
+
+	s := newRenderState("/", false, nil, flamingo.NullLogger{})
+	_, _, err := s.TokenToTemplate("code", &Token{Type: "Block", Nodes: []*Token{{Type: "Code", Val: "x instanceof a.b", Line: 1}}})
+	assert.EqualError(t, err, `code:1:14: right-hand side of 'instanceof' must be a constructor name, got DotExpression`)
+}
+
+func TestCode_RenderUnsupported(t *testing.T) {
+	for code, expected := range map[string]string{
+		"debugger":        "code:1:1: unsupported statement DebuggerStatement",
+		"/a/.test('abc')": "code:1:1: unsupported expression RegExpLiteral",
+		"var x = this":    "code:1:9: unsupported expression ThisExpression",
+	} {
+		s := newRenderState("/", false, nil, flamingo.NullLogger{})
+		_, _, err := s.TokenToTemplate("code", &Token{Type: "Block", Nodes: []*Token{{Type: "Code", Val: code, Line: 1}}})
+		assert.EqualError(t, err, expected, code)
+	}
+}
+
+// renderCode transpiles the code tokens, executes the template and returns the output
+func renderCode(t *testing.T, tokens ...*Token) string {
+	t.Helper()
+
+	return renderCodeContext(t, context.Background(), tokens...)
+}
+
+// renderCodeContext renders the code tokens with the context
+func renderCodeContext(t *testing.T, ctx context.Context, tokens ...*Token) string {
+	t.Helper()
+
+	s := newRenderState("/", false, nil, flamingo.NullLogger{})
+	s.funcs = FuncMap{}
+	tpl, code, err := s.TokenToTemplate("code", &Token{Type: "Block", Nodes: tokens})
+	require.NoError(t, err)
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, tpl.ExecuteTemplate(ctx, buf, "code", convert(nil), false), code)
+	return buf.String()
+}
+
+func codeToken(val string) *Token {
+	inline := true
+	return &Token{Type: "Code", Val: val, MustEscape: true, IsInline: &inline}
+}
+
+// codeTest expects the output of code lines, each line is a code token
+type codeTest struct {
+	name     string
+	code     []string
+	expected string
+}
+
+// runCodeTests renders the code lines of each test in a subtest
+func runCodeTests(t *testing.T, tests []codeTest) {
+	t.Helper()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, renderLines(t, tt.code...))
+		})
+	}
+}
+
+// renderLines renders each line as code token
+func renderLines(t *testing.T, lines ...string) string {
+	t.Helper()
+
+	tokens := make([]*Token, len(lines))
+	for i, line := range lines {
+		tokens[i] = codeToken(line)
+	}
+	return renderCode(t, tokens...)
+}
+
+func TestCode_RenderStatements(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		expected string
+	}{
+		{"for", "for (var i = 0; i < 5; i++) { if (i == 1) continue; if (i == 4) break; x = x + i }", "023"},
+		{"for with sequences", "for (var i = 0, j = 10; i < 3; i++, j -= 2) { x = x + j }", "1086"},
+		{"for without test", "for (var i = 0;; i++) { if (i > 2) break; x = x + i }", "012"},
+		{"for-of array", "for (var v of [1, 2, 3]) { x = x + v }", "123"},
+		{"for-of string", "for (var v of 'abc') { x = x + v + '-' }", "a-b-c-"},
+		{"for-in", "for (var k in {a: 1, b: 2}) { x = x + k }", "ab"},
+		{"while", "var n = 0; while (n < 3) { n += 1; x = x + n }", "123"},
+		{"do-while", "var n = 0; do { n++; x = x + n } while (n < 3)", "123"},
+		{"do-while runs once", "var n = 5; do { n++; x = x + n } while (n < 3)", "6"},
+		{"do-while continue checks the condition", "var n = 0; do { n++; if (n == 2) continue; x = x + n } while (n < 3)", "13"},
+		{
+			"switch",
+			"for (var d of [1, 2, 3, 4, 5]) { switch (d) { case 1: x = x + 'one'; break; case 2: case 3: x = x + 'twothree'; break; case 4: continue; default: x = x + 'def' } x = x + ',' }",
+			"one,twothree,twothree,def,",
+		},
+		{"switch default falls through", "switch ('z') { default: x = x + 'd'; case 'a': x = x + 'a'; break; case 'b': x = x + 'b' }", "da"},
+		{"switch is strict", "switch ('1') { case 1: x = 'loose'; break; case '1': x = 'strict' }", "strict"},
+		{
+			"labelled loops",
+			"outer: for (var i = 0; i < 3; i++) { for (var j = 0; j < 3; j++) { if (j == 1) continue outer; if (i == 2) break outer; x = x + i + j } }",
+			"0010",
+		},
+		{"labelled block", "blk: { x = 'a'; break blk; x = 'b' }", "a"},
+		{"continue inside try", "for (var i = 0; i < 3; i++) { try { if (i == 1) { continue } x = x + i } catch (e) { x = 'err' } }", "02"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, renderCode(t, codeToken("var x = ''"), codeToken(tt.code), codeToken("x")))
+		})
+	}
+
+	t.Run("for-of on objects", func(t *testing.T) {
+		assert.Panics(t, func() { renderCode(t, codeToken("for (var v of {a: 1}) {}")) })
+	})
+}
+
+func TestCode_RenderBlock(t *testing.T) {
+	block := &Token{Type: "Block", Nodes: []*Token{
+		{Type: "Text", Val: "<"},
+		codeToken("i"),
+		{Type: "Code", Val: "if (i == 1)", Block: &Token{Type: "Block", Nodes: []*Token{{Type: "Text", Val: "one"}}}},
+		{Type: "Text", Val: ">"},
+	}}
+
+	assert.Equal(t, "<0><1one><2>", renderCode(t, &Token{Type: "Code", Val: "for (var i = 0; i < 3; i++)", Block: block}))
+}
+
+func TestCode_RenderScoping(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		expected string
+	}{
+		{"let in block", "let y = 'a'; { let y = 'b'; x = x + y } x = x + y", "ba"},
+		{"var in block", "{ var y = 'b' } x = x + y", "b"},
+		{"let in if", "let y = 1; if (true) { let y = 2; var z = y } x = x + y + z", "12"},
+		{"for let", "for (let i = 0; i < 3; i++) { let j = i * 2; x = x + j } x = x + typeof i + typeof j", "024undefinedundefined"},
+		{"for var", "for (var i = 0; i < 3; i++) {} x = x + i", "3"},
+		{
+			"labelled for let",
+			"outer: for (let i = 0; i < 3; i++) { for (let j = 0; j < 3; j++) { if (j == 1) continue outer; if (i == 2) break outer; x = x + i + j } }",
+			"0010",
+		},
+		{"for-of const", "for (const v of [1, 2]) { const w = v + 1; x = x + w } x = x + typeof w", "23undefined"},
+		{"let in switch", "switch (2) { case 2: let q = 'two'; x = q; break } x = x + typeof q", "twoundefined"},
+		{"catch", "try { let t = 1; x = 'a' in 1 } catch (e) { x = typeof t }", "undefined"},
+		{"let without initializer", "let u; x = u === undefined", "true"},
+		{"var without initializer", "var u = 1; var u; x = u", "1"},
+		{"decrement", "let n = 1; n++; n--; n--; x = n", "0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, renderCode(t, codeToken("var x = ''"), codeToken(tt.code), codeToken("x")))
+		})
+	}
+
+	t.Run("pug blocks", func(t *testing.T) {
+		each := &Token{Type: "Each", Val: "v", Obj: "[1, 2]", Block: &Token{Type: "Block", Nodes: []*Token{
+			codeToken("let y = v"),
+			codeToken("var z = v"),
+		}}}
+		assert.Equal(t, "undefined2", renderCode(t, each, codeToken("typeof y"), codeToken("z")))
+
+		cond := &Token{Type: "Conditional", Test: "true", Consequent: &Token{Type: "Block", Nodes: []*Token{
+			codeToken("let y = 1"),
+			codeToken("var z = 2"),
+		}}}
+		assert.Equal(t, "undefined2", renderCode(t, cond, codeToken("typeof y"), codeToken("z")))
+	})
+
+	t.Run("mixins", func(t *testing.T) {
+		definition := &Token{Type: "Mixin", Name: "m", Args: "a", Block: &Token{Type: "Block", Nodes: []*Token{
+			codeToken("var y = a"),
+			codeToken("y"),
+		}}}
+		call := &Token{Type: "Mixin", Name: "m", Args: "2", Call: true}
+		assert.Equal(t, "121", renderCode(t, codeToken("var y = 1"), definition, codeToken("y"), call, codeToken("y")))
+	})
+
+	t.Run("const", func(t *testing.T) {
+		assert.Equal(t, "1", renderCode(t, codeToken("const c = 1"), codeToken("c")))
+		assert.Equal(t, "2", renderCode(t, codeToken("const c = 1"), codeToken("{ let c = 1; c = 2; x = c }"), codeToken("x")))
+
+		compile := func(tokens ...*Token) error {
+			for i, token := range tokens {
+				if token.Line == 0 {
+					token.Line = i + 1
+				}
+			}
+			s := newRenderState("/", false, nil, flamingo.NullLogger{})
+			_, _, err := s.TokenToTemplate("code", &Token{Type: "Block", Nodes: tokens})
+			return err
+		}
+		assert.EqualError(t, compile(codeToken("const c = 1"), codeToken("c = 2")), `code:2:1: assignment to constant variable "c"`)
+		assert.EqualError(t, compile(codeToken("const c = 1"), codeToken("if (true) { c += 2 }")), `code:2:13: assignment to constant variable "c"`)
+		assert.EqualError(t, compile(codeToken("const c = 1"), codeToken("c++")), `code:2:1: assignment to constant variable "c"`)
+		assert.EqualError(t, compile(codeToken("for (const i = 0; i < 3; i++) {}")), `code:1:26: assignment to constant variable "i"`)
+		assert.EqualError(t, compile(codeToken("const c = 1"), &Token{Type: "Code", Val: "c = 3", Line: 7}), `code:7:1: assignment to constant variable "c"`)
+	})
+}
+
+func TestCode_RenderSpreadAndDestructuring(t *testing.T) {
+	runCodeTests(t, []codeTest{
+		{"array spread", []string{"var a = [1, 2]", "[0, ...a, 3, ...'xy'].join(',')"}, "0,1,2,3,x,y"},
+		{"object spread", []string{"var defaults = {a: 'a', b: 'b'}", "var m = {...defaults, b: 'B', c: 'c', ...null}", "var x = ''", "for (var k in m) { x = x + k + m[k] }", "x"}, "aabBcc"},
+		{"call spread", []string{"function f(a, b, c) { return a + b + c }", "var args = ['a', 'b']", "f(...args, 'c')"}, "abc"},
+		{"method spread", []string{"var a = ['a']", "a.push(...['b'])", "a.join('')"}, "ab"},
+		{"object destructuring", []string{"var teaser = {title: 'T', url: '/u', x: 1}", "var {title, url: link, missing = 'm', ...rest} = teaser", "title + link + missing + rest.x"}, "T/um1"},
+		{"array destructuring", []string{"const [a, , c = 'c', ...r] = ['a', 'b', undefined, 'd', 'e']", "a + c + r.join(',')"}, "acd,e"},
+		{"nested destructuring", []string{"let {a: [b, {c}]} = {a: ['b', {c: 'c'}]}", "b + c"}, "bc"},
+		{"string destructuring", []string{"const [a, b = 'b'] = 'x'", "a + b"}, "xb"},
+		{"default only for undefined", []string{"var {a = 'A'} = {a: null}", "typeof a"}, "object"},
+		{"for-of destructuring", []string{"var x = ''", "for (const [k, v] of [['a', 'b'], ['c', 'd']]) { x = x + k + v }", "x + typeof k"}, "abcdundefined"},
+		{"function parameters", []string{"function f({a, b} = {}, ...r) { return a + b + r.join('') }", "f({a: 'a', b: 'b'}, 'c', 'd') + typeof f().length"}, "abcdnumber"},
+	})
+
+	t.Run("mixin parameters", func(t *testing.T) {
+		definition := &Token{Type: "Mixin", Name: "m", Args: "{title, url = '/'}, ...items", Block: &Token{Type: "Block", Nodes: []*Token{
+			codeToken("title + url + items.join(',')"),
+		}}}
+		call := &Token{Type: "Mixin", Name: "m", Args: "{title: 'T'}, ...['a', 'b']", Call: true}
+		assert.Equal(t, "T/a,b", renderCode(t, definition, call))
+	})
+
+	t.Run("destructuring null", func(t *testing.T) {
+		assert.Panics(t, func() { renderCode(t, codeToken("var {a} = null")) })
+	})
+
+	t.Run("destructured const", func(t *testing.T) {
+		s := newRenderState("/", false, nil, flamingo.NullLogger{})
+		_, _, err := s.TokenToTemplate("code", &Token{Type: "Block", Nodes: []*Token{codeToken("const {a} = {a: 1}"), codeToken("a = 2")}})
+		assert.EqualError(t, err, `code: assignment to constant variable "a"`)
+	})
+}
+
+func TestCode_RenderTemplateLiterals(t *testing.T) {
+	runCodeTests(t, []codeTest{
+		{"substitution", []string{"var a = 'A'", "`a ${a} ${1 + 1}`"}, "a A 2"},
+		{"no leading space", []string{"`${'a'}b`"}, "ab"},
+		{"nested braces", []string{"`${ {a: 'b'}.a }`"}, "b"},
+		{"nested template", []string{"`x${`y${'z'}`}`"}, "xyz"},
+		{"escaped substitution", []string{"`\\${a}`"}, "${a}"},
+		{"quoted strings are not interpolated", []string{"'a${1}b' + \"${2}\""}, "a${1}b${2}"},
+		{"multi-line", []string{"`a\nb`"}, "a\nb"},
+		{"escaped output", []string{"`<${'b'}>`"}, "&lt;b&gt;"},
+		{"tagged", []string{"function tag(strs, ...values) { return strs.join('|') + values.join(',') }", "tag`a${1}b${2}c`"}, "a|b|c1,2"},
+	})
+}
+
+func TestCode_RenderDate(t *testing.T) {
+	runCodeTests(t, []codeTest{
+		{"from string", []string{"new Date('2020-02-03T10:00:00Z').toISOString()"}, "2020-02-03T10:00:00.000Z"},
+		{"from components", []string{"var d = new Date(2020, 0, 31)", "`${d.getMonth() + 1}/${d.getDate()}`"}, "1/31"},
+		{"locale", []string{"new Date('2020-02-03').toLocaleDateString('de-DE', {timeZone: 'UTC', day: 'numeric', month: 'long'})"}, "3. Februar"},
+		{"instanceof", []string{"new Date() instanceof Date"}, "true"},
+		{"comparison", []string{"new Date(2020, 0, 1) < new Date(2021, 0, 1)"}, "true"},
+		{"invalid", []string{"new Date('foo')"}, "Invalid Date"},
+	})
+}
+
+func TestCode_RenderNumberMethods(t *testing.T) {
+	runCodeTests(t, []codeTest{
+		{"toFixed", []string{"var price = 12.5", "price.toFixed(2)"}, "12.50"},
+		{"literal", []string{"(1.255).toFixed(1)"}, "1.3"},
+		{"toPrecision", []string{"var x = 123.456", "x.toPrecision(4)"}, "123.5"},
+		{"string literal", []string{"'abc'.length"}, "3"},
+		{"float output", []string{"0.1 + 0.2"}, "0.30000000000000004"},
+		{"large integer output", []string{"var n = 1234567.5 * 2", "n"}, "2469135"},
+		{"exponent output", []string{"1e21 * 1"}, "1e+21"},
+		{"toString radix", []string{"var n = 255", "n.toString(16)"}, "ff"},
+	})
+}
+
+func TestCode_RenderToLocaleString(t *testing.T) {
+	s := newRenderState("/", false, nil, flamingo.NullLogger{})
+	s.funcs = FuncMap{}
+	tpl, code, err := s.TokenToTemplate("code", &Token{Type: "Block", Nodes: []*Token{
+		codeToken("var price = 1234.5"),
+		codeToken("price.toLocaleString()"),
+		codeToken("' '"),
+		codeToken("price.toLocaleString('en-US', {style: 'currency', currency: 'EUR'})"),
+	}})
+	require.NoError(t, err)
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, tpl.ExecuteTemplate(WithLocale(context.Background(), "de-DE"), buf, "code", convert(nil), false), code)
+	assert.Equal(t, "1.234,5 €1,234.50", buf.String())
+}
+
+func TestCode_RenderFunctions(t *testing.T) {
+	runCodeTests(t, []codeTest{
+		{"return value", []string{"function formatPrice(p) { return p.currency + ' ' + p.amount }", "formatPrice({amount: 3, currency: 'EUR'})"}, "EUR 3"},
+		{"recursion", []string{"function fact(n) { if (n <= 1) { return 1 } return n * fact(n - 1) }", "fact(5)"}, "120"},
+		{"hoisting", []string{"twice(2)", "function twice(n) { return n * 2 }"}, "4"},
+		{"missing arguments", []string{"function f(a, b) { return typeof b }", "f(1)"}, "undefined"},
+		{"arguments", []string{"function f() { return arguments.length }", "f(1, 2, 3)"}, "3"},
+		{"no return", []string{"function f() { var x = 1 }", "typeof f()"}, "undefined"},
+		{"return from loop", []string{"function f(arr) { for (var v of arr) { if (v > 1) return v } return 0 }", "f([1, 2, 3])"}, "2"},
+		{"return from try", []string{"function f() { try { return 'a' } catch (e) { return 'b' } }", "f()"}, "a"},
+		{"closure", []string{"var y = 1", "function f() { y = y + 1; return typeof x }", "f() + y"}, "undefined2"},
+		{"closure over let", []string{"let y = 'a'", "function f() { return y }", "if (true) { let y = 'b'; f() }"}, "a"},
+		{"local variables", []string{"var y = 1", "function f() { var y = 2; return y }", "f() + y"}, "3"},
+		{"nested calls", []string{"var y = 'a'", "function g() { return y }", "function f() { var y = 'b'; return g() }", "f()"}, "a"},
+		{"in expressions", []string{"function f(s) { return s + '!' }", "'x ' + f('y') + [f('z')].length"}, "x y!1"},
+		{"redeclaration", []string{"f()", "function f() { return 'a' }", "f()", "function f() { return 'b' }"}, "bb"},
+	})
+
+	t.Run("undefined function", func(t *testing.T) {
+		s := newRenderState("/", false, nil, flamingo.NullLogger{})
+		_, _, err := s.TokenToTemplate("code", &Token{Type: "Block", Nodes: []*Token{codeToken("f(1)")}})
+		assert.EqualError(t, err, `function "f" called but not defined`)
+	})
+
+	t.Run("template functions are not redefined", func(t *testing.T) {
+		s := newRenderState("/", false, nil, flamingo.NullLogger{})
+		s.funcs = FuncMap{"f": func() int { return 1 }}
+		assert.Panics(t, func() {
+			_, _, _ = s.TokenToTemplate("code", &Token{Type: "Block", Nodes: []*Token{codeToken("function f() { return 2 }")}})
+		})
+	})
+}
+
+func TestCode_RenderFunctionsNamedLikeStatements(t *testing.T) {
+	s := newRenderState("/", false, nil, flamingo.NullLogger{})
+	s.funcs = FuncMap{
+		"label": func(v interface{}) string { return fmt.Sprintf("[%v]", v) },
+		"scope": func() string { return "scope" },
+		"step":  func() string { return "step" },
+	}
+	tpl, code, err := s.TokenToTemplate("code", &Token{Type: "Block", Nodes: []*Token{
+		codeToken("var x = ''"),
+		codeToken("for (let i = 0; i < 3; i++) { x = x + label(i) }"),
+		codeToken("x + step() + scope()"),
+	}})
+	require.NoError(t, err)
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, tpl.ExecuteTemplate(context.Background(), buf, "code", convert(nil), false), code)
+	assert.Equal(t, "[0][1][2]stepscope", buf.String())
+}
+
+func TestCode_RenderTypedArguments(t *testing.T) {
+	render := func(code string) (string, error) {
+		s := newRenderState("/", false, nil, flamingo.NullLogger{})
+		s.funcs = FuncMap{
+			"truncate": func(s string, n int) string { return s[:n] },
+			"total": func(items []struct{ Price float64 }) (total float64) {
+				for _, item := range items {
+					total += item.Price
+				}
+				return total
+			},
+		}
+		tpl, _, err := s.TokenToTemplate("code", &Token{Type: "Block", Nodes: []*Token{codeToken(code)}})
+		require.NoError(t, err)
+
+		buf := new(bytes.Buffer)
+		err = func() (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = r.(error)
+				}
+			}()
+			return tpl.ExecuteTemplate(context.Background(), buf, "code", convert(nil), false)
+		}()
+		return buf.String(), err
+	}
+
+	out, err := render("truncate('abcdef', '3')")
+	require.NoError(t, err)
+	assert.Equal(t, "abc", out)
+
+	out, err = render("total([{price: 1.5}, {price: '2'}])")
+	require.NoError(t, err)
+	assert.Equal(t, "3.5", out)
+
+	_, err = render("truncate('abcdef', 'x')")
+	assert.ErrorContains(t, err, `wrong type for argument 2 of truncate: expected int; got "x"`)
+
+	_, err = render("truncate('abcdef', 0 / 0)")
+	assert.ErrorContains(t, err, "wrong type for argument 2 of truncate: expected int; got NaN")
+
+	_, err = render("total([{price: 'x'}])")
+	assert.ErrorContains(t, err, `wrong type for argument 1 of total: item 0: field Price: expected float64; got "x"`)
 }
@@ -14,13 +14,19 @@ func (c *Conditional) Render(p *renderState, wr *bytes.Buffer) error {
 		return errors.New("can not render conditional without consequent")
 	}
 
-	if err := c.Consequent.Render(p, wr); err != nil {
+	p.pushScope()
+	err := c.Consequent.Render(p, wr)
+	p.popScope()
+	if err != nil {
 		return err
 	}
 
 	if c.Alternate != nil {
 		wr.WriteString(`{{ else -}}`)
-		if err := c.Alternate.Render(p, wr); err != nil {
+		p.pushScope()
+		err := c.Alternate.Render(p, wr)
+		p.popScope()
+		if err != nil {
 			return err
 		}
 	}
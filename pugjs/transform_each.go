@@ -12,6 +12,8 @@ func (e *Each) Render(p *renderState, wr *bytes.Buffer) error {
 	} else {
 		fmt.Fprintf(wr, "{{ range $%s := %s -}}", e.Val, p.JsExpr(e.Obj, false, false))
 	}
+	p.pushScope()
+	defer p.popScope()
 	if err := e.Block.Render(p, wr); err != nil {
 		return err
 	}
@@ -2,9 +2,15 @@ package pugjs
 
 import (
 	"bytes"
+	"context"
+	"iter"
+	"reflect"
+	"slices"
 	"testing"
 
+	"flamingo.me/flamingo/v3/framework/flamingo"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestEach_Render(t *testing.T) {
@@ -27,3 +33,107 @@ func TestEach_Render(t *testing.T) {
 
 	buffer.Reset()
 }
+
+func renderEach(ctx context.Context, t *testing.T, data map[string]interface{}, key string) (res string, err error) {
+	t.Helper()
+
+	inline := true
+	s := newRenderState("/", false, nil, flamingo.NullLogger{})
+	s.funcs = FuncMap{}
+	tpl, code, err := s.TokenToTemplate("each", &Token{Type: "Block", Nodes: []*Token{
+		{Type: "Each", Obj: "items", Key: key, Val: "v", Block: &Token{Type: "Block", Nodes: []*Token{
+			{Type: "Code", Val: "`${" + key + "}:${v} `", MustEscape: true, IsInline: &inline},
+		}}},
+	}})
+	require.NoError(t, err, code)
+
+	buf := new(bytes.Buffer)
+	defer func() {
+		// execution errors panic
+		if r := recover(); r != nil {
+			res, err = buf.String(), r.(error)
+		}
+	}()
+	err = tpl.ExecuteTemplate(ctx, buf, "each", convert(data), false)
+	return buf.String(), err
+}
+
+func TestEach_RenderIterators(t *testing.T) {
+	t.Run("channel", func(t *testing.T) {
+		ch := make(chan string, 2)
+		ch <- "a"
+		ch <- "b"
+		close(ch)
+		res, err := renderEach(context.Background(), t, map[string]interface{}{"items": ch}, "i")
+		require.NoError(t, err)
+		assert.Equal(t, "0:a 1:b ", res)
+	})
+
+	t.Run("iter.Seq", func(t *testing.T) {
+		res, err := renderEach(context.Background(), t, map[string]interface{}{"items": slices.Values([]string{"a", "b"})}, "i")
+		require.NoError(t, err)
+		assert.Equal(t, "0:a 1:b ", res)
+	})
+
+	t.Run("iter.Seq2", func(t *testing.T) {
+		seq := func(yield func(string, int) bool) {
+			_ = yield("a", 1) && yield("b", 2)
+		}
+		res, err := renderEach(context.Background(), t, map[string]interface{}{"items": iter.Seq2[string, int](seq)}, "k")
+		require.NoError(t, err)
+		assert.Equal(t, "a:1 b:2 ", res)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		ch := make(chan string)
+		close(ch)
+		res, err := renderEach(context.Background(), t, map[string]interface{}{"items": ch}, "i")
+		require.NoError(t, err)
+		assert.Equal(t, "", res)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		seq := func(yield func(int) bool) {
+			for i := 0; yield(i); i++ {
+				if i == 1 {
+					cancel()
+				}
+			}
+		}
+		res, err := renderEach(ctx, t, map[string]interface{}{"items": iter.Seq[int](seq)}, "i")
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, "0:0 1:1 ", res)
+	})
+	t.Run("spread cancelled", func(t *testing.T) {
+		s := newRenderState("/", false, nil, flamingo.NullLogger{})
+		s.funcs = FuncMap{}
+		tpl, code, err := s.TokenToTemplate("spread", &Token{Type: "Block", Nodes: []*Token{codeToken("[...items].length")}})
+		require.NoError(t, err, code)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		func() {
+			// execution errors panic
+			defer func() {
+				if r := recover(); r != nil {
+					err = r.(error)
+				}
+			}()
+			err = tpl.ExecuteTemplate(ctx, new(bytes.Buffer), "spread", convert(map[string]interface{}{"items": make(chan int)}), false)
+		}()
+		assert.ErrorContains(t, err, context.Canceled.Error(), "an open channel is not received after the render is cancelled")
+	})
+}
+
+func TestSeqType(t *testing.T) {
+	type yield func(int) bool
+	assert.Equal(t, 1, seqType(reflect.TypeOf(slices.Values([]int{}))))
+	assert.Equal(t, 2, seqType(reflect.TypeOf(slices.All([]int{}))))
+	assert.Equal(t, 1, seqType(reflect.TypeOf(func(func(string) bool) {})))
+	assert.Equal(t, 0, seqType(reflect.TypeOf(func(func(int, int, int) bool) {})))
+	assert.Equal(t, 0, seqType(reflect.TypeOf(func(func(...int) bool) {})), "variadic yield")
+	assert.Equal(t, 0, seqType(reflect.TypeOf(func(func(int) error) {})))
+	assert.Equal(t, 0, seqType(reflect.TypeOf(func(yield) {})), "named yield type")
+	assert.Equal(t, 0, seqType(reflect.TypeOf(func(func(int) bool) bool { return true })))
+}
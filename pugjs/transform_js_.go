@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"flamingo.me/pugtemplate/otto/ast"
+	"flamingo.me/pugtemplate/otto/file"
 	ottoparser "flamingo.me/pugtemplate/otto/parser"
 	"flamingo.me/pugtemplate/otto/token"
 	"github.com/pkg/errors"
@@ -33,21 +34,26 @@ var (
 		token.INCREMENT:   "__op__inc", // ++
 		token.DECREMENT:   "__op__dec", // --
 
-		token.EQUAL:        "__op__eql", // ==
-		token.STRICT_EQUAL: "__op__eql", // ===
-		token.LESS:         "__op__lt",  // <
-		token.GREATER:      "__op__gt",  // >
-		token.ASSIGN:       "=",         // =
-		token.NOT:          "__op__not", // !
+		token.EQUAL:        "__op__eql",  // ==
+		token.STRICT_EQUAL: "__op__seql", // ===
+		token.LESS:         "__op__lt",   // <
+		token.GREATER:      "__op__gt",   // >
+		token.ASSIGN:       "=",          // =
+		token.NOT:          "__op__not",  // !
 
 		token.BITWISE_NOT: "__op__bitnot", // ~
 
-		token.NOT_EQUAL:        "__op__neq", // !=
-		token.STRICT_NOT_EQUAL: "__op__neq", // !==
-		token.LESS_OR_EQUAL:    "__op__lte", // <=
-		token.GREATER_OR_EQUAL: "__op__gte", // >=
+		token.NOT_EQUAL:        "__op__neq",  // !=
+		token.STRICT_NOT_EQUAL: "__op__sneq", // !==
+		token.LESS_OR_EQUAL:    "__op__lte",  // <=
+		token.GREATER_OR_EQUAL: "__op__gte",  // >=
 
 		token.DELETE: "__op__delete",
+
+		token.TYPEOF:     "__op__typeof",     // typeof
+		token.VOID:       "__op__void",       // void
+		token.IN:         "__op__in",         // in
+		token.INSTANCEOF: "__op__instanceof", // instanceof
 	}
 
 	writeTranslations io.Writer
@@ -83,6 +89,11 @@ func (p *renderState) JsExpr(expr JavaScriptExpression, wrap, rawcode bool) stri
 		// Expect the input to be a value, this makes `{ ... }` being treated as a map.
 		// Essentially we create a function with one return-statement and inject our return value
 		stmtlist = FuncToStatements(expr)
+
+		// the return statement is not the one of a template function
+		infunction, exproffset := p.infunction, p.exproffset
+		p.infunction, p.exproffset = false, len("return ")
+		defer func() { p.infunction, p.exproffset = infunction, exproffset }()
 	}
 
 	for _, stmt := range stmtlist {
@@ -95,31 +106,6 @@ func (p *renderState) JsExpr(expr JavaScriptExpression, wrap, rawcode bool) stri
 	return finalexpr
 }
 
-// interpolate a string, in the format of `something something ${arbitrary js code resuting in a string} blah`
-// we use a helper function called `s` to merge them later
-func (p *renderState) interpolate(input JavaScriptExpression) JavaScriptExpression {
-	index := 1
-	start := 0
-
-	for index < len(input) {
-		switch {
-		case input[index] == '\\':
-			break
-
-		case input[index] == '{' && input[index-1] == '$':
-			start = index + 1
-
-		case input[index] == '}' && start != 0:
-			substring := JavaScriptExpression(p.JsExpr(input[start:index], false, false))
-			input = input[:start-2] + `" ` + substring + ` "` + input[index+1:]
-			index = start + len(substring)
-			start = 0
-		}
-		index++
-	}
-	return input
-}
-
 func (p *renderState) renderStatement(stmt ast.Statement, wrap bool, dot bool) string {
 	var finalexpr string
 
@@ -130,6 +116,10 @@ func (p *renderState) renderStatement(stmt ast.Statement, wrap bool, dot bool) s
 	switch expr := stmt.(type) {
 	// an expression is just any javascript expression
 	case *ast.ExpressionStatement:
+		// the nested pug block of a code statement
+		if i, ok := expr.Expression.(*ast.Identifier); ok && i.Name == codeBlockPlaceholder && len(p.codeblocks) > 0 {
+			return p.renderCodeBlock()
+		}
 		finalexpr += p.renderExpression(expr.Expression, wrap, dot)
 
 		// a variable statement is a list of expressions, usually variable assignments (var foo = 1, bar = 2)
@@ -138,10 +128,21 @@ func (p *renderState) renderStatement(stmt ast.Statement, wrap bool, dot bool) s
 			finalexpr += p.renderExpression(v, wrap, dot)
 		}
 
-		// the return statement is created by ParseFunction
+		// the return statement is created by ParseFunction, or leaves a template function
 	case *ast.ReturnStatement:
+		if p.infunction {
+			finalexpr = `{{ __return -}}`
+			if expr.Argument != nil {
+				finalexpr = `{{ __return ` + p.renderExpression(expr.Argument, false, true) + ` -}}`
+			}
+			break
+		}
 		finalexpr += p.renderExpression(expr.Argument, wrap, dot)
 
+	// a function declaration becomes a template definition, see renderFunction
+	case *ast.FunctionStatement:
+		p.renderFunction(expr.Function)
+
 	case *ast.IfStatement:
 		finalexpr = `{{if ` + p.renderExpression(expr.Test, false, true) + `}}`
 		finalexpr += p.renderStatement(expr.Consequent, true, true)
@@ -156,17 +157,118 @@ func (p *renderState) renderStatement(stmt ast.Statement, wrap bool, dot bool) s
 		finalexpr += p.renderExpression(expr.Argument, wrap, true)
 
 	case *ast.BlockStatement:
+		p.pushScope()
 		for _, s := range expr.List {
 			finalexpr += p.renderStatement(s, wrap, true)
 		}
+		p.popScope()
+		if declaresLexical(expr.List...) {
+			finalexpr = `{{ __scope -}}` + finalexpr + `{{ end -}}`
+		}
 
 	case *ast.ForInStatement:
-		finalexpr = `{{ range ` + p.renderExpression(expr.Into, false, true) + ` := (__range_helper_keys__ ` + p.renderExpression(expr.Source, false, true) + `) }}`
-		finalexpr += p.renderStatement(expr.Body, true, true)
+		p.pushScope()
+		variable, bindings := p.loopVariable(expr.Into)
+		finalexpr = `{{ range ` + variable + ` := (__range_helper_keys__ ` + p.renderExpression(expr.Source, false, true) + `) }}`
+		finalexpr += bindings + p.renderLoopBody(expr.Body)
+		finalexpr += `{{ end }}`
+		p.popScope()
+
+	case *ast.ForOfStatement:
+		p.pushScope()
+		variable, bindings := p.loopVariable(expr.Into)
+		finalexpr = `{{ range ` + variable + ` := (__range_helper_values__ ` + p.renderExpression(expr.Source, false, true) + `) }}`
+		finalexpr += bindings + p.renderLoopBody(expr.Body)
 		finalexpr += `{{ end }}`
+		p.popScope()
+
+	// let and const in the initializer are scoped to the loop
+	case *ast.ForStatement:
+		p.pushScope()
+		defer p.popScope()
+		finalexpr = p.renderSequence(expr.Initializer)
+		test := "true"
+		if expr.Test != nil {
+			test = p.renderExpression(expr.Test, false, true)
+		}
+		finalexpr += `{{ __while ` + test + ` -}}`
+		finalexpr += p.renderLoopBody(expr.Body)
+		if expr.Update != nil {
+			finalexpr += `{{ __step -}}` + p.renderSequence(expr.Update)
+		}
+		finalexpr += `{{ end -}}`
+		if declaresLexical(&ast.ExpressionStatement{Expression: expr.Initializer}) {
+			finalexpr = `{{ __scope -}}` + finalexpr + `{{ end -}}`
+		}
 
-	// case *ast.BranchStatement:
-	// 	finalexpr = `{{ if `
+	case *ast.WhileStatement:
+		finalexpr = `{{ __while ` + p.renderExpression(expr.Test, false, true) + ` -}}`
+		finalexpr += p.renderLoopBody(expr.Body)
+		finalexpr += `{{ end -}}`
+
+	// do-while checks its condition in the step, so continue works as expected
+	case *ast.DoWhileStatement:
+		finalexpr = `{{ __while true -}}`
+		finalexpr += p.renderLoopBody(expr.Body)
+		finalexpr += `{{ __step -}}{{ if (__op__not ` + p.renderExpression(expr.Test, false, true) + `) }}{{ __break }}{{ end -}}`
+		finalexpr += `{{ end -}}`
+
+	// switch remembers the index of the matching case, and runs every case starting from there
+	case *ast.SwitchStatement:
+		p.labelcounter++
+		label := fmt.Sprintf("__switch_%d", p.labelcounter)
+		discriminant := fmt.Sprintf("$__switch_%d", p.labelcounter)
+		matched := fmt.Sprintf("$__case_%d", p.labelcounter)
+		none := len(expr.Body)
+
+		finalexpr = fmt.Sprintf(`{{ __label %q -}}`, label)
+		finalexpr += `{{ ` + discriminant + ` := ` + p.renderExpression(expr.Discriminant, false, true) + ` -}}`
+		finalexpr += fmt.Sprintf(`{{ %s := %d -}}`, matched, none)
+		for i, c := range expr.Body {
+			if c.Test == nil {
+				continue
+			}
+			finalexpr += fmt.Sprintf(`{{ if (__op__eql %s %d) }}{{ if (__op__seql %s %s) }}{{ %s = %d }}{{ end }}{{ end -}}`,
+				matched, none, discriminant, p.renderExpression(c.Test, false, true), matched, i)
+		}
+		if expr.Default >= 0 {
+			finalexpr += fmt.Sprintf(`{{ if (__op__eql %s %d) }}{{ %s = %d }}{{ end -}}`, matched, none, matched, expr.Default)
+		}
+
+		// all cases share one scope, which is the label
+		p.pushScope()
+		defer p.popScope()
+		p.breakables = append(p.breakables, label)
+		for i, c := range expr.Body {
+			finalexpr += fmt.Sprintf(`{{ if (__op__lte %s %d) -}}`, matched, i)
+			for _, s := range c.Consequent {
+				finalexpr += p.renderStatement(s, true, true)
+			}
+			finalexpr += `{{ end -}}`
+		}
+		p.breakables = p.breakables[:len(p.breakables)-1]
+		finalexpr += `{{ end -}}`
+
+	case *ast.BranchStatement:
+		label := ""
+		if expr.Label != nil {
+			label = expr.Label.Name
+		} else if expr.Token == token.BREAK && len(p.breakables) > 0 {
+			// an unlabelled break leaves the innermost switch or loop
+			label = p.breakables[len(p.breakables)-1]
+		}
+		finalexpr = `{{ __` + expr.Token.String()
+		if label != "" {
+			finalexpr += fmt.Sprintf(` %q`, label)
+		}
+		finalexpr += ` }}`
+
+	case *ast.LabelledStatement:
+		finalexpr = fmt.Sprintf(`{{ __label %q -}}`, expr.Label.Name)
+		finalexpr += p.renderStatement(expr.Statement, true, true)
+		finalexpr += `{{ end -}}`
+
+	case *ast.EmptyStatement:
 
 	case *ast.TryStatement:
 		finalexpr = `{{ try }}`
@@ -177,15 +279,340 @@ func (p *renderState) renderStatement(stmt ast.Statement, wrap bool, dot bool) s
 		// finalexpr += p.renderStatement(expr.Finally, wrap, true)
 		finalexpr += `{{ end }}`
 
-	// we cannot deal with other expressions at the moment, and we don'e expect them ayway
+	// other statements like with and debugger are not supported in templates
 	default:
-		fmt.Printf("%#v\n", stmt)
-		panic("unknown expression")
+		p.compileError(stmt.Idx0(), "unsupported statement %s", nodeType(stmt))
 	}
 
 	return finalexpr
 }
 
+// renderLoopBody renders the body of a loop, unlabelled breaks in the body leave the loop
+func (p *renderState) renderLoopBody(body ast.Statement) string {
+	p.breakables = append(p.breakables, "")
+	defer func() { p.breakables = p.breakables[:len(p.breakables)-1] }()
+	return p.renderStatement(body, true, true)
+}
+
+// loopVariable renders the variable of a for-in or for-of loop.
+// A destructuring pattern loops over a temporary variable, and the bindings are rendered at the start of the body.
+func (p *renderState) loopVariable(into ast.Expression) (string, string) {
+	v, ok := into.(*ast.VariableExpression)
+	if !ok {
+		return p.renderExpression(into, false, true), ""
+	}
+	if v.Target != nil {
+		variable := p.tempVariable()
+		return variable, renderActions(p.renderBinding(v.Target, variable, v.Kind), "{{ ")
+	}
+	p.declare(strings.TrimLeft(v.Name, "$"), v.Kind)
+	return `$` + strings.TrimLeft(v.Name, "$"), ""
+}
+
+// renderBinding binds the value to the target, which is a variable or a destructuring pattern.
+// let and const declare the variables in the current block, var assigns them.
+// The value is rendered once, patterns store it in a temporary variable.
+func (p *renderState) renderBinding(target ast.Expression, value string, kind token.Token) []string {
+	switch target := target.(type) {
+	case *ast.Identifier:
+		name := strings.TrimLeft(target.Name, "$")
+		if kind == token.LET || kind == token.CONST {
+			p.declare(name, kind)
+			return []string{`$` + name + ` := ` + value}
+		}
+		p.checkAssign(name, target.Idx)
+		return []string{`$` + name + ` = ` + value}
+
+	case *ast.AssignPattern:
+		return p.renderBinding(target.Target, `(__op__default `+value+` `+p.renderExpression(target.Default, false, true)+`)`, kind)
+
+	case *ast.ArrayPattern:
+		tmp := p.tempVariable()
+		actions := []string{tmp + ` := (__range_helper_values__ ` + value + `)`}
+		for i, element := range target.Elements {
+			if element != nil {
+				actions = append(actions, p.renderBinding(element, fmt.Sprintf(`(__tryindex %s %d)`, tmp, i), kind)...)
+			}
+		}
+		if target.Rest != nil {
+			actions = append(actions, p.renderBinding(target.Rest, fmt.Sprintf(`(__op__array_rest %s %d)`, tmp, len(target.Elements)), kind)...)
+		}
+		return actions
+
+	case *ast.ObjectPattern:
+		tmp := p.tempVariable()
+		actions := []string{tmp + ` := (__op__destructure ` + value + `)`}
+		keys := ""
+		for _, property := range target.Properties {
+			actions = append(actions, p.renderBinding(property.Value, fmt.Sprintf(`(__tryindex %s %q)`, tmp, property.Key), kind)...)
+			keys += fmt.Sprintf(` %q`, property.Key)
+		}
+		if target.Rest != nil {
+			actions = append(actions, p.renderBinding(target.Rest, `(__op__object_rest `+tmp+keys+`)`, kind)...)
+		}
+		return actions
+	}
+	panic(errors.Errorf("invalid destructuring target %T", target))
+}
+
+// renderParameters binds the parameters of a function or mixin from the arguments array
+func (p *renderState) renderParameters(params *ast.ParameterList, arguments string) string {
+	var actions []string
+	for i, param := range params.List {
+		actions = append(actions, p.renderBinding(param, fmt.Sprintf(`(__tryindex %s %d)`, arguments, i), token.LET)...)
+	}
+	if params.Rest != nil {
+		actions = append(actions, p.renderBinding(params.Rest, fmt.Sprintf(`(__op__array_rest %s %d)`, arguments, len(params.List)), token.LET)...)
+	}
+	return renderActions(actions, "{{- ")
+}
+
+// renderActions wraps each action in its own template action, opened with open
+func renderActions(actions []string, open string) string {
+	var result string
+	for _, action := range actions {
+		result += open + action + ` -}}`
+	}
+	return result
+}
+
+// tempVariable returns a new variable name for intermediate values
+func (p *renderState) tempVariable() string {
+	p.tempcounter++
+	return fmt.Sprintf(`$__tmp_%d`, p.tempcounter)
+}
+
+// renderList renders a list of values as array, spread elements are concatenated with __op__array_spread
+func (p *renderState) renderList(list []ast.Expression) string {
+	var parts []string
+	var current string
+	spread := false
+	for _, e := range list {
+		if s, ok := e.(*ast.SpreadElement); ok {
+			if current != "" {
+				parts = append(parts, `(__op__array`+current+`)`)
+				current = ""
+			}
+			parts = append(parts, p.renderExpression(s.Argument, false, true))
+			spread = true
+			continue
+		}
+		ex := p.renderExpression(e, false, true)
+		if ex == "" {
+			ex = "null"
+		}
+		current += ` ` + ex
+	}
+	if !spread {
+		return `(__op__array` + current + `)`
+	}
+	if current != "" {
+		parts = append(parts, `(__op__array`+current+`)`)
+	}
+	return `(__op__array_spread ` + strings.Join(parts, " ") + `)`
+}
+
+// renderSequence renders the initializer and update of a for loop as separate actions
+func (p *renderState) renderSequence(expr ast.Expression) string {
+	var result string
+	if seq, ok := expr.(*ast.SequenceExpression); ok {
+		for _, e := range seq.Sequence {
+			result += p.renderSequence(e)
+		}
+		return result
+	}
+	if expr == nil {
+		return ""
+	}
+	return p.renderExpression(expr, true, true)
+}
+
+// renderFunction renders a function declaration as template definition.
+// The arguments are passed as dot, var declarations are hoisted, so they shadow the variables the function closes over.
+func (p *renderState) renderFunction(fn *ast.FunctionLiteral) {
+	name := fn.Name.Name
+	if p.isTemplateFunc(name) {
+		panic(errors.Errorf("function %q is already a template function", name))
+	}
+	// like in javascript the last declaration wins, for the calls before it as well
+	if _, ok := p.functions[name]; !ok {
+		p.functionorder = append(p.functionorder, name)
+	}
+
+	breakables, infunction := p.breakables, p.infunction
+	p.breakables, p.infunction = nil, true
+	p.pushScope()
+	defer func() {
+		p.popScope()
+		p.breakables, p.infunction = breakables, infunction
+	}()
+
+	var hoisted []string
+	for _, v := range varNames(fn.Body) {
+		hoisted = append(hoisted, `$`+v+` := (__op__void 0)`)
+	}
+	params := p.renderParameters(fn.ParameterList, "$arguments")
+
+	p.functions[name] = fmt.Sprintf(`
+{{- define "function_%s" }}
+{{- $arguments := . }}
+%s%s
+%s
+{{- end }}`, name, renderActions(hoisted, "{{- "), params, p.renderStatement(fn.Body, true, true))
+}
+
+// varNames returns the names declared by var in the function body, without the ones of nested functions
+func varNames(body ast.Statement) []string {
+	var names []string
+	seen := make(map[string]bool)
+	var add func(target ast.Expression)
+	add = func(target ast.Expression) {
+		switch target := target.(type) {
+		case *ast.Identifier:
+			if name := strings.TrimLeft(target.Name, "$"); !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		case *ast.AssignPattern:
+			add(target.Target)
+		case *ast.ArrayPattern:
+			for _, element := range target.Elements {
+				add(element)
+			}
+			add(target.Rest)
+		case *ast.ObjectPattern:
+			for _, property := range target.Properties {
+				add(property.Value)
+			}
+			add(target.Rest)
+		}
+	}
+
+	ast.Walk(ast.VisitorFunc(func(v ast.Visitor, n ast.Node) ast.Visitor {
+		switch n := n.(type) {
+		case *ast.FunctionLiteral:
+			return nil
+		case *ast.VariableExpression:
+			if n.Kind == token.LET || n.Kind == token.CONST {
+				return v
+			}
+			if n.Target != nil {
+				add(n.Target)
+			} else {
+				add(&ast.Identifier{Name: n.Name})
+			}
+		}
+		return v
+	}), body)
+	return names
+}
+
+// isTemplateFunc reports if the name is a registered template function or a runtime function
+func (p *renderState) isTemplateFunc(name string) bool {
+	if name == "range" {
+		return true
+	}
+	_, registered := p.funcs[name]
+	_, runtime := funcmap[name]
+	return registered || runtime
+}
+
+// pushScope opens a new lexical scope for let and const declarations
+func (p *renderState) pushScope() {
+	p.scopes = append(p.scopes, make(map[string]bool))
+}
+
+// popScope closes the innermost lexical scope
+func (p *renderState) popScope() {
+	p.scopes = p.scopes[:len(p.scopes)-1]
+}
+
+// declare remembers a let or const declaration in the innermost scope
+func (p *renderState) declare(name string, kind token.Token) {
+	if kind != token.LET && kind != token.CONST {
+		return
+	}
+	if len(p.scopes) == 0 {
+		p.pushScope()
+	}
+	p.scopes[len(p.scopes)-1][name] = kind == token.CONST
+}
+
+// checkAssign reports a compile error if the nearest declaration of the variable is a const
+func (p *renderState) checkAssign(name string, idx file.Idx) {
+	for i := len(p.scopes) - 1; i >= 0; i-- {
+		if isConst, ok := p.scopes[i][name]; ok {
+			if isConst {
+				p.compileError(idx, "assignment to constant variable %q", name)
+			}
+			return
+		}
+	}
+}
+
+// declaresLexical reports if the statements directly declare let or const variables
+func declaresLexical(stmts ...ast.Statement) bool {
+	for _, stmt := range stmts {
+		var list []ast.Expression
+		switch stmt := stmt.(type) {
+		case *ast.VariableStatement:
+			list = stmt.List
+		case *ast.ExpressionStatement:
+			list = []ast.Expression{stmt.Expression}
+			if seq, ok := stmt.Expression.(*ast.SequenceExpression); ok {
+				list = seq.Sequence
+			}
+		}
+		for _, expr := range list {
+			if v, ok := expr.(*ast.VariableExpression); ok && (v.Kind == token.LET || v.Kind == token.CONST) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// renderCall renders the call of callee with the rendered arguments, functions declared in the template use __pug__call
+func (p *renderState) renderCall(callee ast.Expression, args []string) string {
+	result := `(` + p.renderExpression(callee, false, false)
+	if i, ok := callee.(*ast.Identifier); ok && !p.isTemplateFunc(i.Name) {
+		p.functioncalls[i.Name] = struct{}{}
+		result = fmt.Sprintf(`(__pug__call %q`, i.Name)
+	}
+	for _, arg := range args {
+		result += ` ` + arg
+	}
+	return result + `)`
+}
+
+// hasSpread reports if the list contains a spread element
+func hasSpread(list []ast.Expression) bool {
+	for _, e := range list {
+		if _, ok := e.(*ast.SpreadElement); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// renderApply renders a call with spread arguments, which passes all arguments as one array to __pug__apply
+func (p *renderState) renderApply(call *ast.CallExpression) string {
+	args := p.renderList(call.ArgumentList)
+	switch callee := call.Callee.(type) {
+	case *ast.Identifier:
+		name := callee.Name
+		if name == "range" {
+			name = "__Range"
+		} else if !p.isTemplateFunc(name) {
+			p.functioncalls[name] = struct{}{}
+		}
+		return fmt.Sprintf(`(__pug__apply %q %s)`, name, args)
+	case *ast.DotExpression:
+		return fmt.Sprintf(`(__pug__apply %s %q %s)`, p.renderExpression(callee.Left, false, true), callee.Identifier.Name, args)
+	}
+	panic(errors.Errorf("spread arguments are not supported for %T", call.Callee))
+}
+
 func (p *renderState) exprToString(expr ast.Expression) string {
 	if expr == nil {
 		return ""
@@ -230,6 +657,25 @@ func (p *renderState) exprToString(expr ast.Expression) string {
 	}
 }
 
+// compileError records an error at the position of the javascript node in the code being compiled,
+// the position is left out if the line of the code is unknown
+func (p *renderState) compileError(idx file.Idx, format string, args ...interface{}) {
+	position := ""
+	if p.line > 0 {
+		column := int(idx) - p.exproffset
+		if column < 1 {
+			column = 1
+		}
+		position = fmt.Sprintf("%d:%d:", p.line, column)
+	}
+	p.errors = append(p.errors, errors.Errorf("%s %s", position, fmt.Sprintf(format, args...)))
+}
+
+// nodeType is the name of the javascript node type for errors, e.g. DotExpression
+func nodeType(node ast.Node) string {
+	return strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast.")
+}
+
 // renderExpression renders the javascript expression into go template
 func (p *renderState) renderExpression(expr ast.Expression, wrap bool, dot bool) string {
 	if expr == nil {
@@ -258,19 +704,12 @@ func (p *renderState) renderExpression(expr ast.Expression, wrap bool, dot bool)
 		}
 
 	// StringLiteral: "test" or 'test' or `test`
+	// only template literals are interpolated, ${ is part of the quoted string
 	case *ast.StringLiteral:
-		if strings.Index(expr.Value, "${") >= 0 {
-			result = `(__str "` + string(p.interpolate(JavaScriptExpression(expr.Value))) + `")`
-			result = strings.Replace(result, `""`, ``, -1)
-			if wrap {
-				result = `{{` + result + `}}`
-			}
+		if wrap {
+			result = template.HTMLEscapeString(expr.Value)
 		} else {
-			if wrap {
-				result = template.HTMLEscapeString(expr.Value)
-			} else {
-				result = fmt.Sprintf(`%q`, expr.Value)
-			}
+			result = fmt.Sprintf(`%q`, expr.Value)
 		}
 
 	// NumberLiteral: 1 or 1.5
@@ -278,16 +717,9 @@ func (p *renderState) renderExpression(expr ast.Expression, wrap bool, dot bool)
 		result = fmt.Sprintf("%v", expr.Value)
 
 	// ArrayLiteral: [1, 2, 3]
+	// spread elements are concatenated: [a, ...b]
 	case *ast.ArrayLiteral:
-		result += `(__op__array`
-		for _, e := range expr.Value {
-			ex := p.renderExpression(e, false, true)
-			if ex == "" {
-				ex = "null"
-			}
-			result += ` ` + ex
-		}
-		result += `)`
+		result = p.renderList(expr.Value)
 		if wrap {
 			result = `{{` + result + `}}`
 		}
@@ -297,19 +729,36 @@ func (p *renderState) renderExpression(expr ast.Expression, wrap bool, dot bool)
 		result = expr.Literal
 
 	// ObjectLiteral: {"key": "value", "key2": something}
+	// spread properties are merged in order: {...a, key: 1}
 	case *ast.ObjectLiteral:
-		result = `(__op__map`
+		var parts []string
+		var current string
 		for _, o := range expr.Value {
-			result += ` "` + o.Key + `" ` + p.renderExpression(o.Value, false, true)
+			if o.Kind == "spread" {
+				if current != "" {
+					parts = append(parts, `(__op__map`+current+`)`)
+					current = ""
+				}
+				parts = append(parts, p.renderExpression(o.Value.(*ast.SpreadElement).Argument, false, true))
+				continue
+			}
+			current += ` "` + o.Key + `" ` + p.renderExpression(o.Value, false, true)
+		}
+		if len(parts) == 0 {
+			result = `(__op__map` + current + `)`
+		} else {
+			if current != "" {
+				parts = append(parts, `(__op__map`+current+`)`)
+			}
+			result = `(__op__map_spread ` + strings.Join(parts, " ") + `)`
 		}
-		result += `)`
 		if wrap {
 			result = `{{` + result + `}}`
 		}
 
 	// NullLiteral: null
 	case *ast.NullLiteral:
-		result = ``
+		result = `null`
 		if wrap {
 			return `{{null}}`
 		}
@@ -317,6 +766,11 @@ func (p *renderState) renderExpression(expr ast.Expression, wrap bool, dot bool)
 	// DotExpression: left.right
 	case *ast.DotExpression:
 		result = p.renderExpression(expr.Left, false, true) + "."
+		switch expr.Left.(type) {
+		case *ast.NumberLiteral, *ast.StringLiteral:
+			// literals need parentheses to be chained, e.g. (1.5).toFixed
+			result = `(` + result[:len(result)-1] + `).`
+		}
 		identifier := p.renderExpression(expr.Identifier, false, true)
 		if identifier[0] == '.' || identifier[0] == '$' {
 			identifier = identifier[1:]
@@ -329,6 +783,41 @@ func (p *renderState) renderExpression(expr ast.Expression, wrap bool, dot bool)
 			result = `{{` + result + `}}`
 		}
 
+	// TemplateLiteral: `text ${expression} text`
+	// a tagged template calls the tag with the array of strings, followed by the values of the expressions
+	case *ast.TemplateLiteral:
+		strs := make([]string, len(expr.Elements))
+		for i, e := range expr.Elements {
+			strs[i] = fmt.Sprintf(`%q`, e.Parsed)
+		}
+		var values []string
+		for _, e := range expr.Expressions {
+			values = append(values, p.renderExpression(e, false, true))
+		}
+		if expr.Tag != nil {
+			result = p.renderCall(expr.Tag, append([]string{`(__op__array ` + strings.Join(strs, " ") + `)`}, values...))
+		} else if len(values) == 0 {
+			if wrap {
+				return template.HTMLEscapeString(expr.Elements[0].Parsed)
+			}
+			return strs[0]
+		} else {
+			result = `(__op__template`
+			for i, s := range strs {
+				result += ` ` + s
+				if i < len(values) {
+					result += ` ` + values[i]
+				}
+			}
+			result += `)`
+		}
+		if wrap {
+			if !p.rawmode {
+				result += ` | __pug__html`
+			}
+			result = `{{` + result + `}}`
+		}
+
 	// ConditionalExpression: if (something) { ... } or foo ? a : b
 	case *ast.ConditionalExpression:
 		cons := p.renderExpression(expr.Consequent, false, true)
@@ -349,11 +838,23 @@ func (p *renderState) renderExpression(expr ast.Expression, wrap bool, dot bool)
 
 	// BinaryExpression:  left binary-operator right, 1 & 2, 0xff ^ 0x01
 	case *ast.BinaryExpression:
+		right := `""`
+		// instanceof checks against the constructor name, as there are no constructor objects
+		if expr.Operator == token.INSTANCEOF {
+			constructor, ok := expr.Right.(*ast.Identifier)
+			if !ok {
+				p.compileError(expr.Right.Idx0(), "right-hand side of 'instanceof' must be a constructor name, got %s", nodeType(expr.Right))
+			} else {
+				right = fmt.Sprintf("%q", constructor.Name)
+			}
+		} else {
+			right = p.renderExpression(expr.Right, false, true)
+		}
 		result = fmt.Sprintf(
 			`(%s %s %s)`,
 			ops[expr.Operator],
 			p.renderExpression(expr.Left, false, true),
-			p.renderExpression(expr.Right, false, true))
+			right)
 		if wrap {
 			if !p.rawmode {
 				result += ` | __pug__html`
@@ -388,11 +889,15 @@ func (p *renderState) renderExpression(expr ast.Expression, wrap bool, dot bool)
 			}
 		}
 
-		result = `(` + p.renderExpression(expr.Callee, false, false)
-		for _, c := range expr.ArgumentList {
-			result += ` ` + p.renderExpression(c, false, true)
+		if hasSpread(expr.ArgumentList) {
+			result = p.renderApply(expr)
+		} else {
+			var args []string
+			for _, c := range expr.ArgumentList {
+				args = append(args, p.renderExpression(c, false, true))
+			}
+			result = p.renderCall(expr.Callee, args)
 		}
-		result += `)`
 		if wrap {
 			if !p.rawmode {
 				result += ` | __pug__html`
@@ -429,15 +934,17 @@ func (p *renderState) renderExpression(expr ast.Expression, wrap bool, dot bool)
 					r,
 					right)
 			} else if ops[expr.Operator] == "=" {
-				result = fmt.Sprintf(`$%s :%s %s`,
+				p.checkAssign(n, expr.Idx0())
+				result = fmt.Sprintf(`$%s %s %s`,
 					n,
 					ops[expr.Operator],
 					right)
 			} else {
-				result = fmt.Sprintf(`$%s := $%s %s %s`,
-					n,
+				p.checkAssign(n, expr.Idx0())
+				result = fmt.Sprintf(`$%s = (%s $%s %s)`,
 					n,
 					ops[expr.Operator],
+					n,
 					right)
 			}
 		}
@@ -446,14 +953,31 @@ func (p *renderState) renderExpression(expr ast.Expression, wrap bool, dot bool)
 		}
 
 	// VariableExpression: creates a new variable, var foo = 1
+	// var is assigned in the scope of the template or mixin, let and const are declared in the current block
 	case *ast.VariableExpression:
+		if expr.Target != nil {
+			if !wrap {
+				panic(errors.New("destructuring is only allowed in statements"))
+			}
+			return renderActions(p.renderBinding(expr.Target, p.renderExpression(expr.Initializer, false, true), expr.Kind), "{{ ")
+		}
 		n := expr.Name
 		n = strings.TrimLeft(n, "$")
 		init := p.renderExpression(expr.Initializer, false, true)
-		if len(init) == 0 {
-			init = "null"
+		if expr.Kind == token.LET || expr.Kind == token.CONST {
+			if len(init) == 0 {
+				init = "(__op__void 0)"
+			}
+			p.declare(n, expr.Kind)
+			result = `$` + n + ` := ` + init
+		} else {
+			// a var without initializer keeps its value
+			if len(init) == 0 {
+				init = `$` + n
+			}
+			p.checkAssign(n, expr.Idx0())
+			result = `$` + n + ` = ` + init
 		}
-		result = `$` + n + ` := ` + init
 		if wrap {
 			result = `{{ ` + result + ` -}}`
 		}
@@ -482,8 +1006,11 @@ func (p *renderState) renderExpression(expr ast.Expression, wrap bool, dot bool)
 
 	// UnaryExpression: an operation on an operand, such as delete foo[bar]
 	case *ast.UnaryExpression:
-		if expr.Operator == token.INCREMENT {
-			result += p.renderExpression(expr.Operand, false, true) + ` := ` + ops[expr.Operator] + ` ` + p.renderExpression(expr.Operand, false, true)
+		if expr.Operator == token.INCREMENT || expr.Operator == token.DECREMENT {
+			if i, ok := expr.Operand.(*ast.Identifier); ok {
+				p.checkAssign(strings.TrimLeft(i.Name, "$"), i.Idx)
+			}
+			result += p.renderExpression(expr.Operand, false, true) + ` = ` + ops[expr.Operator] + ` ` + p.renderExpression(expr.Operand, false, true)
 		} else {
 			result += ops[expr.Operator] + ` ` + p.renderExpression(expr.Operand, false, true)
 		}
@@ -495,6 +1022,11 @@ func (p *renderState) renderExpression(expr ast.Expression, wrap bool, dot bool)
 
 	case *ast.NewExpression:
 		result = `(__op__array`
+		if callee, ok := expr.Callee.(*ast.Identifier); ok {
+			if _, ok := funcmap["__op__new_"+callee.Name]; ok {
+				result = `(__op__new_` + callee.Name
+			}
+		}
 		for _, o := range expr.ArgumentList {
 			ex := p.renderExpression(o, false, true)
 			if ex == "" {
@@ -507,9 +1039,9 @@ func (p *renderState) renderExpression(expr ast.Expression, wrap bool, dot bool)
 			result = `{{` + result + `}}`
 		}
 
+	// other expressions like this, regular expressions and function expressions are not supported in templates
 	default:
-		fmt.Printf("%#v\n", expr)
-		panic("unknown expression")
+		p.compileError(expr.Idx0(), "unsupported expression %s", nodeType(expr))
 	}
 
 	return result
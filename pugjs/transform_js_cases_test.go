@@ -12,12 +12,13 @@ func TestJsExpr(t *testing.T) {
 
 	t.Run("JsExpr modes", func(t *testing.T) {
 		t.Run("With raw, wrap", func(t *testing.T) {
-			assert.Equal(t, `{{ $a := 1 -}}`, s.JsExpr(`var a = 1`, true, true))
+			assert.Equal(t, `{{ $a = 1 -}}`, s.JsExpr(`var a = 1`, true, true))
+			assert.Equal(t, `{{ $a := 1 -}}`, s.JsExpr(`let a = 1`, true, true))
 			assert.Panics(t, func() { s.JsExpr(`[1,2,`, false, true) })
 		})
 
 		t.Run("With raw, not wrap", func(t *testing.T) {
-			assert.Equal(t, `$a := 1`, s.JsExpr(`var a = 1`, false, true))
+			assert.Equal(t, `$a = 1`, s.JsExpr(`var a = 1`, false, true))
 			assert.Panics(t, func() { s.JsExpr(`[1,2,`, false, true) })
 		})
 
@@ -45,7 +46,8 @@ func TestJsExpr(t *testing.T) {
 		})
 
 		t.Run("Transpile String Literal", func(t *testing.T) {
-			assert.Equal(t, `{{(__str "foo" $a " $" (__op__add 1 2) )}}`, s.JsExpr(`"foo${a} \$${1+2}"`, true, false))
+			assert.Equal(t, `foo${a} $${1+2}`, s.JsExpr(`"foo${a} \$${1+2}"`, true, false))
+			assert.Equal(t, `"a${1}b"`, s.JsExpr(`'a${1}b'`, false, false))
 
 			assert.Equal(t, `test`, s.JsExpr(`"test"`, true, false))
 			assert.Equal(t, `"test"`, s.JsExpr(`"test"`, false, false))
@@ -53,9 +55,17 @@ func TestJsExpr(t *testing.T) {
 			assert.Equal(t, `"<test>"`, s.JsExpr(`"<test>"`, false, false))
 		})
 
+		t.Run("Transpile Template Literal", func(t *testing.T) {
+			assert.Equal(t, `(__op__template "background-image:url(" $brand.heroImage.url ")")`, s.JsExpr("`background-image:url(${brand.heroImage.url})`", false, false))
+			assert.Equal(t, `{{(__op__template "a" (__op__template "" (__pug__index (__op__map "b" 1) "b") "") "\n${c}") | __pug__html}}`, s.JsExpr("`a${`${ {b: 1}['b'] }`}\n\\${c}`", true, false))
+			assert.Equal(t, `&lt;a&gt;`, s.JsExpr("`<a>`", true, false))
+			assert.Equal(t, `"${a}"`, s.JsExpr("`\\${a}`", false, false))
+		})
+
 		t.Run("Transpile Array Literal", func(t *testing.T) {
 			assert.Equal(t, `{{(__op__array 1 2 3)}}`, s.JsExpr(`[1, 2, 3]`, true, false))
 			assert.Equal(t, `(__op__array 1 2 3)`, s.JsExpr(`[1, 2, 3]`, false, false))
+			assert.Equal(t, `(__op__array_spread (__op__array 1) $a $b (__op__array 2))`, s.JsExpr(`[1, ...a, ...b, 2]`, false, false))
 		})
 
 		t.Run("Transpile Boolean expression", func(t *testing.T) {
@@ -66,11 +76,12 @@ func TestJsExpr(t *testing.T) {
 		t.Run("Transpile Map Literal", func(t *testing.T) {
 			assert.Equal(t, `{{(__op__map "key" 1 "key2" (__op__map "key1" (__op__array (__op__add 1 2) 3 4)))}}`, s.JsExpr(`{"key": 1, "key2": {"key1": [1+2, 3, 4]}}`, true, false))
 			assert.Equal(t, `(__op__map "key" 1 "key2" (__op__map "key1" (__op__array (__op__add 1 2) 3 4)))`, s.JsExpr(`{"key": 1, "key2": {"key1": [1+2, 3, 4]}}`, false, false))
+			assert.Equal(t, `(__op__map_spread $defaults (__op__map "a" $a) $overrides)`, s.JsExpr(`{...defaults, a, ...overrides}`, false, false))
 		})
 
 		t.Run("Transpile Null Literal", func(t *testing.T) {
 			assert.Equal(t, `{{null}}`, s.JsExpr(`null`, true, false))
-			assert.Equal(t, ``, s.JsExpr(`null`, false, false))
+			assert.Equal(t, `null`, s.JsExpr(`null`, false, false))
 		})
 
 		t.Run("Transpile Dot Expression", func(t *testing.T) {
@@ -87,16 +98,33 @@ func TestJsExpr(t *testing.T) {
 		})
 
 		t.Run("Transpile Binary Expressions", func(t *testing.T) {
-			assert.Equal(t, `{{(__op__b_and $a $b)}}`, s.JsExpr(`a & b`, true, false))
+			assert.Equal(t, `{{(__op__b_and $a $b) | __pug__html}}`, s.JsExpr(`a & b`, true, false))
+			assert.Equal(t, `(__op__in "key" $a)`, s.JsExpr(`'key' in a`, false, false))
+			assert.Equal(t, `(__op__instanceof $a "Array")`, s.JsExpr(`a instanceof Array`, false, false))
+		})
+
+		t.Run("Transpile Unary Expressions", func(t *testing.T) {
+			assert.Equal(t, `(__op__typeof $a)`, s.JsExpr(`typeof a`, false, false))
+			assert.Equal(t, `(__op__void 0)`, s.JsExpr(`void 0`, false, false))
+			assert.Equal(t, `(__op__seql (__op__typeof $a.b) "undefined")`, s.JsExpr(`typeof a.b === 'undefined'`, false, false))
 		})
 
 		t.Run("Transpile Call Expressions", func(t *testing.T) {
 			s.funcs = FuncMap{"foo": func(int, int) {}}
 			assert.Equal(t, `{{(foo (__op__add 1 2)) | __pug__html}}`, s.JsExpr(`foo(1+2)`, true, false))
+			assert.Equal(t, `(__pug__apply "foo" (__op__array_spread (__op__array 1) $a))`, s.JsExpr(`foo(1, ...a)`, false, false))
+			assert.Equal(t, `(__pug__apply $a "push" (__op__array_spread $b))`, s.JsExpr(`a.push(...b)`, false, false))
+			assert.Equal(t, `(foo (__op__array "<b>" "</b>") $a)`, s.JsExpr("foo`<b>${a}</b>`", false, false))
 		})
 
 		t.Run("Transpile Assign Expressions", func(t *testing.T) {
-			assert.Equal(t, `{{ $a := 1 -}}`, s.JsExpr(`a = 1`, true, false))
+			assert.Equal(t, `{{ $a = 1 -}}`, s.JsExpr(`a = 1`, true, false))
+		})
+
+		t.Run("Transpile Destructuring", func(t *testing.T) {
+			s.tempcounter = 0
+			assert.Equal(t, `{{ $__tmp_1 := (__op__destructure $teaser) -}}{{ $title := (__tryindex $__tmp_1 "title") -}}{{ $link := (__op__default (__tryindex $__tmp_1 "url") "/") -}}`, s.JsExpr(`const {title, url: link = "/"} = teaser`, true, true))
+			assert.Equal(t, `{{ $__tmp_2 := (__range_helper_values__ $list) -}}{{ $first = (__tryindex $__tmp_2 0) -}}{{ $rest = (__op__array_rest $__tmp_2 2) -}}`, s.JsExpr(`var [first, , ...rest] = list`, true, true))
 		})
 
 		t.Run("Transpile Sequence Expression", func(t *testing.T) {
@@ -107,8 +135,4 @@ func TestJsExpr(t *testing.T) {
 			assert.Equal(t, `{{(__pug__index (__pug__index $a 0) (__pug__index $b 1)) | __pug__html}}`, s.JsExpr(`a[0][b[1]]`, true, false))
 		})
 	})
-
-	t.Run("Known Bugs", func(t *testing.T) {
-		assert.Equal(t, `(__str "background-image:url(" $brand.heroImage.url ")")`, s.JsExpr("`background-image:url(${brand.heroImage.url})`", false, false))
-	})
 }
@@ -3,7 +3,9 @@ package pugjs
 import (
 	"bytes"
 	"fmt"
-	"strings"
+
+	ottoparser "flamingo.me/pugtemplate/otto/parser"
+	"github.com/pkg/errors"
 )
 
 // Render renders the mixin, either it's call or it's definition
@@ -20,21 +22,19 @@ func (m *Mixin) renderDefinition(p *renderState, wr *bytes.Buffer) error {
 		return nil
 	}
 
-	callargs := strings.Split(string(m.Args[1:len(m.Args)-1]), ",")
-	attrpart := ""
-
-	for ci, ca := range callargs {
-		ca = strings.TrimSpace(ca)
-		attrpart += fmt.Sprintf(
-			"{{- $%s := __tryindex $__args__ %d -}}",
-			ca,
-			ci,
-		)
+	// the arguments are parsed as a javascript parameter list, so they can be destructured
+	fn, err := ottoparser.ParseFunction(string(m.Args[1:len(m.Args)-1]), "")
+	if err != nil {
+		return errors.Wrapf(err, "mixin %s", m.Name)
 	}
 
 	var subblock = new(bytes.Buffer)
 
-	if err := m.Block.Render(p, subblock); err != nil {
+	p.pushScope()
+	attrpart := p.renderParameters(fn.ParameterList, "$__args__")
+	err = m.Block.Render(p, subblock)
+	p.popScope()
+	if err != nil {
 		return err
 	}
 
@@ -58,7 +58,10 @@ func (m *Mixin) renderCall(p *renderState, wr *bytes.Buffer) error {
 		attributes += ` "` + a.Name + `" ` + p.JsExpr(a.Val, false, false)
 	}
 	var subblock = new(bytes.Buffer)
-	if err := m.Block.Render(p, subblock); err != nil {
+	p.pushScope()
+	err := m.Block.Render(p, subblock)
+	p.popScope()
+	if err != nil {
 		return err
 	}
 	if len(subblock.String()) > 0 {
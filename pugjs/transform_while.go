@@ -9,6 +9,8 @@ import (
 func (w *While) Render(p *renderState, wr *bytes.Buffer) error {
 	fmt.Fprintf(wr, "{{ range %s -}}", p.JsExpr(w.Test, false, false))
 
+	p.pushScope()
+	defer p.popScope()
 	if err := w.Block.Render(p, wr); err != nil {
 		return err
 	}
@@ -3,10 +3,11 @@ package pugjs
 import (
 	"encoding/json"
 	"fmt"
-	"math/big"
 	"reflect"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 )
 
 type (
@@ -18,10 +19,6 @@ type (
 		copy() Object
 	}
 
-	truer interface {
-		True() bool
-	}
-
 	sortable interface {
 		Order() []string
 	}
@@ -33,6 +30,11 @@ func Convert(in interface{}) Object {
 }
 
 func convert(in interface{}) Object {
+	return (*converter)(nil).convert(in, 0)
+}
+
+// convert the value at the given depth of the converted data
+func (c *converter) convert(in interface{}, depth int) Object {
 	if in == nil {
 		return Nil{}
 	}
@@ -58,32 +60,59 @@ func convert(in interface{}) Object {
 		return in
 	}
 
+	if t, ok := val.Interface().(time.Time); ok {
+		return NewDate(t)
+	}
+
 	if err, ok := in.(error); ok && err != nil {
 		return String(fmt.Sprintf("Error: %+v", err))
 	}
 
+	switch val.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Struct:
+		if c.tooDeep(depth) {
+			return Undefined{}
+		}
+	}
+
 	switch val.Kind() {
 	case reflect.Slice:
+		if c == nil {
+			c = new(converter)
+		}
+		if o, ok := c.seen(val); ok {
+			return o
+		}
 		array := &Array{
 			items: make([]Object, val.Len()),
 			o:     val.Interface(),
+			conv:  c,
 		}
+		c.remember(val, array)
 		for i := 0; i < val.Len(); i++ {
-			array.items[i] = convert(val.Index(i))
+			array.items[i] = c.convert(val.Index(i), depth+1)
 		}
 		return array
 
 	case reflect.Map:
+		if c == nil {
+			c = new(converter)
+		}
+		if o, ok := c.seen(val); ok {
+			return o
+		}
 		newMap := &Map{
 			items: make(map[string]Object, val.Len()),
 			o:     val.Interface(),
+			conv:  c,
 		}
+		c.remember(val, newMap)
 		for _, k := range val.MapKeys() {
 			// dereference interfaces
 			if k.Kind() == reflect.Interface {
 				k = k.Elem()
 			}
-			newMap.items[k.String()] = convert(val.MapIndex(k))
+			newMap.items[k.String()] = c.convert(val.MapIndex(k), depth+1)
 		}
 
 		if sortable, ok := val.Interface().(sortable); ok {
@@ -98,9 +127,11 @@ func convert(in interface{}) Object {
 
 	case reflect.Struct:
 		newMap := &Map{
-			o: val.Interface(),
+			o:     val.Interface(),
+			conv:  c,
+			depth: depth,
 		}
-		// no item conversion here. It will be done on the fly on first member access
+		// no item conversion here. It will be done on the fly on member access
 
 		return newMap
 
@@ -110,7 +141,14 @@ func convert(in interface{}) Object {
 	case reflect.Interface:
 
 		if val.Type().NumMethod() == 0 {
-			return convert(val.Interface())
+			return c.convert(val.Interface(), depth)
+		}
+
+		// pointers to structs have all methods of the interface, and are converted lazily
+		if elem := val.Elem(); elem.Kind() == reflect.Ptr && !elem.IsNil() && elem.Elem().Kind() == reflect.Struct {
+			if m, ok := c.convert(elem, depth).(*Map); ok && m.items == nil {
+				return &Map{o: elem.Interface(), ptr: m.ptr, conv: m.conv, depth: m.depth}
+			}
 		}
 
 		newMap := &Map{
@@ -119,10 +157,10 @@ func convert(in interface{}) Object {
 		}
 		if !val.IsNil() {
 			for i := 0; i < val.NumMethod(); i++ {
-				newMap.items[lowerFirst(val.Type().Method(i).Name)] = convert(val.Method(i))
+				newMap.items[lowerFirst(val.Type().Method(i).Name)] = c.convert(val.Method(i), depth+1)
 			}
 
-			if m, ok := convert(val.Interface()).(*Map); ok {
+			if m, ok := c.convert(val.Interface(), depth).(*Map); ok {
 				m.convert()
 				for k, v := range m.items {
 					newMap.items[k] = v
@@ -151,14 +189,26 @@ func convert(in interface{}) Object {
 		return Nil{}
 
 	case reflect.Func:
+		if !val.IsNil() && seqType(val.Type()) > 0 {
+			return c.seqIterator(val, depth)
+		}
 		return &Func{fnc: val}
 
 	case reflect.Ptr:
 		if val.IsValid() && val.Elem().IsValid() {
-			newVal := convert(val.Elem())
+			if o, ok := c.seen(val); ok {
+				return o
+			}
+			newVal := c.convert(val.Elem(), depth)
+			if m, ok := newVal.(*Map); ok && m.items == nil && val.Elem().Kind() == reflect.Struct {
+				// the methods of the pointer are converted with the fields on first access
+				m.ptr = val
+				c.remember(val, m)
+				return m
+			}
 			if m, ok := newVal.(*Map); ok {
 				for i := 0; i < val.NumMethod(); i++ {
-					m.Assign(lowerFirst(val.Type().Method(i).Name), convert(val.Method(i)))
+					m.Assign(lowerFirst(val.Type().Method(i).Name), c.convert(val.Method(i), depth+1))
 				}
 			}
 			return newVal
@@ -172,8 +222,10 @@ func convert(in interface{}) Object {
 		return Bool(val.Bool())
 
 	case reflect.Chan:
-		// TODO iterable
-		return Nil{}
+		if val.IsNil() || val.Type().ChanDir()&reflect.RecvDir == 0 {
+			return Nil{}
+		}
+		return c.chanIterator(val, depth)
 	}
 
 	panicOrError(fmt.Sprintf("Cannot convert %#v %T %s %s", val, val, val.Type(), val.Kind()))
@@ -186,7 +238,7 @@ type Func struct {
 }
 
 // Member getter
-func (f *Func) Member(name string) Object { return Nil{} }
+func (f *Func) Member(name string) Object { return Undefined{} }
 
 // String formatter
 func (f *Func) String() string { return f.fnc.String() }
@@ -197,23 +249,16 @@ func (f *Func) True() bool { return true }
 func (f *Func) copy() Object       { return &(*f) }
 func (f *Func) iface() interface{} { return f.fnc.Interface() }
 
-// AllowDeep bool flag
-var AllowDeep = true
-
-// MarshalJSON implementation
+// MarshalJSON implementation, getters are encoded by their value
 func (f *Func) MarshalJSON() ([]byte, error) {
-	if f.fnc.Type().NumIn() == 0 && f.fnc.Type().NumOut() == 1 && AllowDeep {
-		return json.Marshal(convert(f.fnc.Call(nil)[0]))
-	}
-
-	// return function name as string, to avoid circular calls
-	return json.Marshal(f.String())
+	return MarshalJSON(f, RenderOptions{})
 }
 
 // Array type
 type Array struct {
 	items []Object
 	o     interface{}
+	conv  *converter
 }
 
 // Items getter
@@ -234,40 +279,49 @@ func (a *Array) String() string {
 
 // Member getter
 func (a *Array) Member(name string) Object {
+	if m, ok := a.method(name); ok {
+		return m
+	}
+
+	panicOrError("field '" + name + "' not found on pugjs Array")
+	return Undefined{}
+}
+
+// method returns the built-in method name
+func (a *Array) method(name string) (Object, bool) {
 	switch name {
 	case "length":
-		return &Func{fnc: reflect.ValueOf(a.Length)}
+		return &Func{fnc: reflect.ValueOf(a.Length)}, true
 
 	case "indexOf":
-		return &Func{fnc: reflect.ValueOf(a.IndexOf)}
+		return &Func{fnc: reflect.ValueOf(a.IndexOf)}, true
 
 	case "join":
-		return &Func{fnc: reflect.ValueOf(a.Join)}
+		return &Func{fnc: reflect.ValueOf(a.Join)}, true
 
 	case "push":
-		return &Func{fnc: reflect.ValueOf(a.Push)}
+		return &Func{fnc: reflect.ValueOf(a.Push)}, true
 
 	case "pop":
-		return &Func{fnc: reflect.ValueOf(a.Pop)}
+		return &Func{fnc: reflect.ValueOf(a.Pop)}, true
 
 	case "shift":
-		return &Func{fnc: reflect.ValueOf(a.Shift)}
+		return &Func{fnc: reflect.ValueOf(a.Shift)}, true
 
 	case "unshift":
-		return &Func{fnc: reflect.ValueOf(a.Unshift)}
+		return &Func{fnc: reflect.ValueOf(a.Unshift)}, true
 
 	case "splice":
-		return &Func{fnc: reflect.ValueOf(a.Splice)}
+		return &Func{fnc: reflect.ValueOf(a.Splice)}, true
 
 	case "slice":
-		return &Func{fnc: reflect.ValueOf(a.Slice)}
+		return &Func{fnc: reflect.ValueOf(a.Slice)}, true
 
 	case "sort":
-		return &Func{fnc: reflect.ValueOf(a.Sort)}
+		return &Func{fnc: reflect.ValueOf(a.Sort)}, true
 	}
 
-	panicOrError("field '" + name + "' not found on pugjs Array")
-	return Nil{}
+	return nil, false
 }
 
 // Splice an array
@@ -341,7 +395,7 @@ func (a *Array) Shift() Object {
 		a.items = a.items[1:]
 		return first
 	}
-	return nil
+	return Undefined{}
 }
 
 // Unshift adds an element to the beginning of the array and returns the new length
@@ -350,14 +404,14 @@ func (a *Array) Unshift(elements ...Object) int {
 	return len(a.items)
 }
 
-// True getter
+// True getter, arrays are always truthy
 func (a *Array) True() bool {
-	return len(a.items) > 0
+	return true
 }
 
 // MarshalJSON implementation
 func (a *Array) MarshalJSON() ([]byte, error) {
-	return json.Marshal(a.items)
+	return a.conv.marshalJSON(a)
 }
 
 func (a *Array) copy() Object {
@@ -377,37 +431,59 @@ type Map struct {
 	items map[string]Object
 	o     interface{}
 	order []string
+	// ptr is the pointer to the struct o, for its methods
+	ptr reflect.Value
+	// lazy holds the struct members converted before the whole map
+	lazy map[string]Object
+	// conv and depth convert the struct members
+	conv  *converter
+	depth int
+	// source is the shared map a copy-on-write view reads through until it is converted, see share
+	source *Map
+	// mu guards the lazy conversion, so renders can share the map
+	mu sync.Mutex
 }
 
 func (m *Map) convert() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.convertLocked()
+}
+
+func (m *Map) convertLocked() {
 	if m.items != nil {
 		return
 	}
 
+	if m.source != nil {
+		m.copySource()
+		return
+	}
+
 	if m.o == nil {
 		m.items = make(map[string]Object)
 		m.order = make([]string, 0)
 		return
 	}
 
-	val, ok := m.o.(reflect.Value)
+	val, receiver, ok := m.structValue()
 	if !ok {
-		val = reflect.ValueOf(m.o)
+		m.items = make(map[string]Object)
+		return
 	}
+	plan := planFor(receiver.Type())
 
-	m.items = make(map[string]Object, val.Type().NumField()+val.Type().NumMethod())
-
-	for i := 0; i < val.NumField(); i++ {
-		if val.Field(i).CanInterface() {
-			m.items[lowerFirst(val.Type().Field(i).Name)] = convert(val.Field(i))
+	m.items = make(map[string]Object, len(plan.members))
+	for _, member := range plan.members {
+		if item, ok := m.lazy[member.key]; ok {
+			m.items[member.key] = item
+			continue
 		}
+		m.items[member.key] = member.value(m.conv, m.depth+1, val, receiver)
 	}
+	m.lazy = nil
 
-	for i := 0; i < val.NumMethod(); i++ {
-		m.items[lowerFirst(val.Type().Method(i).Name)] = convert(val.Method(i))
-	}
-
-	if sortable, ok := val.Interface().(sortable); ok {
+	if sortable, ok := receiver.Interface().(sortable); ok {
 		order := sortable.Order()
 		m.order = make([]string, len(order))
 		for i, o := range order {
@@ -500,6 +576,15 @@ func (m *Map) Assign(key string, field Object) {
 	}
 }
 
+// set assigns the key and appends new keys to the order, to build ordered maps
+func (m *Map) set(key string, field Object) {
+	m.convert()
+	if _, ok := m.items[key]; !ok {
+		m.order = append(m.order, key)
+	}
+	m.items[key] = field
+}
+
 // HasMember checks if a member exists
 func (m *Map) HasMember(field string) bool {
 	m.convert()
@@ -510,8 +595,8 @@ func (m *Map) HasMember(field string) bool {
 
 // Member getter
 func (m *Map) Member(field string) Object {
-	m.convert()
 	if field == "__assign" {
+		m.convert()
 		return &Func{fnc: reflect.ValueOf(func(k, v interface{}) Object {
 			// if we have a ordered map we need to append to not lose it
 			// this is only allowed to happen if we have an ordered list, otherwise we would
@@ -525,54 +610,62 @@ func (m *Map) Member(field string) Object {
 		})}
 	}
 
-	if i, ok := m.items[field]; ok {
+	if i, ok := m.item(field); ok {
 		return i
 	}
-	if i, ok := m.items[upperFirst(field)]; ok {
+	if key, ok := m.conv.fieldNames().key(m.o, field); ok {
+		if i, ok := m.item(key); ok {
+			return i
+		}
+	}
+	if i, ok := m.item(upperFirst(field)); ok {
 		return i
 	}
-	if i, ok := m.items[strings.Title(field)]; ok {
+	if i, ok := m.item(strings.Title(field)); ok {
 		return i
 	}
 
-	field = strings.NewReplacer("id", "ID", "url", "URL", "api", "API").Replace(field)
+	field = initialisms.Replace(field)
 
-	if i, ok := m.items[field]; ok {
+	if i, ok := m.item(field); ok {
 		return i
 	}
-	if i, ok := m.items[upperFirst(field)]; ok {
+	if i, ok := m.item(upperFirst(field)); ok {
 		return i
 	}
-	if i, ok := m.items[strings.Title(field)]; ok {
+	if i, ok := m.item(strings.Title(field)); ok {
 		return i
 	}
 
-	return Nil{}
+	return Undefined{}
 }
 
+// initialisms are guessed for keys which are not found
+var initialisms = strings.NewReplacer("id", "ID", "url", "URL", "api", "API")
+
 // MarshalJSON implementation
 func (m *Map) MarshalJSON() ([]byte, error) {
 	if s, ok := m.o.(json.Marshaler); ok {
 		return s.MarshalJSON()
 	}
-	m.convert()
-	tmp := make(map[string]interface{}, len(m.items))
-	for k, v := range m.items {
-		tmp[lowerFirst(k)] = v
-	}
-	return json.Marshal(tmp)
+	return m.conv.marshalJSON(m)
 }
 
-// True getter
+// True getter, objects are always truthy
 func (m *Map) True() bool {
-	if m.o != nil && reflect.DeepEqual(reflect.Zero(reflect.TypeOf(m.o)).Interface(), m.o) {
-		return false
-	}
-	m.convert()
-	return len(m.items) > 0
+	return true
 }
 
 func (m *Map) copy() Object {
+	m.mu.Lock()
+	if m.items == nil && len(m.lazy) == 0 {
+		m.mu.Unlock()
+		// not converted yet, the copy converts on its own
+		return &Map{o: m.o, ptr: m.ptr, conv: m.conv, depth: m.depth, source: m.source}
+	}
+	m.convertLocked()
+	m.mu.Unlock()
+
 	c := &Map{
 		items: make(map[string]Object, len(m.items)),
 		o:     m.o,
@@ -613,7 +706,7 @@ func (s String) Member(field string) Object {
 	case "indexOf":
 		return &Func{fnc: reflect.ValueOf(s.IndexOf)}
 	}
-	return Nil{}
+	return Undefined{}
 }
 
 // CharAt function
@@ -676,10 +769,26 @@ func (s String) copy() Object { return s }
 type Number float64
 
 // Member getter
-func (n Number) Member(string) Object { return Nil{} }
+func (n Number) Member(name string) Object {
+	switch name {
+	case "toFixed":
+		return &Func{fnc: reflect.ValueOf(n.ToFixed)}
+
+	case "toPrecision":
+		return &Func{fnc: reflect.ValueOf(n.ToPrecision)}
+
+	case "toString":
+		return &Func{fnc: reflect.ValueOf(n.ToString)}
+
+	case "toLocaleString":
+		return &Func{fnc: reflect.ValueOf(n.ToLocaleString)}
+	}
+
+	return Undefined{}
+}
 
 // String formatter
-func (n Number) String() string     { return big.NewFloat(float64(n)).String() }
+func (n Number) String() string     { return numberToString(float64(n)) }
 func (n Number) copy() Object       { return n }
 func (n Number) iface() interface{} { return n }
 
@@ -687,7 +796,7 @@ func (n Number) iface() interface{} { return n }
 type Bool bool
 
 // Member getter
-func (b Bool) Member(string) Object { return Nil{} }
+func (b Bool) Member(string) Object { return Undefined{} }
 
 // String formatter
 func (b Bool) String() string { return fmt.Sprintf("%v", bool(b)) }
@@ -700,8 +809,8 @@ func (b Bool) iface() interface{} { return b }
 // Nil type
 type Nil struct{}
 
-// Member is always nil
-func (n Nil) Member(string) Object { return Nil{} }
+// Member is always undefined
+func (n Nil) Member(string) Object { return Undefined{} }
 
 // String is always empty
 func (n Nil) String() string { return "" }
@@ -713,3 +822,20 @@ func (n Nil) MarshalJSON() ([]byte, error) { return []byte("null"), nil }
 func (n Nil) True() bool         { return false }
 func (n Nil) copy() Object       { return Nil{} }
 func (n Nil) iface() interface{} { return nil }
+
+// Undefined type, used for missing members and unset variables
+type Undefined struct{}
+
+// Member is always undefined
+func (u Undefined) Member(string) Object { return Undefined{} }
+
+// String is always empty
+func (u Undefined) String() string { return "" }
+
+// MarshalJSON of Undefined, map keys holding undefined are omitted by the map itself
+func (u Undefined) MarshalJSON() ([]byte, error) { return []byte("null"), nil }
+
+// True is always false
+func (u Undefined) True() bool         { return false }
+func (u Undefined) copy() Object       { return Undefined{} }
+func (u Undefined) iface() interface{} { return nil }
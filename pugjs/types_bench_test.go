@@ -0,0 +1,110 @@
+package pugjs
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"testing"
+
+	"flamingo.me/flamingo/v3/framework/flamingo"
+)
+
+type (
+	benchPrice struct {
+		Amount   float64
+		Currency string
+	}
+
+	benchVariant struct {
+		SKU        string
+		Title      string
+		Price      benchPrice
+		Attributes map[string]string
+	}
+
+	benchProduct struct {
+		ID       string
+		Title    string
+		Variants []*benchVariant
+		Tags     []string
+	}
+)
+
+func (v *benchVariant) FormattedPrice() string {
+	return strconv.FormatFloat(v.Price.Amount, 'f', 2, 64) + " " + v.Price.Currency
+}
+
+func (v *benchVariant) IsAvailable() bool { return v.Price.Amount > 0 }
+
+func (p benchProduct) VariantCount() int { return len(p.Variants) }
+
+func benchData(variants int) map[string]interface{} {
+	product := benchProduct{ID: "p-1", Title: "Shirt", Tags: []string{"new", "sale", "cotton"}}
+	for i := 0; i < variants; i++ {
+		product.Variants = append(product.Variants, &benchVariant{
+			SKU:        "sku-" + strconv.Itoa(i),
+			Title:      "Variant " + strconv.Itoa(i),
+			Price:      benchPrice{Amount: float64(i) + .99, Currency: "EUR"},
+			Attributes: map[string]string{"color": "red", "size": "M"},
+		})
+	}
+	return map[string]interface{}{"product": product}
+}
+
+func BenchmarkConvert_Struct(b *testing.B) {
+	data := benchData(20)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m := convert(data["product"]).(*Map)
+		m.Member("title")
+	}
+}
+
+func BenchmarkConvert_Pointer(b *testing.B) {
+	variant := benchData(1)["product"].(benchProduct).Variants[0]
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m := convert(variant).(*Map)
+		m.Member("sku")
+		m.Member("formattedPrice")
+	}
+}
+
+func BenchmarkConvert_Full(b *testing.B) {
+	data := benchData(20)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m := convert(data).(*Map)
+		m.Keys()
+		for _, v := range m.Member("product").(*Map).Member("variants").(*Array).items {
+			v.(*Map).Keys()
+		}
+	}
+}
+
+func BenchmarkRender_Product(b *testing.B) {
+	s := newRenderState("/", false, nil, flamingo.NullLogger{})
+	s.funcs = FuncMap{}
+	inline := true
+	tpl, _, err := s.TokenToTemplate("product", &Token{Type: "Block", Nodes: []*Token{
+		{Type: "Code", Val: "product.title + ' ' + product.variantCount()", MustEscape: true, IsInline: &inline},
+		{Type: "Each", Obj: "product.variants", Val: "variant", Block: &Token{Type: "Block", Nodes: []*Token{
+			{Type: "Code", Val: "variant.sku + ': ' + variant.formattedPrice()", MustEscape: true, IsInline: &inline},
+			{Type: "Code", Val: "variant.attributes.color", MustEscape: true, IsInline: &inline},
+		}}},
+	}})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	data := benchData(20)
+	buf := new(bytes.Buffer)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		if err := tpl.ExecuteTemplate(context.Background(), buf, "product", convert(data), false); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package pugjs
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// coerce converts an Object to a Go type, so template functions can declare plain Go parameters:
+// numbers, strings and bools are converted like JavaScript does, arrays to slices, and maps to Go maps
+// or to structs by the field names templates use
+func coerce(o Object, typ reflect.Type) (reflect.Value, error) {
+	return (*converter)(nil).coerce(o, typ)
+}
+
+// coerce the Object to the Go type, structs are filled by the field names of the converter
+func (c *converter) coerce(o Object, typ reflect.Type) (reflect.Value, error) {
+	if o == nil {
+		o = Undefined{}
+	}
+	if reflect.TypeOf(o).AssignableTo(typ) {
+		return reflect.ValueOf(o), nil
+	}
+	switch o.(type) {
+	case Nil, Undefined:
+		return reflect.Zero(typ), nil
+	}
+
+	// the original Go value, e.g. the struct of a map or the time of a date
+	if m, ok := o.(*Map); ok && m.ptr.IsValid() && m.ptr.Type().AssignableTo(typ) {
+		return m.ptr, nil
+	}
+	if original := o.iface(); original != nil {
+		if v, ok := original.(reflect.Value); ok {
+			original = v.Interface()
+		}
+		if reflect.TypeOf(original).AssignableTo(typ) {
+			return reflect.ValueOf(original), nil
+		}
+	}
+
+	switch typ.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(o.String()).Convert(typ), nil
+
+	case reflect.Bool:
+		return reflect.ValueOf(toBoolean(o)).Convert(typ), nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f, err := coerceNumber(o, typ)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if math.IsNaN(f) {
+			return reflect.Value{}, fmt.Errorf("expected %s; got NaN", typ)
+		}
+		v := reflect.New(typ).Elem()
+		if math.IsInf(f, 0) || v.OverflowInt(int64(f)) {
+			return reflect.Value{}, fmt.Errorf("%s overflows %s", o, typ)
+		}
+		v.SetInt(int64(f))
+		return v, nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		f, err := coerceNumber(o, typ)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if math.IsNaN(f) {
+			return reflect.Value{}, fmt.Errorf("expected %s; got NaN", typ)
+		}
+		v := reflect.New(typ).Elem()
+		if f < 0 || math.IsInf(f, 0) || v.OverflowUint(uint64(f)) {
+			return reflect.Value{}, fmt.Errorf("%s overflows %s", o, typ)
+		}
+		v.SetUint(uint64(f))
+		return v, nil
+
+	case reflect.Float32, reflect.Float64:
+		f, err := coerceNumber(o, typ)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(f).Convert(typ), nil
+
+	case reflect.Slice:
+		if s, ok := o.(String); ok && typ.Elem().Kind() == reflect.Uint8 {
+			return reflect.ValueOf([]byte(s)).Convert(typ), nil
+		}
+		a, ok := o.(*Array)
+		if !ok {
+			break
+		}
+		v := reflect.MakeSlice(typ, len(a.items), len(a.items))
+		return v, c.coerceItems(a, v)
+
+	case reflect.Array:
+		a, ok := o.(*Array)
+		if !ok {
+			break
+		}
+		v := reflect.New(typ).Elem()
+		if len(a.items) > v.Len() {
+			return reflect.Value{}, fmt.Errorf("%d items do not fit into %s", len(a.items), typ)
+		}
+		return v, c.coerceItems(a, v)
+
+	case reflect.Map:
+		m, ok := o.(*Map)
+		if !ok || typ.Key().Kind() != reflect.String {
+			break
+		}
+		v := reflect.MakeMapWithSize(typ, len(m.Keys()))
+		for _, key := range m.Keys() {
+			item, err := c.coerce(m.Member(key), typ.Elem())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("key %q: %w", key, err)
+			}
+			v.SetMapIndex(reflect.ValueOf(key).Convert(typ.Key()), item)
+		}
+		return v, nil
+
+	case reflect.Struct:
+		m, ok := o.(*Map)
+		if !ok {
+			break
+		}
+		return c.coerceStruct(m, typ)
+
+	case reflect.Ptr:
+		elem, err := c.coerce(o, typ.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		v := reflect.New(typ.Elem())
+		v.Elem().Set(elem)
+		return v, nil
+
+	case reflect.Func:
+		if f, ok := o.(*Func); ok && f.fnc.Type().ConvertibleTo(typ) {
+			return f.fnc.Convert(typ), nil
+		}
+	}
+
+	return reflect.Value{}, fmt.Errorf("expected %s; got %s", typ, objectTypeName(o))
+}
+
+// coerceNumber converts the object to a number, strings which are not numbers are an error
+func coerceNumber(o Object, typ reflect.Type) (float64, error) {
+	switch o.(type) {
+	case Number, String, Bool:
+	default:
+		return 0, fmt.Errorf("expected %s; got %s", typ, objectTypeName(o))
+	}
+	f := toNumber(o)
+	if _, isNumber := o.(Number); math.IsNaN(f) && !isNumber {
+		return 0, fmt.Errorf("expected %s; got %q", typ, o.String())
+	}
+	return f, nil
+}
+
+// coerceItems converts the array items into the slice or array v
+func (c *converter) coerceItems(a *Array, v reflect.Value) error {
+	for i, item := range a.items {
+		elem, err := c.coerce(item, v.Type().Elem())
+		if err != nil {
+			return fmt.Errorf("item %d: %w", i, err)
+		}
+		v.Index(i).Set(elem)
+	}
+	return nil
+}
+
+// coerceStruct fills the exported fields of a new struct with the map members, by the names the field is
+// accessible by in templates, or by the Go field name
+func (c *converter) coerceStruct(m *Map, typ reflect.Type) (reflect.Value, error) {
+	resolver := c.fieldNames().resolver
+
+	v := reflect.New(typ).Elem()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		names := append(resolver.FieldNames(field), lowerFirst(field.Name), field.Name)
+		for _, name := range names {
+			if !m.HasMember(name) {
+				continue
+			}
+			value, err := c.coerce(m.Member(name), field.Type)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("field %s: %w", field.Name, err)
+			}
+			v.Field(i).Set(value)
+			break
+		}
+	}
+	return v, nil
+}
+
+// objectTypeName is the name of the object type for error messages, e.g. String or Array
+func objectTypeName(o Object) string {
+	return reflect.Indirect(reflect.ValueOf(o)).Type().Name()
+}
@@ -0,0 +1,124 @@
+package pugjs
+
+import (
+	"context"
+	"reflect"
+	"sync"
+)
+
+type (
+	// RenderOptions configure how the data of a render is converted and serialized
+	RenderOptions struct {
+		// MaxDepth limits the nesting of slices, maps and structs, deeper values are undefined. 0 is unlimited.
+		MaxDepth int
+		// SkipGetters serializes methods without arguments by their name instead of calling them
+		SkipGetters bool
+	}
+
+	// converter converts Go values to Objects for one render. Pointers, maps and slices which have been
+	// converted already are returned as the same Object, which also breaks reference cycles.
+	converter struct {
+		options RenderOptions
+		types   *objectTypes
+		mu      sync.Mutex
+		objects map[identity]Object
+	}
+
+	// identity of a pointer, map or slice
+	identity struct {
+		typ reflect.Type
+		ptr uintptr
+		len int
+	}
+
+	// objectTypes are the prototype methods and struct field names of an engine
+	objectTypes struct {
+		prototypes map[string]map[string]PrototypeFunc
+		fields     *fieldNames
+	}
+
+	renderOptionsKey struct{}
+	objectTypesKey   struct{}
+)
+
+// WithRenderOptions sets the render options for renders with the context
+func WithRenderOptions(ctx context.Context, options RenderOptions) context.Context {
+	return context.WithValue(ctx, renderOptionsKey{}, options)
+}
+
+// RenderOptionsFromContext returns the render options of the context, and false if none are set
+func RenderOptionsFromContext(ctx context.Context) (RenderOptions, bool) {
+	if ctx == nil {
+		return RenderOptions{}, false
+	}
+	options, ok := ctx.Value(renderOptionsKey{}).(RenderOptions)
+	return options, ok
+}
+
+// withObjectTypes sets the object types of the engine for renders with the context
+func withObjectTypes(ctx context.Context, types *objectTypes) context.Context {
+	return context.WithValue(ctx, objectTypesKey{}, types)
+}
+
+// objectTypesFromContext returns the object types of the render, nil if the render has no engine
+func objectTypesFromContext(ctx context.Context) *objectTypes {
+	if ctx == nil {
+		return nil
+	}
+	types, _ := ctx.Value(objectTypesKey{}).(*objectTypes)
+	return types
+}
+
+func newConverter(options RenderOptions, types *objectTypes) *converter {
+	return &converter{options: options, types: types}
+}
+
+// fieldNames returns the field names of the engine, or the default field names without an engine
+func (c *converter) fieldNames() *fieldNames {
+	if c == nil || c.types == nil || c.types.fields == nil {
+		return defaultFieldNames
+	}
+	return c.types.fields
+}
+
+// tooDeep checks the depth against the maximum depth
+func (c *converter) tooDeep(depth int) bool {
+	return c != nil && c.options.MaxDepth > 0 && depth > c.options.MaxDepth
+}
+
+func identityOf(val reflect.Value) identity {
+	id := identity{typ: val.Type(), ptr: val.Pointer()}
+	if val.Kind() == reflect.Slice {
+		id.len = val.Len()
+	}
+	return id
+}
+
+// tracked checks if the identity of the value is tracked, empty slices can share their pointer
+func (c *converter) tracked(val reflect.Value) bool {
+	return c != nil && !val.IsNil() && (val.Kind() != reflect.Slice || val.Len() > 0)
+}
+
+// seen returns the Object the pointer, map or slice has been converted to before
+func (c *converter) seen(val reflect.Value) (Object, bool) {
+	if !c.tracked(val) {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	o, ok := c.objects[identityOf(val)]
+	return o, ok
+}
+
+// remember the Object the pointer, map or slice is converted to
+func (c *converter) remember(val reflect.Value, o Object) {
+	if !c.tracked(val) {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.objects == nil {
+		c.objects = make(map[identity]Object)
+	}
+	c.objects[identityOf(val)] = o
+}
@@ -1,6 +1,7 @@
 package pugjs
 
 import (
+	"encoding/json"
 	"errors"
 	"reflect"
 	"testing"
@@ -147,8 +148,8 @@ func TestConvert(t *testing.T) {
 			{uint8(1), Number(1)},
 			{complex(1, 1), Nil{}},
 
-			// Channel
-			{make(chan bool), Nil{}},
+			// Send only channels can not be iterated
+			{make(chan<- bool), Nil{}},
 
 			// Bool
 			{true, Bool(true)},
@@ -204,5 +205,73 @@ func TestMapTruer(t *testing.T) {
 
 	m = struct{ Foo string }{}
 	cm = convert(m)
-	assert.False(t, cm.(*Map).True())
+	assert.True(t, cm.(*Map).True(), "objects are always truthy")
+}
+
+type testConvertCategory struct {
+	Name     string
+	Parent   *testConvertCategory
+	Children []*testConvertCategory
+}
+
+func (c *testConvertCategory) Root() *testConvertCategory {
+	if c.Parent == nil {
+		return c
+	}
+	return c.Parent.Root()
+}
+
+func TestConvertCycles(t *testing.T) {
+	t.Run("self referencing map and slice", func(t *testing.T) {
+		m := map[string]interface{}{"name": "m"}
+		m["self"] = m
+		s := []interface{}{"a", nil}
+		s[1] = s
+
+		cm := convert(m).(*Map)
+		assert.Same(t, cm, cm.Member("self"))
+		cs := convert(s).(*Array)
+		assert.Same(t, cs, cs.items[1])
+	})
+
+	t.Run("pointers are converted once", func(t *testing.T) {
+		parent := &testConvertCategory{Name: "parent"}
+		child := &testConvertCategory{Name: "child", Parent: parent}
+		parent.Children = []*testConvertCategory{child}
+
+		c := newConverter(RenderOptions{}, nil)
+		cp := c.convert(parent, 0).(*Map)
+		cc := cp.Member("children").(*Array).items[0].(*Map)
+		assert.Same(t, cp, cc.Member("parent"))
+		assert.Same(t, cc, c.convert(child, 0))
+	})
+
+	t.Run("json", func(t *testing.T) {
+		parent := &testConvertCategory{Name: "parent"}
+		parent.Children = []*testConvertCategory{{Name: "child", Parent: parent}}
+
+		b, err := json.Marshal(convert(parent))
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"name": "parent", "parent": null, "root": "[Circular]", "children": [{"name": "child", "parent": "[Circular]", "root": "[Circular]", "children": []}]}`, string(b))
+
+		b, err = MarshalJSON(convert(parent), RenderOptions{SkipGetters: true, MaxDepth: 1})
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"name": "parent", "parent": null, "root": "<func() *pugjs.testConvertCategory Value>", "children": [null]}`, string(b))
+	})
+
+	t.Run("max depth", func(t *testing.T) {
+		data := map[string]interface{}{
+			"a": map[string]interface{}{
+				"b": map[string]interface{}{"c": "deep"},
+				"s": "shallow",
+			},
+		}
+
+		m := newConverter(RenderOptions{MaxDepth: 1}, nil).convert(data, 0).(*Map)
+		assert.Equal(t, String("shallow"), m.Member("a").Member("s"))
+		assert.Equal(t, Undefined{}, m.Member("a").Member("b"))
+
+		m = newConverter(RenderOptions{MaxDepth: 2}, nil).convert(data, 0).(*Map)
+		assert.Equal(t, String("deep"), m.Member("a").Member("b").Member("c"))
+	})
 }
@@ -0,0 +1,283 @@
+package pugjs
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Date type, the JavaScript equivalent of a time.Time.
+// The zero value is an invalid date.
+type Date struct {
+	t     time.Time
+	valid bool
+}
+
+// NewDate creates a Date for the given time
+func NewDate(t time.Time) *Date {
+	return &Date{t: t, valid: true}
+}
+
+// Time returns the underlying time, and false if the date is invalid
+func (d *Date) Time() (time.Time, bool) { return d.t, d.valid }
+
+// String formatter, same format as Date.prototype.toString
+func (d *Date) String() string {
+	if !d.valid {
+		return "Invalid Date"
+	}
+	return d.t.Format("Mon Jan 02 2006 15:04:05 GMT-0700 (MST)")
+}
+
+func (d *Date) copy() Object       { return &Date{t: d.t, valid: d.valid} }
+func (d *Date) iface() interface{} { return d.t }
+
+// MarshalJSON implementation, invalid dates are null
+func (d *Date) MarshalJSON() ([]byte, error) {
+	if !d.valid {
+		return []byte("null"), nil
+	}
+	return json.Marshal(d.t.UTC().Format("2006-01-02T15:04:05.000Z"))
+}
+
+// Member getter
+func (d *Date) Member(name string) Object {
+	switch name {
+	case "getFullYear", "getMonth", "getDate", "getDay", "getHours", "getMinutes", "getSeconds", "getMilliseconds",
+		"getUTCFullYear", "getUTCMonth", "getUTCDate", "getUTCDay", "getUTCHours", "getUTCMinutes", "getUTCSeconds", "getUTCMilliseconds",
+		"getTime", "getTimezoneOffset", "valueOf",
+		"toISOString", "toJSON", "toString", "toDateString", "toLocaleDateString":
+		return &Func{fnc: reflect.ValueOf(d).MethodByName(upperFirst(name))}
+	}
+
+	// time.Time methods, e.g. format or unix, stay available as before
+	if m := reflect.ValueOf(d.t).MethodByName(upperFirst(name)); m.IsValid() {
+		return &Func{fnc: m}
+	}
+
+	return Undefined{}
+}
+
+func (d *Date) field(t time.Time, f func(time.Time) int) Number {
+	if !d.valid {
+		return Number(math.NaN())
+	}
+	return Number(f(t))
+}
+
+func yearOf(t time.Time) int        { return t.Year() }
+func monthOf(t time.Time) int       { return int(t.Month()) - 1 }
+func dayOf(t time.Time) int         { return t.Day() }
+func weekdayOf(t time.Time) int     { return int(t.Weekday()) }
+func hourOf(t time.Time) int        { return t.Hour() }
+func minuteOf(t time.Time) int      { return t.Minute() }
+func secondOf(t time.Time) int      { return t.Second() }
+func millisecondOf(t time.Time) int { return t.Nanosecond() / int(time.Millisecond) }
+
+// GetFullYear returns the year
+func (d *Date) GetFullYear() Number { return d.field(d.t, yearOf) }
+
+// GetMonth returns the month, starting with 0 for January
+func (d *Date) GetMonth() Number { return d.field(d.t, monthOf) }
+
+// GetDate returns the day of the month
+func (d *Date) GetDate() Number { return d.field(d.t, dayOf) }
+
+// GetDay returns the day of the week, starting with 0 for Sunday
+func (d *Date) GetDay() Number { return d.field(d.t, weekdayOf) }
+
+// GetHours returns the hour
+func (d *Date) GetHours() Number { return d.field(d.t, hourOf) }
+
+// GetMinutes returns the minutes
+func (d *Date) GetMinutes() Number { return d.field(d.t, minuteOf) }
+
+// GetSeconds returns the seconds
+func (d *Date) GetSeconds() Number { return d.field(d.t, secondOf) }
+
+// GetMilliseconds returns the milliseconds
+func (d *Date) GetMilliseconds() Number { return d.field(d.t, millisecondOf) }
+
+// GetUTCFullYear returns the year in UTC
+func (d *Date) GetUTCFullYear() Number { return d.field(d.t.UTC(), yearOf) }
+
+// GetUTCMonth returns the month in UTC, starting with 0 for January
+func (d *Date) GetUTCMonth() Number { return d.field(d.t.UTC(), monthOf) }
+
+// GetUTCDate returns the day of the month in UTC
+func (d *Date) GetUTCDate() Number { return d.field(d.t.UTC(), dayOf) }
+
+// GetUTCDay returns the day of the week in UTC, starting with 0 for Sunday
+func (d *Date) GetUTCDay() Number { return d.field(d.t.UTC(), weekdayOf) }
+
+// GetUTCHours returns the hour in UTC
+func (d *Date) GetUTCHours() Number { return d.field(d.t.UTC(), hourOf) }
+
+// GetUTCMinutes returns the minutes in UTC
+func (d *Date) GetUTCMinutes() Number { return d.field(d.t.UTC(), minuteOf) }
+
+// GetUTCSeconds returns the seconds in UTC
+func (d *Date) GetUTCSeconds() Number { return d.field(d.t.UTC(), secondOf) }
+
+// GetUTCMilliseconds returns the milliseconds in UTC
+func (d *Date) GetUTCMilliseconds() Number { return d.field(d.t.UTC(), millisecondOf) }
+
+// GetTime returns the milliseconds since the unix epoch
+func (d *Date) GetTime() Number {
+	if !d.valid {
+		return Number(math.NaN())
+	}
+	return Number(d.t.UnixMilli())
+}
+
+// ValueOf is the same as GetTime
+func (d *Date) ValueOf() Number { return d.GetTime() }
+
+// GetTimezoneOffset returns the difference to UTC in minutes, positive west of UTC
+func (d *Date) GetTimezoneOffset() Number {
+	if !d.valid {
+		return Number(math.NaN())
+	}
+	_, offset := d.t.Zone()
+	return Number(-offset / 60)
+}
+
+// ToISOString formats the date as an ISO 8601 string in UTC
+func (d *Date) ToISOString() (string, error) {
+	if !d.valid {
+		return "", errors.New("RangeError: Invalid time value")
+	}
+	return d.t.UTC().Format("2006-01-02T15:04:05.000Z"), nil
+}
+
+// ToJSON is the same as ToISOString, but null for invalid dates
+func (d *Date) ToJSON() Object {
+	if !d.valid {
+		return Nil{}
+	}
+	s, _ := d.ToISOString()
+	return String(s)
+}
+
+// ToString is the same as String
+func (d *Date) ToString() string { return d.String() }
+
+// ToDateString returns the date part of ToString
+func (d *Date) ToDateString() string {
+	if !d.valid {
+		return "Invalid Date"
+	}
+	return d.t.Format("Mon Jan 02 2006")
+}
+
+// ToLocaleDateString formats the date for a locale, e.g. "de-DE", with the options
+// weekday, year, month, day, dateStyle and timeZone of Intl.DateTimeFormat
+func (d *Date) ToLocaleDateString(args ...Object) (string, error) {
+	if !d.valid {
+		return "Invalid Date", nil
+	}
+
+	var locale, options Object = Undefined{}, Undefined{}
+	if len(args) > 0 {
+		locale = args[0]
+	}
+	if len(args) > 1 {
+		options = args[1]
+	}
+
+	format := dateFormatOptions(options)
+	t := d.t
+	if format.timeZone != "" {
+		loc, err := time.LoadLocation(format.timeZone)
+		if err != nil {
+			return "", errors.New("RangeError: Invalid time zone specified: " + format.timeZone)
+		}
+		t = t.In(loc)
+	}
+
+	return findDateLocale(locale).format(t, format), nil
+}
+
+// dateParseLayouts are the accepted layouts for new Date(string), without and with timezone
+var dateParseLayouts = []struct {
+	layout string
+	utc    bool
+}{
+	{"2006-01-02", true},
+	{"2006-01", true},
+	{"2006", true},
+	{time.RFC3339Nano, false},
+	{"2006-01-02T15:04:05.999999999", false},
+	{"2006-01-02T15:04", false},
+	{"2006-01-02 15:04:05", false},
+	{"Mon Jan 02 2006 15:04:05 GMT-0700", false},
+	{time.RFC1123Z, false},
+	{time.RFC1123, false},
+}
+
+// parseDate parses a date string, date-only forms are UTC and date-time forms without offset local time
+func parseDate(s string) *Date {
+	s = strings.TrimSpace(s)
+	// drop the timezone name of the toString format
+	if i := strings.Index(s, " ("); i > 0 && strings.HasSuffix(s, ")") {
+		s = s[:i]
+	}
+	for _, l := range dateParseLayouts {
+		loc := time.Local
+		if l.utc {
+			loc = time.UTC
+		}
+		if t, err := time.ParseInLocation(l.layout, s, loc); err == nil {
+			return NewDate(t)
+		}
+	}
+	return &Date{}
+}
+
+// runtimeNewDate implements new Date(...) with no arguments, a timestamp, a date string or a date,
+// or year, monthIndex[, day[, hours[, minutes[, seconds[, milliseconds]]]]] in local time
+func runtimeNewDate(args ...interface{}) *Date {
+	switch len(args) {
+	case 0:
+		return NewDate(time.Now())
+
+	case 1:
+		switch arg := convert(args[0]).(type) {
+		case *Date:
+			return arg.copy().(*Date)
+		case String:
+			return parseDate(string(arg))
+		default:
+			ms := toNumber(arg)
+			if math.IsNaN(ms) || math.IsInf(ms, 0) {
+				return &Date{}
+			}
+			return NewDate(time.UnixMilli(int64(ms)))
+		}
+	}
+
+	fields := [7]float64{0, 0, 1, 0, 0, 0, 0}
+	for i, arg := range args {
+		if i >= len(fields) {
+			break
+		}
+		fields[i] = toNumber(convert(arg))
+		if math.IsNaN(fields[i]) || math.IsInf(fields[i], 0) {
+			return &Date{}
+		}
+		fields[i] = math.Trunc(fields[i])
+	}
+	if fields[0] >= 0 && fields[0] <= 99 {
+		fields[0] += 1900
+	}
+
+	// time.Date normalizes overflowing values the same way as JavaScript
+	return NewDate(time.Date(
+		int(fields[0]), time.Month(fields[1]+1), int(fields[2]),
+		int(fields[3]), int(fields[4]), int(fields[5]), int(fields[6])*int(time.Millisecond),
+		time.Local,
+	))
+}
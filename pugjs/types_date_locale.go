@@ -0,0 +1,275 @@
+package pugjs
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	// dateFormat are the Intl.DateTimeFormat options supported by Date.toLocaleDateString
+	dateFormat struct {
+		weekday, year, month, day string
+		timeZone                  string
+	}
+
+	// datePart is a date component ('w'eekday, 'd'ay, 'm'onth or 'y'ear) followed by a separator
+	datePart struct {
+		field     byte
+		separator string
+	}
+
+	// dateLocale contains the names and layouts to format dates for a locale
+	dateLocale struct {
+		// numeric is the order of day, month and year for numeric months, e.g. "mdy"
+		numeric   string
+		separator string
+		// pad pads numeric days and months to two digits
+		pad bool
+		// textual is the layout for month names
+		textual       []datePart
+		months        [12]string
+		shortMonths   [12]string
+		weekdays      [7]string
+		shortWeekdays [7]string
+	}
+)
+
+var (
+	englishMonths        = [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"}
+	englishShortMonths   = [12]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"}
+	englishWeekdays      = [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"}
+	englishShortWeekdays = [7]string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
+)
+
+// dateLocales are the known locales by lower case language tag or language, en-US is the default
+var dateLocales = map[string]*dateLocale{
+	"en-us": {
+		numeric:       "mdy",
+		separator:     "/",
+		textual:       []datePart{{'w', ", "}, {'m', " "}, {'d', ", "}, {'y', ""}},
+		months:        englishMonths,
+		shortMonths:   englishShortMonths,
+		weekdays:      englishWeekdays,
+		shortWeekdays: englishShortWeekdays,
+	},
+	"en-gb": {
+		numeric:       "dmy",
+		separator:     "/",
+		pad:           true,
+		textual:       []datePart{{'w', " "}, {'d', " "}, {'m', " "}, {'y', ""}},
+		months:        englishMonths,
+		shortMonths:   englishShortMonths,
+		weekdays:      englishWeekdays,
+		shortWeekdays: englishShortWeekdays,
+	},
+	"de": {
+		numeric:       "dmy",
+		separator:     ".",
+		textual:       []datePart{{'w', ", "}, {'d', ". "}, {'m', " "}, {'y', ""}},
+		months:        [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		shortMonths:   [12]string{"Jan.", "Feb.", "März", "Apr.", "Mai", "Juni", "Juli", "Aug.", "Sept.", "Okt.", "Nov.", "Dez."},
+		weekdays:      [7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+		shortWeekdays: [7]string{"So.", "Mo.", "Di.", "Mi.", "Do.", "Fr.", "Sa."},
+	},
+	"fr": {
+		numeric:       "dmy",
+		separator:     "/",
+		pad:           true,
+		textual:       []datePart{{'w', " "}, {'d', " "}, {'m', " "}, {'y', ""}},
+		months:        [12]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		shortMonths:   [12]string{"janv.", "févr.", "mars", "avr.", "mai", "juin", "juil.", "août", "sept.", "oct.", "nov.", "déc."},
+		weekdays:      [7]string{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"},
+		shortWeekdays: [7]string{"dim.", "lun.", "mar.", "mer.", "jeu.", "ven.", "sam."},
+	},
+	"es": {
+		numeric:       "dmy",
+		separator:     "/",
+		textual:       []datePart{{'w', ", "}, {'d', " de "}, {'m', " de "}, {'y', ""}},
+		months:        [12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+		shortMonths:   [12]string{"ene", "feb", "mar", "abr", "may", "jun", "jul", "ago", "sept", "oct", "nov", "dic"},
+		weekdays:      [7]string{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"},
+		shortWeekdays: [7]string{"dom", "lun", "mar", "mié", "jue", "vie", "sáb"},
+	},
+	"it": {
+		numeric:       "dmy",
+		separator:     "/",
+		textual:       []datePart{{'w', " "}, {'d', " "}, {'m', " "}, {'y', ""}},
+		months:        [12]string{"gennaio", "febbraio", "marzo", "aprile", "maggio", "giugno", "luglio", "agosto", "settembre", "ottobre", "novembre", "dicembre"},
+		shortMonths:   [12]string{"gen", "feb", "mar", "apr", "mag", "giu", "lug", "ago", "set", "ott", "nov", "dic"},
+		weekdays:      [7]string{"domenica", "lunedì", "martedì", "mercoledì", "giovedì", "venerdì", "sabato"},
+		shortWeekdays: [7]string{"dom", "lun", "mar", "mer", "gio", "ven", "sab"},
+	},
+	"nl": {
+		numeric:       "dmy",
+		separator:     "-",
+		textual:       []datePart{{'w', " "}, {'d', " "}, {'m', " "}, {'y', ""}},
+		months:        [12]string{"januari", "februari", "maart", "april", "mei", "juni", "juli", "augustus", "september", "oktober", "november", "december"},
+		shortMonths:   [12]string{"jan", "feb", "mrt", "apr", "mei", "jun", "jul", "aug", "sep", "okt", "nov", "dec"},
+		weekdays:      [7]string{"zondag", "maandag", "dinsdag", "woensdag", "donderdag", "vrijdag", "zaterdag"},
+		shortWeekdays: [7]string{"zo", "ma", "di", "wo", "do", "vr", "za"},
+	},
+}
+
+// findDateLocale resolves a locale or list of locales, falling back to the language and then to en-US
+func findDateLocale(locales Object) *dateLocale {
+	var candidates []string
+	switch locales := locales.(type) {
+	case String:
+		candidates = []string{string(locales)}
+	case *Array:
+		for _, l := range locales.items {
+			candidates = append(candidates, l.String())
+		}
+	}
+
+	for _, c := range candidates {
+		tag := strings.ToLower(strings.ReplaceAll(c, "_", "-"))
+		if l, ok := dateLocales[tag]; ok {
+			return l
+		}
+		if i := strings.Index(tag, "-"); i > 0 {
+			tag = tag[:i]
+		}
+		if l, ok := dateLocales[tag]; ok {
+			return l
+		}
+		if tag == "en" {
+			return dateLocales["en-us"]
+		}
+	}
+	return dateLocales["en-us"]
+}
+
+// dateFormatOptions reads the options object of toLocaleDateString
+func dateFormatOptions(options Object) dateFormat {
+	option := func(name string) string {
+		if m, ok := options.(*Map); ok {
+			if v, ok := m.Member(name).(String); ok {
+				return string(v)
+			}
+		}
+		return ""
+	}
+
+	f := dateFormat{
+		weekday:  option("weekday"),
+		year:     option("year"),
+		month:    option("month"),
+		day:      option("day"),
+		timeZone: option("timeZone"),
+	}
+
+	switch option("dateStyle") {
+	case "full":
+		f.weekday, f.year, f.month, f.day = "long", "numeric", "long", "numeric"
+	case "long":
+		f.year, f.month, f.day = "numeric", "long", "numeric"
+	case "medium":
+		f.year, f.month, f.day = "numeric", "short", "numeric"
+	case "short":
+		f.year, f.month, f.day = "2-digit", "numeric", "numeric"
+	}
+
+	if f.weekday == "" && f.year == "" && f.month == "" && f.day == "" {
+		f.year, f.month, f.day = "numeric", "numeric", "numeric"
+	}
+	return f
+}
+
+// format a time with the given options
+func (l *dateLocale) format(t time.Time, f dateFormat) string {
+	weekday := ""
+	switch f.weekday {
+	case "long":
+		weekday = l.weekdays[t.Weekday()]
+	case "short":
+		weekday = l.shortWeekdays[t.Weekday()]
+	case "narrow":
+		weekday = string([]rune(l.weekdays[t.Weekday()])[:1])
+	}
+
+	year := ""
+	switch f.year {
+	case "numeric":
+		year = strconv.Itoa(t.Year())
+	case "2-digit":
+		year = twoDigits(t.Year() % 100)
+	}
+
+	day := ""
+	switch f.day {
+	case "numeric":
+		day = strconv.Itoa(t.Day())
+	case "2-digit":
+		day = twoDigits(t.Day())
+	}
+
+	var parts []datePart
+	switch f.month {
+	case "long", "short", "narrow":
+		month := l.months[t.Month()-1]
+		if f.month == "short" {
+			month = l.shortMonths[t.Month()-1]
+		} else if f.month == "narrow" {
+			month = string([]rune(month)[:1])
+		}
+		values := map[byte]string{'w': weekday, 'd': day, 'm': month, 'y': year}
+		for _, p := range l.textual {
+			if values[p.field] != "" {
+				parts = append(parts, datePart{separator: p.separator, field: p.field})
+			}
+		}
+		return joinDateParts(parts, values)
+
+	case "numeric", "2-digit":
+		month := strconv.Itoa(int(t.Month()))
+		if f.month == "2-digit" || l.pad {
+			month = twoDigits(int(t.Month()))
+		}
+		if f.day == "numeric" && l.pad {
+			day = twoDigits(t.Day())
+		}
+		values := map[byte]string{'d': day, 'm': month, 'y': year}
+		var numeric []string
+		for _, field := range []byte(l.numeric) {
+			if values[field] != "" {
+				numeric = append(numeric, values[field])
+			}
+		}
+		result := strings.Join(numeric, l.separator)
+		if weekday != "" {
+			result = weekday + ", " + result
+		}
+		return result
+	}
+
+	// no month, so the parts can not be combined in a meaningful way
+	var result []string
+	for _, v := range []string{weekday, day, year} {
+		if v != "" {
+			result = append(result, v)
+		}
+	}
+	return strings.Join(result, " ")
+}
+
+// twoDigits formats a day, month or year with a leading zero
+func twoDigits(n int) string {
+	if n < 10 {
+		return "0" + strconv.Itoa(n)
+	}
+	return strconv.Itoa(n)
+}
+
+// joinDateParts joins the values of the parts, the separator of a part is only used if another part follows
+func joinDateParts(parts []datePart, values map[byte]string) string {
+	var result strings.Builder
+	for i, p := range parts {
+		result.WriteString(values[p.field])
+		if i < len(parts)-1 {
+			result.WriteString(p.separator)
+		}
+	}
+	return result.String()
+}
@@ -0,0 +1,109 @@
+package pugjs
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+type (
+	// FieldNameResolver returns the names a struct field can be accessed by in templates,
+	// in addition to the Go field name, also with a lower case first letter
+	FieldNameResolver interface {
+		FieldNames(field reflect.StructField) []string
+	}
+
+	// TagFieldNameResolver resolves the name of the pug and json struct tags, and the camel case Go name,
+	// e.g. SKU becomes sku
+	TagFieldNameResolver struct{}
+)
+
+// fieldNames caches the resolved field names per struct type, mapping the names to the map keys of the fields
+type fieldNames struct {
+	resolver FieldNameResolver
+	mu       sync.RWMutex
+	types    map[reflect.Type]map[string]string
+}
+
+// defaultFieldNames resolve the names of structs converted without an engine
+var defaultFieldNames = newFieldNames(nil)
+
+// newFieldNames with the resolver, nil uses the TagFieldNameResolver
+func newFieldNames(resolver FieldNameResolver) *fieldNames {
+	if resolver == nil {
+		resolver = TagFieldNameResolver{}
+	}
+	return &fieldNames{resolver: resolver, types: make(map[reflect.Type]map[string]string)}
+}
+
+// FieldNames of the field
+func (TagFieldNameResolver) FieldNames(field reflect.StructField) []string {
+	var names []string
+	for _, tag := range []string{"pug", "json"} {
+		name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+		if name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	return append(names, camelCase(field.Name))
+}
+
+// camelCase converts a Go name to a JavaScript name, initialisms are handled as words,
+// e.g. HTTPStatus becomes httpStatus and ProductID becomes productId
+func camelCase(name string) string {
+	runes := []rune(name)
+	result := make([]rune, len(runes))
+	for i, r := range runes {
+		// a letter is upper case if it starts a word, which is after a lower case letter
+		// or the last upper case letter before a lower case letter
+		wordStart := i > 0 && unicode.IsUpper(r) &&
+			(!unicode.IsUpper(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1])))
+		if wordStart {
+			result[i] = r
+		} else {
+			result[i] = unicode.ToLower(r)
+		}
+	}
+	return string(result)
+}
+
+// key returns the map key of the struct field with the resolved name
+func (f *fieldNames) key(o interface{}, name string) (string, bool) {
+	typ := reflect.TypeOf(o)
+	if v, ok := o.(reflect.Value); ok {
+		typ = v.Type()
+	}
+	if typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return "", false
+	}
+
+	f.mu.RLock()
+	names, ok := f.types[typ]
+	f.mu.RUnlock()
+
+	if !ok {
+		f.mu.Lock()
+		names = make(map[string]string)
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			// the Go field name works as well, e.g. PI of a Math struct
+			for _, n := range append(f.resolver.FieldNames(field), field.Name) {
+				if _, exists := names[n]; !exists {
+					names[n] = lowerFirst(field.Name)
+				}
+			}
+		}
+		f.types[typ] = names
+		f.mu.Unlock()
+	}
+
+	key, ok := names[name]
+	return key, ok
+}
@@ -0,0 +1,107 @@
+package pugjs
+
+import (
+	"context"
+	"reflect"
+)
+
+// Iterator is a lazily consumed sequence, converted from a receive channel, an iter.Seq or an iter.Seq2.
+// It is iterated by each and for of, channels can only be iterated once.
+type Iterator struct {
+	o   interface{}
+	seq func(ctx context.Context, yield func(key, value Object) bool) error
+}
+
+// Member getter
+func (it *Iterator) Member(string) Object { return Undefined{} }
+
+// String formatter
+func (it *Iterator) String() string { return "[object Iterator]" }
+
+// True getter, iterators are always truthy
+func (it *Iterator) True() bool { return true }
+
+func (it *Iterator) copy() Object       { return it }
+func (it *Iterator) iface() interface{} { return it.o }
+
+// Iterate calls yield for all keys and values until yield returns false. The iteration stops with the error
+// of the context if it is done.
+func (it *Iterator) Iterate(ctx context.Context, yield func(key, value Object) bool) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return it.seq(ctx, yield)
+}
+
+// values collects all values of the iterator, until the context is done
+func (it *Iterator) values(ctx context.Context) ([]Object, error) {
+	var res []Object
+	err := it.Iterate(ctx, func(_, value Object) bool {
+		res = append(res, value)
+		return true
+	})
+	return res, err
+}
+
+var boolType = reflect.TypeOf(true)
+
+// chanIterator receives the values of the channel, keyed by their index
+func (c *converter) chanIterator(ch reflect.Value, depth int) *Iterator {
+	return &Iterator{
+		o: ch.Interface(),
+		seq: func(ctx context.Context, yield func(key, value Object) bool) error {
+			cases := []reflect.SelectCase{
+				{Dir: reflect.SelectRecv, Chan: ch},
+				{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+			}
+			for i := 0; ; i++ {
+				chosen, value, ok := reflect.Select(cases)
+				if chosen == 1 {
+					return ctx.Err()
+				}
+				if !ok || !yield(Number(i), c.convert(value, depth+1)) {
+					return nil
+				}
+			}
+		},
+	}
+}
+
+// seqType checks if the function type has the shape of an iter.Seq, func(yield func(V) bool), or of an iter.Seq2,
+// func(yield func(K, V) bool), and returns the number of yielded values
+func seqType(typ reflect.Type) int {
+	if typ.NumIn() != 1 || typ.NumOut() != 0 || typ.IsVariadic() || typ.In(0).Kind() != reflect.Func {
+		return 0
+	}
+	yield := typ.In(0)
+	if yield.Name() != "" || yield.IsVariadic() || yield.NumIn() < 1 || yield.NumIn() > 2 ||
+		yield.NumOut() != 1 || yield.Out(0) != boolType {
+		return 0
+	}
+	return yield.NumIn()
+}
+
+// seqIterator calls the iter.Seq or iter.Seq2 function, iter.Seq values are keyed by their index
+func (c *converter) seqIterator(fn reflect.Value, depth int) *Iterator {
+	yieldType := fn.Type().In(0)
+	return &Iterator{
+		o: fn.Interface(),
+		seq: func(ctx context.Context, yield func(key, value Object) bool) error {
+			var err error
+			i := 0
+			fn.Call([]reflect.Value{reflect.MakeFunc(yieldType, func(args []reflect.Value) []reflect.Value {
+				more := false
+				if err = ctx.Err(); err == nil {
+					if len(args) == 2 {
+						more = yield(c.convert(args[0], depth+1), c.convert(args[1], depth+1))
+					} else {
+						more = yield(Number(i), c.convert(args[0], depth+1))
+					}
+					i++
+				}
+				return []reflect.Value{reflect.ValueOf(more)}
+			})})
+			return err
+		},
+	}
+}
@@ -0,0 +1,97 @@
+package pugjs
+
+import (
+	"encoding/json"
+)
+
+// jsonEncoder prepares Objects for encoding/json. Nested maps and arrays are encoded by the same encoder,
+// so reference cycles are detected, and the render options apply to the whole value.
+type jsonEncoder struct {
+	options RenderOptions
+	active  map[interface{}]bool
+}
+
+// MarshalJSON encodes an Object as JSON with the render options, references to an object which is being
+// encoded are encoded as "[Circular]". Other values are encoded by encoding/json.
+func MarshalJSON(v interface{}, options RenderOptions) ([]byte, error) {
+	o, ok := v.(Object)
+	if !ok {
+		return json.Marshal(v)
+	}
+	return json.Marshal((&jsonEncoder{options: options}).value(o, 0))
+}
+
+func (c *converter) marshalJSON(o Object) ([]byte, error) {
+	var options RenderOptions
+	if c != nil {
+		options = c.options
+	}
+	return MarshalJSON(o, options)
+}
+
+// enter marks the object as being encoded, and reports false for cycles
+func (e *jsonEncoder) enter(key interface{}) bool {
+	if e.active[key] {
+		return false
+	}
+	if e.active == nil {
+		e.active = make(map[interface{}]bool)
+	}
+	e.active[key] = true
+	return true
+}
+
+func (e *jsonEncoder) value(o Object, depth int) interface{} {
+	switch o := o.(type) {
+	case *Map:
+		if s, ok := o.o.(json.Marshaler); ok {
+			return s
+		}
+		if e.options.MaxDepth > 0 && depth > e.options.MaxDepth {
+			return nil
+		}
+		// maps of the same struct pointer are the same object
+		var key interface{} = o
+		if o.ptr.IsValid() {
+			key = identityOf(o.ptr)
+		}
+		if !e.enter(key) {
+			return "[Circular]"
+		}
+		defer delete(e.active, key)
+
+		o.convert()
+		tmp := make(map[string]interface{}, len(o.items))
+		for k, v := range o.items {
+			if _, ok := v.(Undefined); ok {
+				continue
+			}
+			tmp[lowerFirst(k)] = e.value(v, depth+1)
+		}
+		return tmp
+
+	case *Array:
+		if e.options.MaxDepth > 0 && depth > e.options.MaxDepth {
+			return nil
+		}
+		if !e.enter(o) {
+			return "[Circular]"
+		}
+		defer delete(e.active, o)
+
+		tmp := make([]interface{}, len(o.items))
+		for i, v := range o.items {
+			tmp[i] = e.value(v, depth+1)
+		}
+		return tmp
+
+	case *Func:
+		// getters are encoded by their value
+		if o.fnc.Type().NumIn() == 0 && o.fnc.Type().NumOut() == 1 && !e.options.SkipGetters {
+			return e.value(convert(o.fnc.Call(nil)[0]), depth)
+		}
+		// return function name as string, to avoid circular calls
+		return o.String()
+	}
+	return o
+}
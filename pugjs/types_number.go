@@ -0,0 +1,245 @@
+package pugjs
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// numberToString implements the ECMAScript Number::toString abstract operation
+func numberToString(x float64) string {
+	switch {
+	case math.IsNaN(x):
+		return "NaN"
+	case x == 0:
+		// -0 is "0" as well
+		return "0"
+	case x < 0:
+		return "-" + numberToString(-x)
+	case math.IsInf(x, 1):
+		return "Infinity"
+	}
+
+	// the shortest digits which round-trip, and n so that the value is 0.digits * 10^n
+	e := strconv.FormatFloat(x, 'e', -1, 64)
+	mantissa, exponent := e[:strings.IndexByte(e, 'e')], e[strings.IndexByte(e, 'e')+1:]
+	digits := strings.Replace(mantissa, ".", "", 1)
+	k := len(digits)
+	n, _ := strconv.Atoi(exponent)
+	n++
+
+	switch {
+	case k <= n && n <= 21:
+		return digits + strings.Repeat("0", n-k)
+	case 0 < n && n <= 21:
+		return digits[:n] + "." + digits[n:]
+	case -6 < n && n <= 0:
+		return "0." + strings.Repeat("0", -n) + digits
+	}
+
+	sign := "+"
+	if n-1 < 0 {
+		sign = "-"
+	}
+	exp := strconv.Itoa(abs(n - 1))
+	if k == 1 {
+		return digits + "e" + sign + exp
+	}
+	return digits[:1] + "." + digits[1:] + "e" + sign + exp
+}
+
+// ToString formats the number in the given radix, like Number.prototype.toString
+func (n Number) ToString(radix ...interface{}) (string, error) {
+	r, err := digitsArgument(radix, 10, 2, 36)
+	if err != nil {
+		return "", errors.New("RangeError: toString() radix must be between 2 and 36")
+	}
+	x := float64(n)
+	if r == 10 || math.IsNaN(x) || math.IsInf(x, 0) || x == 0 {
+		return n.String(), nil
+	}
+	return radixString(x, r), nil
+}
+
+// radixString formats a finite number in a radix other than 10,
+// the fraction gets as many digits as needed to distinguish it from the neighbouring float64 values
+func radixString(x float64, radix int) string {
+	sign := ""
+	if x < 0 {
+		sign = "-"
+		x = -x
+	}
+
+	integer := math.Floor(x)
+	fraction := x - integer
+	i, _ := new(big.Float).SetFloat64(integer).Int(nil)
+
+	var digits []byte
+	delta := math.Max(0.5*(math.Nextafter(x, math.Inf(1))-x), math.SmallestNonzeroFloat64)
+	if fraction >= delta {
+		for {
+			fraction *= float64(radix)
+			delta *= float64(radix)
+			digit := int(fraction)
+			digits = append(digits, byte(digit))
+			fraction -= float64(digit)
+			if fraction > 0.5 || (fraction == 0.5 && digit&1 == 1) {
+				if fraction+delta > 1 {
+					// round up, and carry into the integer part if needed
+					for {
+						last := len(digits) - 1
+						if last < 0 {
+							i.Add(i, big.NewInt(1))
+							break
+						}
+						if int(digits[last])+1 < radix {
+							digits[last]++
+							break
+						}
+						digits = digits[:last]
+					}
+					break
+				}
+			}
+			if fraction < delta {
+				break
+			}
+		}
+	}
+
+	result := sign + i.Text(radix)
+	if len(digits) > 0 {
+		const chars = "0123456789abcdefghijklmnopqrstuvwxyz"
+		result += "."
+		for _, d := range digits {
+			result += string(chars[d])
+		}
+	}
+	return result
+}
+
+// ToFixed formats the number with a fixed number of decimals, like Number.prototype.toFixed
+func (n Number) ToFixed(fractionDigits ...interface{}) (string, error) {
+	digits, err := digitsArgument(fractionDigits, 0, 0, 100)
+	if err != nil {
+		return "", err
+	}
+
+	x := float64(n)
+	if math.IsNaN(x) || math.IsInf(x, 0) || math.Abs(x) >= 1e21 {
+		return n.String(), nil
+	}
+
+	s := roundDecimal(x, digits).String()
+	if digits > 0 {
+		if len(s) <= digits {
+			s = strings.Repeat("0", digits-len(s)+1) + s
+		}
+		s = s[:len(s)-digits] + "." + s[len(s)-digits:]
+	}
+	if x < 0 {
+		s = "-" + s
+	}
+	return s, nil
+}
+
+// ToPrecision formats the number with the given number of significant digits, like Number.prototype.toPrecision
+func (n Number) ToPrecision(precision ...interface{}) (string, error) {
+	if len(precision) == 0 || isUndefined(precision[0]) {
+		return n.String(), nil
+	}
+	p, err := digitsArgument(precision, 0, 1, 100)
+	if err != nil {
+		return "", err
+	}
+
+	x := float64(n)
+	if math.IsNaN(x) || math.IsInf(x, 0) {
+		return n.String(), nil
+	}
+
+	sign := ""
+	if x < 0 {
+		sign = "-"
+	}
+
+	var digits string
+	e := 0
+	if x == 0 {
+		digits = strings.Repeat("0", p)
+	} else {
+		e = int(math.Floor(math.Log10(math.Abs(x))))
+		digits = roundDecimal(x, p-1-e).String()
+		// the estimated exponent can be off by one, because of rounding or log10 precision
+		if len(digits) > p {
+			e++
+			digits = roundDecimal(x, p-1-e).String()
+		} else if len(digits) < p {
+			e--
+			digits = roundDecimal(x, p-1-e).String()
+		}
+	}
+
+	if e < -6 || e >= p {
+		mantissa := digits[:1]
+		if p > 1 {
+			mantissa += "." + digits[1:]
+		}
+		exponent := "+"
+		if e < 0 {
+			exponent = "-"
+		}
+		return sign + mantissa + "e" + exponent + Number(math.Abs(float64(e))).String(), nil
+	}
+
+	if e == p-1 {
+		return sign + digits, nil
+	}
+	if e >= 0 {
+		return sign + digits[:e+1] + "." + digits[e+1:], nil
+	}
+	return sign + "0." + strings.Repeat("0", -(e+1)) + digits, nil
+}
+
+// roundDecimal returns the integer closest to |x| * 10^scale, ties are rounded up
+func roundDecimal(x float64, scale int) *big.Int {
+	r := new(big.Rat).SetFloat64(math.Abs(x))
+	pow := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(scale))), nil)
+	if scale >= 0 {
+		r.Mul(r, new(big.Rat).SetInt(pow))
+	} else {
+		r.Quo(r, new(big.Rat).SetInt(pow))
+	}
+	r.Add(r, big.NewRat(1, 2))
+	return new(big.Int).Quo(r.Num(), r.Denom())
+}
+
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
+
+// digitsArgument reads an optional integer argument in the range [min, max]
+func digitsArgument(args []interface{}, def, min, max int) (int, error) {
+	if len(args) == 0 || isUndefined(args[0]) {
+		return def, nil
+	}
+	f := toNumber(convert(args[0]))
+	if math.IsNaN(f) {
+		f = 0
+	}
+	f = math.Trunc(f)
+	if f < float64(min) || f > float64(max) {
+		return 0, errors.New("RangeError: digits argument must be between " + Number(min).String() + " and " + Number(max).String())
+	}
+	return int(f), nil
+}
+
+func isUndefined(x interface{}) bool {
+	_, ok := convert(x).(Undefined)
+	return ok
+}
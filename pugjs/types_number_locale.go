@@ -0,0 +1,279 @@
+package pugjs
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/big"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/currency"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/number"
+)
+
+type (
+	// NumberFormat formats numbers for a locale, like Intl.NumberFormat
+	NumberFormat struct {
+		tag             language.Tag
+		style           string
+		currency        currency.Unit
+		currencyDisplay string
+		minFraction     int
+		maxFraction     int
+		minInteger      int
+		grouping        bool
+	}
+
+	// currencyLayout describes where a locale puts the currency symbol
+	currencyLayout struct {
+		suffix bool
+		space  bool
+	}
+)
+
+// currencyLayouts by language or language and region, all other locales put the symbol in front of the amount
+var currencyLayouts = map[string]currencyLayout{
+	"de":    {suffix: true, space: true},
+	"de-AT": {space: true},
+	"de-CH": {space: true},
+	"fr":    {suffix: true, space: true},
+	"es":    {suffix: true, space: true},
+	"es-MX": {},
+	"es-US": {},
+	"it":    {suffix: true, space: true},
+	"it-CH": {space: true},
+	"nl":    {space: true},
+	"pt":    {suffix: true, space: true},
+	"pt-BR": {space: true},
+	"pl":    {suffix: true, space: true},
+	"cs":    {suffix: true, space: true},
+	"sk":    {suffix: true, space: true},
+	"sv":    {suffix: true, space: true},
+	"da":    {suffix: true, space: true},
+	"nb":    {suffix: true, space: true},
+	"fi":    {suffix: true, space: true},
+	"ru":    {suffix: true, space: true},
+	"uk":    {suffix: true, space: true},
+	"hu":    {suffix: true, space: true},
+	"ro":    {suffix: true, space: true},
+	"el":    {suffix: true, space: true},
+	"hr":    {suffix: true, space: true},
+	"bg":    {suffix: true, space: true},
+}
+
+// WithLocale sets the locale used by toLocaleString and the number format template functions
+func WithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, LocaleKey, locale)
+}
+
+// LocaleFromContext returns the locale of the context, or an empty string
+func LocaleFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	locale, _ := ctx.Value(LocaleKey).(string)
+	return locale
+}
+
+// NewNumberFormat creates a number format for the locale with the Intl.NumberFormat options style, currency,
+// currencyDisplay, minimumIntegerDigits, minimumFractionDigits, maximumFractionDigits and useGrouping
+func NewNumberFormat(locale string, options Object) (*NumberFormat, error) {
+	m, _ := options.(*Map)
+	option := func(name string) Object {
+		if m == nil {
+			return Undefined{}
+		}
+		return m.Member(name)
+	}
+	defined := func(o Object) bool {
+		_, undefined := o.(Undefined)
+		return !undefined
+	}
+
+	tag := language.English
+	if locale != "" {
+		tag = language.Make(locale)
+	}
+	f := &NumberFormat{tag: tag, style: "decimal", currencyDisplay: "symbol", minInteger: 1, grouping: true}
+
+	if style := option("style"); defined(style) {
+		f.style = style.String()
+	}
+
+	minDefault, maxDefault := 0, 3
+	switch f.style {
+	case "decimal":
+	case "percent":
+		maxDefault = 0
+	case "currency":
+		code := option("currency")
+		if !defined(code) {
+			return nil, errors.New("TypeError: Currency code is required with currency style")
+		}
+		unit, err := currency.ParseISO(code.String())
+		if err != nil {
+			return nil, errors.New("RangeError: Invalid currency code: " + code.String())
+		}
+		f.currency = unit
+		scale, _ := currency.Standard.Rounding(unit)
+		minDefault, maxDefault = scale, scale
+		if display := option("currencyDisplay"); defined(display) {
+			f.currencyDisplay = display.String()
+		}
+	default:
+		return nil, errors.New("RangeError: Value " + f.style + " out of range for NumberFormat options property style")
+	}
+
+	var err error
+	if f.minInteger, err = digitsOption(option("minimumIntegerDigits"), 1, 1, 21); err != nil {
+		return nil, err
+	}
+	minFraction, maxFraction := option("minimumFractionDigits"), option("maximumFractionDigits")
+	if f.minFraction, err = digitsOption(minFraction, minDefault, 0, 100); err != nil {
+		return nil, err
+	}
+	if !defined(maxFraction) && f.minFraction > maxDefault {
+		maxDefault = f.minFraction
+	}
+	if f.maxFraction, err = digitsOption(maxFraction, maxDefault, 0, 100); err != nil {
+		return nil, err
+	}
+	if !defined(minFraction) && f.minFraction > f.maxFraction {
+		f.minFraction = f.maxFraction
+	}
+	if f.minFraction > f.maxFraction {
+		return nil, errors.New("RangeError: maximumFractionDigits value is out of range")
+	}
+
+	if grouping := option("useGrouping"); defined(grouping) {
+		f.grouping = toBoolean(grouping)
+	}
+
+	return f, nil
+}
+
+// digitsOption reads an optional integer option in the range [min, max]
+func digitsOption(o Object, def, min, max int) (int, error) {
+	return digitsArgument([]interface{}{o}, def, min, max)
+}
+
+// Format a number
+func (f *NumberFormat) Format(x float64) string {
+	switch {
+	case math.IsNaN(x):
+		return "NaN"
+	case math.IsInf(x, 1):
+		return "∞"
+	case math.IsInf(x, -1):
+		return "-∞"
+	}
+
+	printer := message.NewPrinter(f.tag)
+	opts := []number.Option{
+		number.MinIntegerDigits(f.minInteger),
+		number.MinFractionDigits(f.minFraction),
+		number.MaxFractionDigits(f.maxFraction),
+	}
+	if !f.grouping {
+		opts = append(opts, number.NoSeparator())
+	}
+
+	switch f.style {
+	case "percent":
+		return printer.Sprint(number.Percent(roundHalfExpand(x*100, f.maxFraction)/100, opts...))
+
+	case "currency":
+		amount := printer.Sprint(number.Decimal(math.Abs(roundHalfExpand(x, f.maxFraction)), opts...))
+		sign := ""
+		if x < 0 && roundHalfExpand(x, f.maxFraction) != 0 {
+			sign = "-"
+		}
+		return sign + f.currencyAmount(printer, amount)
+	}
+
+	return printer.Sprint(number.Decimal(roundHalfExpand(x, f.maxFraction), opts...))
+}
+
+// currencyAmount adds the currency symbol to a formatted amount
+func (f *NumberFormat) currencyAmount(printer *message.Printer, amount string) string {
+	var symbol string
+	switch f.currencyDisplay {
+	case "code":
+		symbol = f.currency.String()
+	case "narrowSymbol":
+		symbol = printer.Sprint(currency.NarrowSymbol(f.currency))
+	default:
+		symbol = printer.Sprint(currency.Symbol(f.currency))
+	}
+
+	base, _ := f.tag.Base()
+	region, _ := f.tag.Region()
+	layout, ok := currencyLayouts[base.String()+"-"+region.String()]
+	if !ok {
+		layout = currencyLayouts[base.String()]
+	}
+
+	// codes like CHF are always separated from the amount
+	if last, _ := utf8.DecodeLastRuneInString(symbol); unicode.IsLetter(last) {
+		layout.space = true
+	}
+	space := ""
+	if layout.space {
+		space = "\u00a0"
+	}
+
+	if layout.suffix {
+		return amount + space + symbol
+	}
+	return symbol + space + amount
+}
+
+// roundHalfExpand rounds to the given number of decimals, ties are rounded away from zero.
+// Unlike toFixed it rounds the shortest decimal form of x, so 1.005 rounds to 1.01 like in browsers.
+func roundHalfExpand(x float64, decimals int) float64 {
+	r, ok := new(big.Rat).SetString(strconv.FormatFloat(math.Abs(x), 'f', -1, 64))
+	if !ok {
+		return x
+	}
+	pow := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil))
+	r.Mul(r, pow)
+	r.Add(r, big.NewRat(1, 2))
+	r.SetInt(new(big.Int).Quo(r.Num(), r.Denom()))
+	r.Quo(r, pow)
+	f, _ := r.Float64()
+	if x < 0 {
+		return -f
+	}
+	return f
+}
+
+// ToLocaleString formats the number for a locale with the options of Intl.NumberFormat,
+// the locale defaults to the locale of the render context
+func (n Number) ToLocaleString(ctx context.Context, args ...Object) (string, error) {
+	locale := LocaleFromContext(ctx)
+	if len(args) > 0 {
+		switch l := args[0].(type) {
+		case String:
+			locale = string(l)
+		case *Array:
+			if len(l.items) > 0 {
+				locale = l.items[0].String()
+			}
+		}
+	}
+
+	var options Object = Undefined{}
+	if len(args) > 1 {
+		options = args[1]
+	}
+
+	f, err := NewNumberFormat(locale, options)
+	if err != nil {
+		return "", err
+	}
+	return f.Format(float64(n)), nil
+}
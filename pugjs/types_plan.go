@@ -0,0 +1,115 @@
+package pugjs
+
+import (
+	"reflect"
+	"sync"
+)
+
+type (
+	// conversionPlan lists the members of a struct, or a pointer to a struct, as they are converted to a Map
+	conversionPlan struct {
+		members []planMember
+		keys    map[string]int
+	}
+
+	// planMember is a struct field or a method of the receiver
+	planMember struct {
+		key   string
+		field bool
+		index int
+	}
+)
+
+// conversionPlans caches the plans per type, so renders do not inspect the same types over and over again
+var conversionPlans sync.Map
+
+// planFor returns the conversion plan of a struct or pointer to struct type,
+// methods override fields with the same name
+func planFor(typ reflect.Type) *conversionPlan {
+	if p, ok := conversionPlans.Load(typ); ok {
+		return p.(*conversionPlan)
+	}
+
+	structType := typ
+	if typ.Kind() == reflect.Ptr {
+		structType = typ.Elem()
+	}
+
+	p := &conversionPlan{keys: make(map[string]int, structType.NumField()+typ.NumMethod())}
+	add := func(m planMember) {
+		if i, ok := p.keys[m.key]; ok {
+			p.members[i] = m
+			return
+		}
+		p.keys[m.key] = len(p.members)
+		p.members = append(p.members, m)
+	}
+
+	for i := 0; i < structType.NumField(); i++ {
+		if structType.Field(i).IsExported() {
+			add(planMember{key: lowerFirst(structType.Field(i).Name), field: true, index: i})
+		}
+	}
+	for i := 0; i < typ.NumMethod(); i++ {
+		add(planMember{key: lowerFirst(typ.Method(i).Name), index: i})
+	}
+
+	actual, _ := conversionPlans.LoadOrStore(typ, p)
+	return actual.(*conversionPlan)
+}
+
+// value converts the member of the struct value or the receiver of the methods
+func (pm planMember) value(c *converter, depth int, val, receiver reflect.Value) Object {
+	if pm.field {
+		return c.convert(val.Field(pm.index), depth)
+	}
+	return c.convert(receiver.Method(pm.index), depth)
+}
+
+// structValue returns the struct and the receiver for its methods of a struct backed map
+func (m *Map) structValue() (val, receiver reflect.Value, ok bool) {
+	val, isValue := m.o.(reflect.Value)
+	if !isValue {
+		val = reflect.ValueOf(m.o)
+	}
+	receiver = val
+	if m.ptr.IsValid() {
+		receiver = m.ptr
+	}
+	if val.Kind() == reflect.Ptr && !val.IsNil() {
+		receiver = val
+		val = val.Elem()
+	}
+	return val, receiver, val.Kind() == reflect.Struct
+}
+
+// item returns the item of the map. Struct members are converted on access until the whole map is needed.
+func (m *Map) item(key string) (Object, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.items == nil {
+		if m.source != nil {
+			return m.sourceItem(key)
+		}
+		if val, receiver, ok := m.structValue(); ok {
+			plan := planFor(receiver.Type())
+			i, ok := plan.keys[key]
+			if !ok {
+				return nil, false
+			}
+			if item, ok := m.lazy[key]; ok {
+				return item, true
+			}
+			if m.lazy == nil {
+				m.lazy = make(map[string]Object)
+			}
+			item := plan.members[i].value(m.conv, m.depth+1, val, receiver)
+			m.lazy[key] = item
+			return item, true
+		}
+		m.convertLocked()
+	}
+
+	item, ok := m.items[key]
+	return item, ok
+}
@@ -0,0 +1,63 @@
+package pugjs
+
+import (
+	"reflect"
+	"strings"
+)
+
+type (
+	// PrototypeFunc adds a method to the prototype of a JavaScript type. Modules bind it by type and method name:
+	//   injector.BindMap((*pugjs.PrototypeFunc)(nil), "Number.format").To(FormatPrice{})
+	// Supported types are String, Number, Array and Map, built-in methods and map keys take precedence.
+	PrototypeFunc interface {
+		// Func returns the method for the value it is called on, the method may take a context.Context
+		// as first argument to get the render context
+		Func(this Object) interface{}
+	}
+
+	prototypeFuncProvider func() map[string]PrototypeFunc
+)
+
+// newPrototypes returns the prototype methods by type and name, the funcs are keyed by type and method name,
+// e.g. "String.format"
+func newPrototypes(funcs map[string]PrototypeFunc) map[string]map[string]PrototypeFunc {
+	prototypes := make(map[string]map[string]PrototypeFunc)
+	for k, f := range funcs {
+		typ, name, ok := strings.Cut(k, ".")
+		if !ok {
+			panicOrError("prototype method '" + k + "' must be bound as Type.method")
+			continue
+		}
+		if prototypes[typ] == nil {
+			prototypes[typ] = make(map[string]PrototypeFunc)
+		}
+		prototypes[typ][name] = f
+	}
+	return prototypes
+}
+
+// member returns the member name of the object, the prototype methods of String, Number, Array and Map
+// are looked up after the built-in methods and map keys
+func (t *objectTypes) member(obj Object, name string) Object {
+	if t == nil || len(t.prototypes) == 0 {
+		return obj.Member(name)
+	}
+
+	switch o := obj.(type) {
+	case *Array:
+		if m, ok := o.method(name); ok {
+			return m
+		}
+	case *Map, String, Number:
+		if m := obj.Member(name); !isUndefined(m) {
+			return m
+		}
+	default:
+		return obj.Member(name)
+	}
+
+	if f, ok := t.prototypes[objectTypeName(obj)][name]; ok {
+		return &Func{fnc: reflect.ValueOf(f.Func(obj))}
+	}
+	return obj.Member(name)
+}
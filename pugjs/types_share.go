@@ -0,0 +1,66 @@
+package pugjs
+
+// share returns a copy-on-write view of converted data, so concurrent renders can share the data: maps read
+// through to the shared map until they are modified or iterated, arrays are copied with views of their items.
+// Modifications of one render neither leak into other renders nor race with them.
+func share(o Object) Object {
+	return shareWith(o, make(map[*Array]*Array))
+}
+
+func shareWith(o Object, arrays map[*Array]*Array) Object {
+	switch o := o.(type) {
+	case *Map:
+		return &Map{o: o.o, ptr: o.ptr, conv: o.conv, depth: o.depth, source: o}
+
+	case *Array:
+		// arrays containing themselves share one copy
+		if c, ok := arrays[o]; ok {
+			return c
+		}
+		c := &Array{items: make([]Object, len(o.items)), o: o.o, conv: o.conv}
+		arrays[o] = c
+		for i, item := range o.items {
+			c.items[i] = shareWith(item, arrays)
+		}
+		return c
+	}
+
+	return o
+}
+
+// sourceItem returns the item of the shared map as view, views of items are kept in lazy so they are modified in place
+func (m *Map) sourceItem(key string) (Object, bool) {
+	if item, ok := m.lazy[key]; ok {
+		return item, true
+	}
+	item, ok := m.source.item(key)
+	if !ok {
+		return nil, false
+	}
+	if m.lazy == nil {
+		m.lazy = make(map[string]Object)
+	}
+	item = share(item)
+	m.lazy[key] = item
+	return item, true
+}
+
+// copySource copies the items of the shared map before the view is modified or iterated, the map must be locked
+func (m *Map) copySource() {
+	m.source.mu.Lock()
+	m.source.convertLocked()
+	items, order := m.source.items, m.source.order
+	m.source.mu.Unlock()
+
+	m.items = make(map[string]Object, len(items))
+	for key, item := range items {
+		if viewed, ok := m.lazy[key]; ok {
+			m.items[key] = viewed
+			continue
+		}
+		m.items[key] = share(item)
+	}
+	m.order = append([]string(nil), order...)
+	m.lazy = nil
+	m.source = nil
+}
@@ -1,9 +1,17 @@
 package pugjs
 
 import (
+	"context"
+	"io"
+	"math"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"flamingo.me/flamingo/v3/framework/flamingo"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNil(t *testing.T) {
@@ -11,18 +19,39 @@ func TestNil(t *testing.T) {
 
 	assert.Equal(t, false, n.True())
 	assert.Equal(t, "", n.String())
-	assert.Equal(t, Nil{}, n.Member(""))
-	assert.Equal(t, Nil{}, n.Member("aaa"))
+	assert.Equal(t, Undefined{}, n.Member(""))
+	assert.Equal(t, Undefined{}, n.Member("aaa"))
 	assert.Equal(t, Nil{}, n.copy())
 }
 
+func TestUndefined(t *testing.T) {
+	u := Undefined{}
+
+	assert.Equal(t, false, u.True())
+	assert.Equal(t, "", u.String())
+	assert.Equal(t, Undefined{}, u.Member("aaa"))
+	assert.Equal(t, Undefined{}, u.copy())
+
+	m := convert(map[string]interface{}{"a": 1}).(*Map)
+	assert.Equal(t, Undefined{}, m.Member("missing"))
+	m.Assign("b", Undefined{})
+	m.Assign("c", Nil{})
+	b, err := m.MarshalJSON()
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"a":1,"c":null}`, string(b))
+
+	b, err = convert([]Object{Number(1), Undefined{}}).(*Array).MarshalJSON()
+	assert.NoError(t, err)
+	assert.Equal(t, `[1,null]`, string(b))
+}
+
 func TestBool(t *testing.T) {
 	t.Run("true", func(t *testing.T) {
 		b := Bool(true)
 		assert.Equal(t, true, b.True())
 		assert.Equal(t, "true", b.String())
-		assert.Equal(t, Nil{}, b.Member(""))
-		assert.Equal(t, Nil{}, b.Member("aaa"))
+		assert.Equal(t, Undefined{}, b.Member(""))
+		assert.Equal(t, Undefined{}, b.Member("aaa"))
 		assert.Equal(t, Bool(true), b.copy())
 	})
 
@@ -30,8 +59,8 @@ func TestBool(t *testing.T) {
 		b := Bool(false)
 		assert.Equal(t, false, b.True())
 		assert.Equal(t, "false", b.String())
-		assert.Equal(t, Nil{}, b.Member(""))
-		assert.Equal(t, Nil{}, b.Member("aaa"))
+		assert.Equal(t, Undefined{}, b.Member(""))
+		assert.Equal(t, Undefined{}, b.Member("aaa"))
 		assert.Equal(t, Bool(false), b.copy())
 	})
 }
@@ -44,12 +73,192 @@ func TestNumber(t *testing.T) {
 	assert.Equal(t, "0", Number(0).String())
 	assert.Equal(t, "-1", Number(-1).String())
 
-	assert.Equal(t, Nil{}, n.Member(""))
-	assert.Equal(t, Nil{}, n.Member("aaa"))
+	assert.Equal(t, Undefined{}, n.Member(""))
+	assert.Equal(t, Undefined{}, n.Member("aaa"))
 
 	assert.Equal(t, n, n.copy())
 }
 
+func TestNumber_String(t *testing.T) {
+	a, b := 0.1, 0.2
+	tests := []struct {
+		n        float64
+		expected string
+	}{
+		{0, "0"},
+		{math.Copysign(0, -1), "0"},
+		{math.NaN(), "NaN"},
+		{math.Inf(1), "Infinity"},
+		{math.Inf(-1), "-Infinity"},
+		{19.99, "19.99"},
+		{a + b, "0.30000000000000004"},
+		{123456789012, "123456789012"},
+		{1e20, "100000000000000000000"},
+		{1e21, "1e+21"},
+		{1.5e21, "1.5e+21"},
+		{0.000001, "0.000001"},
+		{0.0000001, "1e-7"},
+		{1.23e-7, "1.23e-7"},
+		{-42.5, "-42.5"},
+		{9007199254740993, "9007199254740992"},
+		{5e-324, "5e-324"},
+		{1.7976931348623157e308, "1.7976931348623157e+308"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, Number(tt.n).String())
+	}
+}
+
+func TestNumber_ToString(t *testing.T) {
+	tests := []struct {
+		n        Number
+		radix    []interface{}
+		expected string
+	}{
+		{255, []interface{}{16}, "ff"},
+		{-255, []interface{}{2}, "-11111111"},
+		{0.5, []interface{}{2}, "0.1"},
+		{0.1, []interface{}{2}, "0.0001100110011001100110011001100110011001100110011001101"},
+		{3.75, []interface{}{16}, "3.c"},
+		{1e21, []interface{}{16}, "3635c9adc5dea00000"},
+		{12.5, nil, "12.5"},
+		{Number(math.NaN()), []interface{}{16}, "NaN"},
+	}
+	for _, tt := range tests {
+		res, err := tt.n.ToString(tt.radix...)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expected, res)
+	}
+
+	_, err := Number(1).ToString(37)
+	assert.Error(t, err)
+}
+
+func TestNumber_ToFixed(t *testing.T) {
+	tests := []struct {
+		n        Number
+		digits   []interface{}
+		expected string
+	}{
+		{1.005, []interface{}{2}, "1.00"},
+		{1.255, []interface{}{Number(1)}, "1.3"},
+		{2.5, nil, "3"},
+		{-2.5, nil, "-3"},
+		{0.5, []interface{}{0}, "1"},
+		{12.3456, []interface{}{3}, "12.346"},
+		{0.000001, []interface{}{4}, "0.0000"},
+		{-0.0001, []interface{}{2}, "-0.00"},
+		{42, []interface{}{2}, "42.00"},
+		{1e21, []interface{}{2}, "1e+21"},
+	}
+	for _, tt := range tests {
+		res, err := tt.n.ToFixed(tt.digits...)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expected, res, tt.n.String())
+	}
+
+	_, err := Number(1).ToFixed(101)
+	assert.Error(t, err)
+}
+
+func TestNumber_ToPrecision(t *testing.T) {
+	tests := []struct {
+		n         Number
+		precision []interface{}
+		expected  string
+	}{
+		{123.456, []interface{}{4}, "123.5"},
+		{123.456, []interface{}{2}, "1.2e+2"},
+		{0.000123, []interface{}{2}, "0.00012"},
+		{0.0000001234, []interface{}{2}, "1.2e-7"},
+		{9.99, []interface{}{2}, "10"},
+		{-1.5, []interface{}{1}, "-2"},
+		{0, []interface{}{3}, "0.00"},
+		{42, []interface{}{Undefined{}}, "42"},
+	}
+	for _, tt := range tests {
+		res, err := tt.n.ToPrecision(tt.precision...)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expected, res, tt.n.String())
+	}
+
+	_, err := Number(1).ToPrecision(0)
+	assert.Error(t, err)
+}
+
+func TestDate(t *testing.T) {
+	d, ok := convert(time.Date(2026, 10, 19, 14, 5, 9, 120e6, time.FixedZone("CEST", 2*3600))).(*Date)
+	assert.True(t, ok)
+
+	call := func(name string, args ...interface{}) interface{} {
+		var in []reflect.Value
+		for _, a := range args {
+			in = append(in, reflect.ValueOf(a))
+		}
+		return d.Member(name).(*Func).fnc.Call(in)[0].Interface()
+	}
+
+	assert.Equal(t, Number(2026), call("getFullYear"))
+	assert.Equal(t, Number(9), call("getMonth"))
+	assert.Equal(t, Number(19), call("getDate"))
+	assert.Equal(t, Number(1), call("getDay"))
+	assert.Equal(t, Number(14), call("getHours"))
+	assert.Equal(t, Number(12), call("getUTCHours"))
+	assert.Equal(t, Number(120), call("getMilliseconds"))
+	assert.Equal(t, Number(-120), call("getTimezoneOffset"))
+	assert.Equal(t, Number(1792411509120), call("getTime"))
+	assert.Equal(t, "2026-10-19T12:05:09.120Z", call("toISOString"))
+	assert.Equal(t, "Mon Oct 19 2026 14:05:09 GMT+0200 (CEST)", d.String())
+	assert.Equal(t, "2026", call("format", "2006"), "time.Time methods are still available")
+	assert.Equal(t, Undefined{}, d.Member("missing"))
+
+	b, err := d.MarshalJSON()
+	assert.NoError(t, err)
+	assert.Equal(t, `"2026-10-19T12:05:09.120Z"`, string(b))
+
+	invalid := &Date{}
+	assert.Equal(t, "Invalid Date", invalid.String())
+	assert.True(t, math.IsNaN(float64(invalid.GetFullYear())))
+	_, err = invalid.ToISOString()
+	assert.Error(t, err)
+}
+
+func TestDate_ToLocaleDateString(t *testing.T) {
+	d := NewDate(time.Date(2026, 3, 5, 23, 30, 0, 0, time.UTC))
+	long := &Map{items: map[string]Object{"weekday": String("long"), "year": String("numeric"), "month": String("long"), "day": String("numeric")}}
+
+	tests := []struct {
+		locale   Object
+		options  Object
+		expected string
+	}{
+		{Undefined{}, Undefined{}, "3/5/2026"},
+		{String("en-GB"), Undefined{}, "05/03/2026"},
+		{String("de-DE"), Undefined{}, "5.3.2026"},
+		{String("fr"), Undefined{}, "05/03/2026"},
+		{String("nl-NL"), Undefined{}, "5-3-2026"},
+		{String("xx"), Undefined{}, "3/5/2026"},
+		{&Array{items: []Object{String("xx"), String("de")}}, Undefined{}, "5.3.2026"},
+		{String("en-US"), long, "Thursday, March 5, 2026"},
+		{String("de-DE"), long, "Donnerstag, 5. März 2026"},
+		{String("es"), long, "jueves, 5 de marzo de 2026"},
+		{String("fr-FR"), long, "jeudi 5 mars 2026"},
+		{String("de"), &Map{items: map[string]Object{"month": String("long"), "year": String("numeric")}}, "März 2026"},
+		{String("en-US"), &Map{items: map[string]Object{"day": String("2-digit"), "month": String("2-digit"), "year": String("2-digit")}}, "03/05/26"},
+		{String("en-US"), &Map{items: map[string]Object{"dateStyle": String("medium")}}, "Mar 5, 2026"},
+		{String("de"), &Map{items: map[string]Object{"dateStyle": String("full"), "timeZone": String("Europe/Berlin")}}, "Freitag, 6. März 2026"},
+	}
+
+	for _, tt := range tests {
+		res, err := d.ToLocaleDateString(tt.locale, tt.options)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expected, res, tt.locale.String())
+	}
+
+	_, err := d.ToLocaleDateString(String("en"), &Map{items: map[string]Object{"timeZone": String("Nowhere/Invalid")}})
+	assert.Error(t, err)
+}
+
 func TestArray_Splice(t *testing.T) {
 	arr := convert([]int{1, 2, 3, 4, 5}).(*Array)
 
@@ -107,7 +316,7 @@ func TestString_ToLowerCase(t *testing.T) {
 
 func TestMap(t *testing.T) {
 	m := new(Map)
-	assert.False(t, m.True())
+	assert.True(t, m.True())
 }
 
 func TestArray_Sort(t *testing.T) {
@@ -200,7 +409,7 @@ func TestArray_Shift(t *testing.T) {
 			name:           "test shift with int and empty input array",
 			input:          convert([]int{}).(*Array),
 			expectedArray:  convert([]int{}).(*Array),
-			expectedResult: nil,
+			expectedResult: Undefined{},
 		},
 	}
 
@@ -264,9 +473,9 @@ func TestArray_True(t *testing.T) {
 			expectedResult: true,
 		},
 		{
-			name:           "empty, should be false",
+			name:           "empty, should be true",
 			input:          convert([]int{}).(*Array),
-			expectedResult: false,
+			expectedResult: true,
 		},
 	}
 
@@ -376,3 +585,329 @@ func TestMap_HasMember(t *testing.T) {
 		assert.Equal(t, tt.expectedResult, tt.input.HasMember(tt.member))
 	}
 }
+
+func TestMap_MemberUpperCaseField(t *testing.T) {
+	m := convert(struct{ PI, SQRT1_2 float64 }{3.14, 0.7}).(*Map)
+
+	assert.Equal(t, Number(3.14), m.Member("PI"))
+	assert.Equal(t, Number(0.7), m.Member("SQRT1_2"))
+	assert.Equal(t, Number(3.14), m.Member("pI"))
+
+	m = &Map{items: map[string]Object{"pI": Number(3.14)}}
+	assert.Equal(t, Undefined{}, m.Member("PI"), "only struct fields are resolved by their Go name")
+}
+
+func TestMap_MemberFieldNames(t *testing.T) {
+	type product struct {
+		SKU        string
+		HTTPStatus int
+		ProductID  string
+		Label      string `json:"displayName,omitempty"`
+		Variant    string `pug:"color" json:"variantColor"`
+		Internal   string `json:"-"`
+	}
+	m := convert(product{SKU: "sku-1", HTTPStatus: 200, ProductID: "p-1", Label: "Shirt", Variant: "red", Internal: "x"}).(*Map)
+
+	assert.Equal(t, String("sku-1"), m.Member("sku"))
+	assert.Equal(t, String("sku-1"), m.Member("SKU"))
+	assert.Equal(t, Number(200), m.Member("httpStatus"))
+	assert.Equal(t, String("p-1"), m.Member("productId"))
+	assert.Equal(t, String("p-1"), m.Member("productID"))
+	assert.Equal(t, String("Shirt"), m.Member("displayName"))
+	assert.Equal(t, String("Shirt"), m.Member("label"))
+	assert.Equal(t, String("red"), m.Member("color"))
+	assert.Equal(t, String("red"), m.Member("variantColor"))
+	assert.Equal(t, String("x"), m.Member("internal"))
+	assert.Equal(t, Undefined{}, m.Member("-"))
+}
+
+type upperCaseResolver struct{}
+
+func (upperCaseResolver) FieldNames(field reflect.StructField) []string {
+	return []string{strings.ToUpper(field.Name)}
+}
+
+func TestFieldNameResolver(t *testing.T) {
+	conv := newConverter(RenderOptions{}, &objectTypes{fields: newFieldNames(upperCaseResolver{})})
+
+	m := conv.convert(struct{ DisplayName string }{"Shirt"}, 0).(*Map)
+	assert.Equal(t, String("Shirt"), m.Member("DISPLAYNAME"))
+	assert.Equal(t, String("Shirt"), m.Member("displayName"), "the Go field name is still available")
+
+	var target struct{ DisplayName string }
+	v, err := conv.coerce(convert(map[string]interface{}{"DISPLAYNAME": "Hat"}), reflect.TypeOf(target))
+	require.NoError(t, err)
+	assert.Equal(t, "Hat", v.Field(0).String())
+
+	other := convert(struct{ DisplayName string }{"Shirt"}).(*Map)
+	assert.Equal(t, Undefined{}, other.Member("DISPLAYNAME"), "the resolver only applies to its engine")
+}
+
+func TestEngine_ObjectTypes(t *testing.T) {
+	data := map[string]interface{}{"product": struct{ DisplayName string }{"Shirt"}}
+
+	engine := func() *Engine {
+		s := newRenderState("/", false, nil, flamingo.NullLogger{})
+		s.funcs = FuncMap{}
+		tpl, _, err := s.TokenToTemplate("page", &Token{Type: "Block", Nodes: []*Token{
+			codeToken("typeof product.DISPLAYNAME + ' ' + typeof 'hi'.shout"),
+		}})
+		require.NoError(t, err)
+
+		e := NewEngineWithOptions()
+		e.Logger = flamingo.NullLogger{}
+		e.templates = map[string]*Template{"page": tpl}
+		e.templatesLoaded = 1
+		return e
+	}
+
+	custom := engine()
+	custom.FieldNameResolver = upperCaseResolver{}
+	custom.PrototypeProvider = func() map[string]PrototypeFunc {
+		return map[string]PrototypeFunc{"String.shout": testPrototypeFunc(func(this Object) interface{} {
+			return func() string { return strings.ToUpper(this.String()) + "!" }
+		})}
+	}
+	custom.types = custom.objectTypes()
+	plain := engine()
+
+	render := func(e *Engine) string {
+		t.Helper()
+		r, err := e.Render(context.Background(), "page", data)
+		require.NoError(t, err)
+		b, _ := io.ReadAll(r)
+		return string(b)
+	}
+
+	assert.Equal(t, "string string", render(custom))
+	assert.Equal(t, "undefined undefined", render(plain), "the types of an engine do not leak into other engines")
+}
+
+func TestNumberFormat(t *testing.T) {
+	options := func(o map[string]interface{}) Object { return convert(o) }
+
+	tests := []struct {
+		name     string
+		locale   string
+		options  Object
+		n        float64
+		expected string
+	}{
+		{"default locale", "", nil, 1234567.891, "1,234,567.891"},
+		{"german", "de-DE", nil, 1234567.891, "1.234.567,891"},
+		{"rounding", "en-US", nil, 0.0005, "0.001"},
+		{"no grouping", "en-US", options(map[string]interface{}{"useGrouping": false}), 1234.5, "1234.5"},
+		{"fraction digits", "en-US", options(map[string]interface{}{"minimumFractionDigits": 2, "maximumFractionDigits": 2}), 0.125, "0.13"},
+		{"ties of the shortest decimal form", "en-US", options(map[string]interface{}{"maximumFractionDigits": 2}), 1.005, "1.01"},
+		{"negative ties", "en-US", options(map[string]interface{}{"maximumFractionDigits": 2}), -1.005, "-1.01"},
+		{"minimum fraction digits", "en-US", options(map[string]interface{}{"minimumFractionDigits": 4}), 1.5, "1.5000"},
+		{"integer digits", "en-US", options(map[string]interface{}{"minimumIntegerDigits": 3}), 7, "007"},
+		{"negative", "en-US", nil, -1.5, "-1.5"},
+		{"NaN", "en-US", nil, math.NaN(), "NaN"},
+		{"percent", "en-US", options(map[string]interface{}{"style": "percent"}), 0.256, "26%"},
+		{"percent german", "de-DE", options(map[string]interface{}{"style": "percent", "maximumFractionDigits": 1}), 0.2567, "25,7 %"},
+		{"currency", "en-US", options(map[string]interface{}{"style": "currency", "currency": "EUR"}), 12.5, "€12.50"},
+		{"currency german", "de-DE", options(map[string]interface{}{"style": "currency", "currency": "EUR"}), 1234.5, "1.234,50 €"},
+		{"currency negative", "en-US", options(map[string]interface{}{"style": "currency", "currency": "USD"}), -3, "-$3.00"},
+		{"currency without decimals", "en-US", options(map[string]interface{}{"style": "currency", "currency": "JPY"}), 1234.5, "¥1,235"},
+		{"currency code", "en-US", options(map[string]interface{}{"style": "currency", "currency": "EUR", "currencyDisplay": "code"}), 1, "EUR 1.00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewNumberFormat(tt.locale, tt.options)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, f.Format(tt.n))
+		})
+	}
+
+	_, err := NewNumberFormat("en", options(map[string]interface{}{"style": "currency"}))
+	assert.Error(t, err, "currency is required")
+	_, err = NewNumberFormat("en", options(map[string]interface{}{"minimumFractionDigits": 3, "maximumFractionDigits": 1}))
+	assert.Error(t, err)
+	_, err = NewNumberFormat("en", options(map[string]interface{}{"style": "unit"}))
+	assert.Error(t, err)
+}
+
+func TestNumber_ToLocaleString(t *testing.T) {
+	s, err := Number(1234.5).ToLocaleString(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "1,234.5", s)
+
+	ctx := WithLocale(context.Background(), "de-DE")
+	s, err = Number(1234.5).ToLocaleString(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "1.234,5", s, "the locale of the context is used")
+
+	s, err = Number(1234.5).ToLocaleString(ctx, String("en-US"), convert(map[string]interface{}{"style": "currency", "currency": "USD"}))
+	require.NoError(t, err)
+	assert.Equal(t, "$1,234.50", s)
+}
+
+type (
+	testPrototypeFunc func(this Object) interface{}
+)
+
+func (f testPrototypeFunc) Func(this Object) interface{} { return f(this) }
+
+func TestPrototypeFuncs(t *testing.T) {
+	ctx := withObjectTypes(context.Background(), &objectTypes{prototypes: newPrototypes(map[string]PrototypeFunc{
+		"Number.format": testPrototypeFunc(func(this Object) interface{} {
+			return func(ctx context.Context, currency string) (string, error) {
+				return this.(Number).ToLocaleString(ctx, String("en-US"), convert(map[string]interface{}{"style": "currency", "currency": currency}))
+			}
+		}),
+		"String.shout": testPrototypeFunc(func(this Object) interface{} {
+			return func() string { return strings.ToUpper(this.String()) + "!" }
+		}),
+		"Array.first": testPrototypeFunc(func(this Object) interface{} {
+			return func() Object { return this.(*Array).items[0] }
+		}),
+		"Map.size": testPrototypeFunc(func(this Object) interface{} {
+			return func() int { return len(this.(*Map).Keys()) }
+		}),
+		"String.toUpperCase": testPrototypeFunc(func(this Object) interface{} {
+			return func() string { return "overridden" }
+		}),
+	})})
+
+	assert.Equal(t, "$12.50", renderCodeContext(t, ctx, codeToken("var price = 12.5"), codeToken("price.format('USD')")))
+	assert.Equal(t, "HI!", renderCodeContext(t, ctx, codeToken("'hi'.shout()")))
+	assert.Equal(t, "function", renderCodeContext(t, ctx, codeToken("var {size} = {a: 1, b: 2}"), codeToken("typeof size")), "destructured")
+	assert.Equal(t, "a", renderCodeContext(t, ctx, codeToken("['a', 'b'].first()")))
+	assert.Equal(t, "2", renderCodeContext(t, ctx, codeToken("var m = {a: 1, b: 2}"), codeToken("m.size()")))
+	assert.Equal(t, "3", renderCodeContext(t, ctx, codeToken("var m = {size: 3}"), codeToken("m.size")), "map keys take precedence")
+	assert.Equal(t, "HI", renderCodeContext(t, ctx, codeToken("'hi'.toUpperCase()")), "built-in methods take precedence")
+	assert.Equal(t, Undefined{}, objectTypesFromContext(ctx).member(String("hi"), "unknown"))
+	assert.Equal(t, Undefined{}, (*objectTypes)(nil).member(String("hi"), "shout"), "the methods only apply to their engine")
+}
+
+type lazyTestItem struct {
+	Name  string
+	Inner struct{ Value int }
+}
+
+func (i *lazyTestItem) Label() string { return "item " + i.Name }
+
+func TestMap_LazyStructConversion(t *testing.T) {
+	m := convert(&lazyTestItem{Name: "a"}).(*Map)
+
+	assert.Equal(t, "item a", m.Member("label").(*Func).fnc.Call(nil)[0].Interface())
+	inner := m.Member("inner").(*Map)
+	inner.Assign("value", Number(2))
+	assert.Nil(t, m.items, "members are converted on access")
+
+	assert.ElementsMatch(t, []string{"name", "inner", "label"}, m.Keys())
+	assert.Same(t, inner, m.Member("inner"), "converted members are kept")
+	assert.Equal(t, Number(2), m.Member("inner").Member("value"))
+
+	c := convert(lazyTestItem{Name: "b"}).copy().(*Map)
+	assert.Equal(t, String("b"), c.Member("name"))
+
+	var iface interface{ Label() string } = &lazyTestItem{Name: "c"}
+	fromInterface := convert(reflect.ValueOf(&iface).Elem()).(*Map)
+	assert.Equal(t, String("c"), fromInterface.Member("name"))
+	assert.Equal(t, &lazyTestItem{Name: "c"}, fromInterface.iface())
+}
+
+func TestShare(t *testing.T) {
+	source := convert(map[string]interface{}{
+		"item":  &lazyTestItem{Name: "a"},
+		"lines": []interface{}{map[string]interface{}{"qty": 1}},
+	}).(*Map)
+
+	view := share(source).(*Map)
+	view.Member("item").Member("inner").(*Map).Assign("value", Number(2))
+	view.Member("lines").Member("push").(*Func).fnc.Call([]reflect.Value{reflect.ValueOf(Object(Number(3)))})
+	view.Member("lines").(*Array).items[0].(*Map).Assign("qty", Number(2))
+	view.Assign("added", Bool(true))
+
+	assert.Equal(t, Number(2), view.Member("item").Member("inner").Member("value"), "modified views are kept")
+	assert.Equal(t, `{"qty":2} 3`, view.Member("lines").String())
+	assert.Equal(t, Number(2), view.Member("lines").(*Array).items[0].Member("qty"))
+	assert.Equal(t, Bool(true), view.Member("added"))
+
+	other := share(source).(*Map)
+	assert.Equal(t, Number(0), other.Member("item").Member("inner").Member("value"), "modifications do not leak")
+	assert.Equal(t, Number(1), other.Member("lines").Member("length").(*Func).fnc.Call(nil)[0].Interface())
+	assert.Equal(t, Number(1), other.Member("lines").(*Array).items[0].Member("qty"))
+	assert.Equal(t, Undefined{}, other.Member("added"))
+	assert.Equal(t, Undefined{}, source.Member("added"))
+}
+
+type coerceTestItem struct {
+	Title string `json:"title"`
+	Count int
+	Tags  []string
+	Price *float64
+}
+
+func TestCoerce(t *testing.T) {
+	price := 9.5
+	item := &coerceTestItem{Title: "a"}
+	tests := []struct {
+		name     string
+		in       Object
+		expected interface{}
+	}{
+		{"number to int", Number(2.7), 2},
+		{"string to int", String("12"), 12},
+		{"bool to uint8", Bool(true), uint8(1)},
+		{"string to float64", String("1.5"), 1.5},
+		{"number to string", Number(3), "3"},
+		{"array to string", &Array{items: []Object{Number(1), Number(2)}}, "1 2"},
+		{"string to bool", String(""), false},
+		{"array to slice", &Array{items: []Object{Number(1), String("2")}}, []int{1, 2}},
+		{"array to array", &Array{items: []Object{Number(1)}}, [2]int{1, 0}},
+		{"string to bytes", String("ab"), []byte("ab")},
+		{"map to map", convert(map[string]interface{}{"a": 1, "b": "2"}), map[string]float64{"a": 1, "b": 2}},
+		{
+			"map to struct",
+			convert(map[string]interface{}{"title": "t", "count": "3", "tags": []interface{}{"x", 1}, "Price": 9.5}),
+			coerceTestItem{Title: "t", Count: 3, Tags: []string{"x", "1"}, Price: &price},
+		},
+		{"struct map to original pointer", convert(item), item},
+		{"date to time", NewDate(time.Unix(0, 0)), time.Unix(0, 0)},
+		{"undefined to zero", Undefined{}, 0},
+		{"null to zero", Nil{}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := coerce(tt.in, reflect.TypeOf(tt.expected))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, v.Interface())
+		})
+	}
+
+	t.Run("struct map keeps the pointer", func(t *testing.T) {
+		v, err := coerce(convert(item), reflect.TypeOf(item))
+		require.NoError(t, err)
+		assert.Same(t, item, v.Interface())
+	})
+
+	errors := []struct {
+		name     string
+		in       Object
+		typ      interface{}
+		expected string
+	}{
+		{"not a number", String("x"), 0, `expected int; got "x"`},
+		{"overflow", Number(-1), uint8(0), "-1 overflows uint8"},
+		{"NaN to int", Number(math.NaN()), 0, "expected int; got NaN"},
+		{"NaN to uint", Number(math.NaN()), uint(0), "expected uint; got NaN"},
+		{"infinity to int", Number(math.Inf(1)), int64(0), "Infinity overflows int64"},
+		{"negative infinity to int", Number(math.Inf(-1)), 0, "-Infinity overflows int"},
+		{"array to int", &Array{}, 0, "expected int; got Array"},
+		{"slice item", &Array{items: []Object{Number(1), String("x")}}, []int{}, `item 1: expected int; got "x"`},
+		{"struct field", convert(map[string]interface{}{"count": "x"}), coerceTestItem{}, `field Count: expected int; got "x"`},
+		{"array too long", &Array{items: []Object{Number(1), Number(2)}}, [1]int{}, "2 items do not fit into [1]int"},
+	}
+
+	for _, tt := range errors {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := coerce(tt.in, reflect.TypeOf(tt.typ))
+			assert.EqualError(t, err, tt.expected)
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package templatefunctions
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 
@@ -12,14 +13,19 @@ type (
 	DebugFunc struct{}
 )
 
-// Func as implementation of debug method
+// Func as implementation of debug method, getters are not called if allowDeep is false
 func (df DebugFunc) Func(ctx context.Context) interface{} {
 	return func(o interface{}, allowDeep ...bool) string {
+		options, _ := pugjs.RenderOptionsFromContext(ctx)
 		if len(allowDeep) > 0 {
-			pugjs.AllowDeep = allowDeep[0]
+			options.SkipGetters = !allowDeep[0]
 		}
-		d, _ := json.MarshalIndent(o, "", "    ")
-		pugjs.AllowDeep = true
-		return string(d)
+		b, err := pugjs.MarshalJSON(o, options)
+		if err != nil {
+			return ""
+		}
+		var d bytes.Buffer
+		_ = json.Indent(&d, b, "", "    ")
+		return d.String()
 	}
 }
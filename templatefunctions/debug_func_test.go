@@ -45,3 +45,20 @@ func TestDebugFunc_Func(t *testing.T) {
 		assert.Equal(t, tt.result, debugFunc(tt.input, tt.allowDeep))
 	}
 }
+
+type debugGetter struct{}
+
+func (debugGetter) Value() string { return "called" }
+
+func TestDebugFunc_RenderOptions(t *testing.T) {
+	data := pugjs.Convert(map[string]interface{}{"getter": debugGetter{}})
+
+	debugFunc := DebugFunc{}.Func(context.Background()).(func(o interface{}, allowDeep ...bool) string)
+	assert.Contains(t, debugFunc(data), `"value": "called"`)
+	assert.NotContains(t, debugFunc(data, false), `"value": "called"`)
+
+	ctx := pugjs.WithRenderOptions(context.Background(), pugjs.RenderOptions{SkipGetters: true})
+	debugFunc = DebugFunc{}.Func(ctx).(func(o interface{}, allowDeep ...bool) string)
+	assert.NotContains(t, debugFunc(data), `"value": "called"`)
+	assert.Contains(t, debugFunc(data, true), `"value": "called"`)
+}
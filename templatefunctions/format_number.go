@@ -0,0 +1,65 @@
+package templatefunctions
+
+import (
+	"context"
+
+	"flamingo.me/pugtemplate/pugjs"
+)
+
+type (
+	// FormatNumberFunc formats a number for the locale of the request
+	FormatNumberFunc struct{}
+
+	// FormatCurrencyFunc formats an amount in a currency for the locale of the request
+	FormatCurrencyFunc struct{}
+
+	// FormatPercentFunc formats a ratio as percentage for the locale of the request
+	FormatPercentFunc struct{}
+)
+
+// Func formats a number, the options are the same as for Intl.NumberFormat, plus locale to override the request locale
+func (f FormatNumberFunc) Func(ctx context.Context) interface{} {
+	return func(value interface{}, options ...interface{}) (string, error) {
+		return formatNumber(ctx, value, options, nil)
+	}
+}
+
+// Func formats an amount in the currency, e.g. formatCurrency(12.5, "EUR") is "€12.50" in English
+func (f FormatCurrencyFunc) Func(ctx context.Context) interface{} {
+	return func(value, currency interface{}, options ...interface{}) (string, error) {
+		return formatNumber(ctx, value, options, map[string]interface{}{"style": "currency", "currency": currency})
+	}
+}
+
+// Func formats a ratio as percentage, e.g. formatPercent(0.25) is "25%" in English
+func (f FormatPercentFunc) Func(ctx context.Context) interface{} {
+	return func(value interface{}, options ...interface{}) (string, error) {
+		return formatNumber(ctx, value, options, map[string]interface{}{"style": "percent"})
+	}
+}
+
+// formatNumber formats the value with the given options, the fixed options take precedence
+func formatNumber(ctx context.Context, value interface{}, options []interface{}, fixed map[string]interface{}) (string, error) {
+	opts := pugjs.Convert(map[string]interface{}{}).(*pugjs.Map)
+	if len(options) > 0 {
+		if m, ok := pugjs.Convert(options[0]).(*pugjs.Map); ok {
+			for _, k := range m.Keys() {
+				opts.Assign(k, m.Member(k))
+			}
+		}
+	}
+	for k, v := range fixed {
+		opts.Assign(k, pugjs.Convert(v))
+	}
+
+	locale := pugjs.LocaleFromContext(ctx)
+	if opts.HasMember("locale") {
+		locale = opts.Member("locale").String()
+	}
+
+	format, err := pugjs.NewNumberFormat(locale, opts)
+	if err != nil {
+		return "", err
+	}
+	return format.Format(pugjs.ToNumber(value)), nil
+}
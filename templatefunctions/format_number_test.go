@@ -0,0 +1,40 @@
+package templatefunctions
+
+import (
+	"context"
+	"testing"
+
+	"flamingo.me/pugtemplate/pugjs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatNumberFuncs(t *testing.T) {
+	ctx := pugjs.WithLocale(context.Background(), "de-DE")
+
+	formatNumber := FormatNumberFunc{}.Func(ctx).(func(interface{}, ...interface{}) (string, error))
+	res, err := formatNumber(1234.5678)
+	require.NoError(t, err)
+	assert.Equal(t, "1.234,568", res)
+
+	res, err = formatNumber("1234.5", map[string]interface{}{"locale": "en-US", "minimumFractionDigits": 2})
+	require.NoError(t, err)
+	assert.Equal(t, "1,234.50", res, "the locale option overrides the request locale")
+
+	formatCurrency := FormatCurrencyFunc{}.Func(ctx).(func(interface{}, interface{}, ...interface{}) (string, error))
+	res, err = formatCurrency(12.5, "EUR")
+	require.NoError(t, err)
+	assert.Equal(t, "12,50 €", res)
+
+	res, err = formatCurrency(12.5, "USD", map[string]interface{}{"style": "percent", "maximumFractionDigits": 0})
+	require.NoError(t, err)
+	assert.Equal(t, "13 $", res, "the style can not be overridden")
+
+	_, err = formatCurrency(1, "invalid")
+	assert.Error(t, err)
+
+	formatPercent := FormatPercentFunc{}.Func(context.Background()).(func(interface{}, ...interface{}) (string, error))
+	res, err = formatPercent(0.125, map[string]interface{}{"maximumFractionDigits": 1})
+	require.NoError(t, err)
+	assert.Equal(t, "12.5%", res)
+}
@@ -12,19 +12,22 @@ type (
 	JsJSON struct{}
 
 	// JSON is our Javascript's JSON equivalent
-	JSON struct{}
+	JSON struct {
+		options pugjs.RenderOptions
+	}
 )
 
 // Func returns the JSON object
 func (jl JsJSON) Func(ctx context.Context) interface{} {
 	return func() JSON {
-		return JSON{}
+		options, _ := pugjs.RenderOptionsFromContext(ctx)
+		return JSON{options: options}
 	}
 }
 
 // Stringify returns a string from the json
 func (j JSON) Stringify(x interface{}) string {
-	b, err := json.Marshal(x)
+	b, err := pugjs.MarshalJSON(x, j.options)
 	if err != nil {
 		panic(err)
 	}
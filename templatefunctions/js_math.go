@@ -2,108 +2,266 @@ package templatefunctions
 
 import (
 	"context"
+	"hash/fnv"
 	"math"
-	"reflect"
+	"math/bits"
+	"math/rand"
+	"sync"
+
+	"flamingo.me/flamingo/v3/framework/web"
+	"flamingo.me/pugtemplate/pugjs"
 )
 
 type (
 	// JsMath is exported as a template function
 	JsMath struct{}
 
-	// Math is our Javascript's Math equivalent
-	Math struct{}
+	// Math is our Javascript's Math equivalent, the pug tags keep the upper case constant names
+	Math struct {
+		E       float64 `pug:"E"`
+		LN10    float64 `pug:"LN10"`
+		LN2     float64 `pug:"LN2"`
+		LOG10E  float64 `pug:"LOG10E"`
+		LOG2E   float64 `pug:"LOG2E"`
+		PI      float64 `pug:"PI"`
+		SQRT1_2 float64 `pug:"SQRT1_2"`
+		SQRT2   float64 `pug:"SQRT2"`
+
+		random *randomSource
+	}
+
+	// randomSource is a random number generator which is safe for concurrent use
+	randomSource struct {
+		mu   sync.Mutex
+		rand *rand.Rand
+	}
 )
 
+// mathRandomKey is the request value holding the random source of a request
+const mathRandomKey = "pugtemplate.math.random"
+
 // Func as implementation of debug method
 func (ml JsMath) Func(ctx context.Context) interface{} {
 	return func() Math {
-		return Math{}
+		return Math{
+			E:       math.E,
+			LN10:    math.Ln10,
+			LN2:     math.Ln2,
+			LOG10E:  math.Log10E,
+			LOG2E:   math.Log2E,
+			PI:      math.Pi,
+			SQRT1_2: 1 / math.Sqrt2,
+			SQRT2:   math.Sqrt2,
+			random:  requestRandomSource(ctx),
+		}
 	}
 }
 
+// requestRandomSource returns the random source of the current request, seeded by the request method and URL,
+// so the same request renders the same random numbers. Without a request the global source is used.
+func requestRandomSource(ctx context.Context) *randomSource {
+	request := web.RequestFromContext(ctx)
+	if request == nil || request.Request() == nil {
+		return nil
+	}
+
+	if source, ok := request.Values.Load(mathRandomKey); ok {
+		return source.(*randomSource)
+	}
+
+	seed := fnv.New64a()
+	_, _ = seed.Write([]byte(request.Request().Method + " " + request.Request().URL.String()))
+	source, _ := request.Values.LoadOrStore(mathRandomKey, &randomSource{rand: rand.New(rand.NewSource(int64(seed.Sum64())))})
+	return source.(*randomSource)
+}
+
+// Float64 returns a random number in [0, 1)
+func (r *randomSource) Float64() float64 {
+	if r == nil {
+		return rand.Float64()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rand.Float64()
+}
+
+// Abs returns the absolute value
+func (m Math) Abs(x interface{}) float64 { return math.Abs(pugjs.ToNumber(x)) }
+
+// Acos returns the arccosine
+func (m Math) Acos(x interface{}) float64 { return math.Acos(pugjs.ToNumber(x)) }
+
+// Acosh returns the hyperbolic arccosine
+func (m Math) Acosh(x interface{}) float64 { return math.Acosh(pugjs.ToNumber(x)) }
+
+// Asin returns the arcsine
+func (m Math) Asin(x interface{}) float64 { return math.Asin(pugjs.ToNumber(x)) }
+
+// Asinh returns the hyperbolic arcsine
+func (m Math) Asinh(x interface{}) float64 { return math.Asinh(pugjs.ToNumber(x)) }
+
+// Atan returns the arctangent
+func (m Math) Atan(x interface{}) float64 { return math.Atan(pugjs.ToNumber(x)) }
+
+// Atanh returns the hyperbolic arctangent
+func (m Math) Atanh(x interface{}) float64 { return math.Atanh(pugjs.ToNumber(x)) }
+
+// Atan2 returns the arctangent of y/x
+func (m Math) Atan2(y, x interface{}) float64 {
+	return math.Atan2(pugjs.ToNumber(y), pugjs.ToNumber(x))
+}
+
+// Cbrt returns the cube root
+func (m Math) Cbrt(x interface{}) float64 { return math.Cbrt(pugjs.ToNumber(x)) }
+
 // Ceil rounds a value up to the next biggest integer
-func (m Math) Ceil(x interface{}) int {
-	if reflect.TypeOf(x).Kind() == reflect.Int {
-		x = float64(reflect.ValueOf(x).Int())
-	} else if reflect.TypeOf(x).Kind() == reflect.Int64 {
-		x = float64(reflect.ValueOf(x).Int())
-	} else if reflect.TypeOf(x).Kind() == reflect.Float64 {
-		x = float64(reflect.ValueOf(x).Float())
-	}
-	return int(math.Ceil(x.(float64)))
+func (m Math) Ceil(x interface{}) float64 { return math.Ceil(pugjs.ToNumber(x)) }
+
+// Clz32 returns the number of leading zero bits of the 32-bit integer
+func (m Math) Clz32(x interface{}) float64 {
+	return float64(bits.LeadingZeros32(toUint32(pugjs.ToNumber(x))))
 }
 
-// Min gets the minimum value
-func (m Math) Min(x ...interface{}) (res float64) {
-	res = float64(math.MaxFloat64)
+// Cos returns the cosine
+func (m Math) Cos(x interface{}) float64 { return math.Cos(pugjs.ToNumber(x)) }
+
+// Cosh returns the hyperbolic cosine
+func (m Math) Cosh(x interface{}) float64 { return math.Cosh(pugjs.ToNumber(x)) }
+
+// Exp returns e to the power of x
+func (m Math) Exp(x interface{}) float64 { return math.Exp(pugjs.ToNumber(x)) }
+
+// Expm1 returns e to the power of x, minus 1
+func (m Math) Expm1(x interface{}) float64 { return math.Expm1(pugjs.ToNumber(x)) }
+
+// Floor rounds a value down to the next smallest integer
+func (m Math) Floor(x interface{}) float64 { return math.Floor(pugjs.ToNumber(x)) }
+
+// Fround rounds to the nearest single precision float
+func (m Math) Fround(x interface{}) float64 { return float64(float32(pugjs.ToNumber(x))) }
+
+// Hypot returns the square root of the sum of squares
+func (m Math) Hypot(x ...interface{}) float64 {
+	res := 0.
+	nan := false
 	for _, v := range x {
-		if reflect.TypeOf(v).Kind() == reflect.Int {
-			v = float64(reflect.ValueOf(v).Int())
-		} else if reflect.TypeOf(v).Kind() == reflect.Int64 {
-			v = float64(reflect.ValueOf(v).Int())
-		} else if reflect.TypeOf(v).Kind() == reflect.Float64 {
-			v = float64(reflect.ValueOf(v).Float())
+		f := pugjs.ToNumber(v)
+		if math.IsInf(f, 0) {
+			return math.Inf(1)
 		}
-		if v.(float64) < res {
-			res = v.(float64)
+		if math.IsNaN(f) {
+			nan = true
 		}
+		res = math.Hypot(res, f)
+	}
+	if nan {
+		return math.NaN()
 	}
-	return
+	return res
+}
+
+// Imul returns the 32-bit integer multiplication
+func (m Math) Imul(x, y interface{}) float64 {
+	return float64(int32(toUint32(pugjs.ToNumber(x)) * toUint32(pugjs.ToNumber(y))))
 }
 
+// Log returns the natural logarithm
+func (m Math) Log(x interface{}) float64 { return math.Log(pugjs.ToNumber(x)) }
+
+// Log10 returns the base 10 logarithm
+func (m Math) Log10(x interface{}) float64 { return math.Log10(pugjs.ToNumber(x)) }
+
+// Log1p returns the natural logarithm of 1 + x
+func (m Math) Log1p(x interface{}) float64 { return math.Log1p(pugjs.ToNumber(x)) }
+
+// Log2 returns the base 2 logarithm
+func (m Math) Log2(x interface{}) float64 { return math.Log2(pugjs.ToNumber(x)) }
+
 // Max gets the maximum value
-func (m Math) Max(x ...interface{}) (res float64) {
-	res = float64(math.SmallestNonzeroFloat64)
+func (m Math) Max(x ...interface{}) float64 {
+	res := math.Inf(-1)
 	for _, v := range x {
-		if reflect.TypeOf(v).Kind() == reflect.Int {
-			v = float64(reflect.ValueOf(v).Int())
-		} else if reflect.TypeOf(v).Kind() == reflect.Int64 {
-			v = float64(reflect.ValueOf(v).Int())
-		} else if reflect.TypeOf(v).Kind() == reflect.Float64 {
-			v = float64(reflect.ValueOf(v).Float())
-		}
-		if v.(float64) > res {
-			res = v.(float64)
-		}
+		res = math.Max(res, pugjs.ToNumber(v))
 	}
-	return
+	return res
 }
 
-// Trunc drops the decimals
-func (m Math) Trunc(x interface{}) int {
-	if reflect.TypeOf(x).Kind() == reflect.Int {
-		x = float64(reflect.ValueOf(x).Int())
-	} else if reflect.TypeOf(x).Kind() == reflect.Int64 {
-		x = float64(reflect.ValueOf(x).Int())
-	} else if reflect.TypeOf(x).Kind() == reflect.Float64 {
-		x = float64(reflect.ValueOf(x).Float())
-	}
-	return int(math.Trunc(x.(float64)))
+// Min gets the minimum value
+func (m Math) Min(x ...interface{}) float64 {
+	res := math.Inf(1)
+	for _, v := range x {
+		res = math.Min(res, pugjs.ToNumber(v))
+	}
+	return res
+}
+
+// Pow returns x to the power of y
+func (m Math) Pow(x, y interface{}) float64 {
+	fx, fy := pugjs.ToNumber(x), pugjs.ToNumber(y)
+	// unlike Go, JavaScript does not treat 1 special
+	if math.IsNaN(fy) || (math.Abs(fx) == 1 && math.IsInf(fy, 0)) {
+		return math.NaN()
+	}
+	return math.Pow(fx, fy)
 }
 
-func round(n float64) float64 {
-	if n >= 0.5 {
-		return math.Trunc(n + 0.5)
+// Random returns a random number in [0, 1)
+func (m Math) Random() float64 { return m.random.Float64() }
+
+// Round rounds a value to the nearest integer, .5 is rounded up
+func (m Math) Round(x interface{}) float64 {
+	f := pugjs.ToNumber(x)
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return f
 	}
-	if n <= -0.5 {
-		return math.Trunc(n - 0.5)
+	res := math.Floor(f)
+	if f-res >= 0.5 {
+		res++
 	}
-	if math.IsNaN(n) {
-		return math.NaN()
+	if res == 0 && math.Signbit(f) {
+		return math.Copysign(0, -1)
+	}
+	return res
+}
+
+// Sign returns 1 for positive and -1 for negative values, 0 and NaN are kept
+func (m Math) Sign(x interface{}) float64 {
+	f := pugjs.ToNumber(x)
+	switch {
+	case f > 0:
+		return 1
+	case f < 0:
+		return -1
 	}
-	return 0
+	return f
 }
 
-// Round rounds a value to the nearest integer
-func (m Math) Round(x interface{}) int {
-	if reflect.TypeOf(x).Kind() == reflect.Int {
-		x = float64(reflect.ValueOf(x).Int())
-	} else if reflect.TypeOf(x).Kind() == reflect.Int64 {
-		x = float64(reflect.ValueOf(x).Int())
-	} else if reflect.TypeOf(x).Kind() == reflect.Float64 {
-		x = float64(reflect.ValueOf(x).Float())
-	} else {
+// Sin returns the sine
+func (m Math) Sin(x interface{}) float64 { return math.Sin(pugjs.ToNumber(x)) }
+
+// Sinh returns the hyperbolic sine
+func (m Math) Sinh(x interface{}) float64 { return math.Sinh(pugjs.ToNumber(x)) }
+
+// Sqrt returns the square root
+func (m Math) Sqrt(x interface{}) float64 { return math.Sqrt(pugjs.ToNumber(x)) }
+
+// Tan returns the tangent
+func (m Math) Tan(x interface{}) float64 { return math.Tan(pugjs.ToNumber(x)) }
+
+// Tanh returns the hyperbolic tangent
+func (m Math) Tanh(x interface{}) float64 { return math.Tanh(pugjs.ToNumber(x)) }
+
+// Trunc drops the decimals
+func (m Math) Trunc(x interface{}) float64 { return math.Trunc(pugjs.ToNumber(x)) }
+
+// toUint32 implements the ECMAScript ToUint32 abstract operation
+func toUint32(f float64) uint32 {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
 		return 0
 	}
-	return int(round(x.(float64)))
+	f = math.Mod(math.Trunc(f), 1<<32)
+	if f < 0 {
+		f += 1 << 32
+	}
+	return uint32(f)
 }
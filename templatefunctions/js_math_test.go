@@ -2,9 +2,13 @@ package templatefunctions
 
 import (
 	"context"
+	"math"
+	"net/http/httptest"
 	"testing"
 
 	"flamingo.me/flamingo/v3/framework/flamingo"
+	"flamingo.me/flamingo/v3/framework/web"
+	"flamingo.me/pugtemplate/pugjs"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -18,24 +22,72 @@ func TestJsMath(t *testing.T) {
 	assert.Equal(t, 3., math.Max(1, int64(2), 3.))
 
 	// ceil
-	assert.Equal(t, 2, math.Ceil(2))
-	assert.Equal(t, 2, math.Ceil(int64(2)))
-	assert.Equal(t, 2, math.Ceil(2.))
-	assert.Equal(t, 3, math.Ceil(2.4))
-	assert.Equal(t, 3, math.Ceil(2.5))
+	assert.Equal(t, 2., math.Ceil(2))
+	assert.Equal(t, 2., math.Ceil(int64(2)))
+	assert.Equal(t, 2., math.Ceil(2.))
+	assert.Equal(t, 3., math.Ceil(2.4))
+	assert.Equal(t, 3., math.Ceil(2.5))
 
 	// trunc
-	assert.Equal(t, 2, math.Trunc(2))
-	assert.Equal(t, 2, math.Trunc(int64(2)))
-	assert.Equal(t, 2, math.Trunc(2.))
-	assert.Equal(t, 2, math.Trunc(2.1))
+	assert.Equal(t, 2., math.Trunc(2))
+	assert.Equal(t, 2., math.Trunc(int64(2)))
+	assert.Equal(t, 2., math.Trunc(2.))
+	assert.Equal(t, 2., math.Trunc(2.1))
 
 	// round
-	assert.Equal(t, 2, math.Round(2))
-	assert.Equal(t, 2, math.Round(int64(2)))
-	assert.Equal(t, 2, math.Round(2.))
-	assert.Equal(t, 2, math.Round(2.1))
-	assert.Equal(t, 2, math.Round(2.4))
-	assert.Equal(t, 3, math.Round(2.5))
-	assert.Equal(t, 3, math.Round(2.9))
+	assert.Equal(t, 2., math.Round(2))
+	assert.Equal(t, 2., math.Round(int64(2)))
+	assert.Equal(t, 2., math.Round(2.))
+	assert.Equal(t, 2., math.Round(2.1))
+	assert.Equal(t, 2., math.Round(2.4))
+	assert.Equal(t, 3., math.Round(2.5))
+	assert.Equal(t, 3., math.Round(2.9))
+}
+
+func TestJsMath_ES(t *testing.T) {
+	m := JsMath{}.Func(context.Background()).(func() Math)()
+
+	assert.Equal(t, math.Pi, m.PI)
+	assert.Equal(t, 2., m.Floor(pugjs.Number(2.9)))
+	assert.Equal(t, 2., m.Floor("2.9"), "strings are coerced")
+	assert.Equal(t, -3., m.Floor(-2.1))
+	assert.Equal(t, -2., m.Round(-2.5), "halfs are rounded up")
+	assert.True(t, math.Signbit(m.Round(-0.4)))
+	assert.True(t, math.IsNaN(m.Round("a")))
+	assert.Equal(t, 3., m.Abs(-3))
+	assert.Equal(t, 8., m.Pow(2, 3))
+	assert.True(t, math.IsNaN(m.Pow(1, math.Inf(1))))
+	assert.Equal(t, 3., m.Sqrt(9))
+	assert.Equal(t, -1., m.Sign(-5))
+	assert.Equal(t, 0., m.Sign(0))
+	assert.True(t, math.IsNaN(m.Sign(pugjs.Undefined{})))
+	assert.Equal(t, -1., m.Max(-1, -2), "max of negative numbers")
+	assert.True(t, math.IsInf(m.Max(), -1))
+	assert.True(t, math.IsNaN(m.Min(1, "a")))
+	assert.Equal(t, 5., m.Hypot(3, 4))
+	assert.Equal(t, 31., m.Clz32(1))
+	assert.Equal(t, -5., m.Imul(0xffffffff, 5))
+}
+
+func TestJsMath_Random(t *testing.T) {
+	mathFor := func(url string) Math {
+		ctx := web.ContextWithRequest(context.Background(), web.CreateRequest(httptest.NewRequest("GET", url, nil), nil))
+		return JsMath{}.Func(ctx).(func() Math)()
+	}
+
+	first := mathFor("/page?a=1")
+	values := []float64{first.Random(), first.Random()}
+	assert.NotEqual(t, values[0], values[1])
+	for _, v := range values {
+		assert.True(t, v >= 0 && v < 1)
+	}
+
+	second := mathFor("/page?a=1")
+	assert.Equal(t, values, []float64{second.Random(), second.Random()}, "the same request renders the same numbers")
+
+	other := mathFor("/page?a=2")
+	assert.NotEqual(t, values[0], other.Random())
+
+	noRequest := JsMath{}.Func(context.Background()).(func() Math)()
+	assert.True(t, noRequest.Random() < 1)
 }
@@ -0,0 +1,93 @@
+package templatefunctions
+
+import (
+	"context"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"flamingo.me/pugtemplate/pugjs"
+)
+
+type (
+	// JsNumber is exported as a template function
+	JsNumber struct{}
+
+	// Number is our Javascript's Number equivalent, the pug tags keep the upper case constant names
+	Number struct {
+		EPSILON           float64 `pug:"EPSILON"`
+		MAX_SAFE_INTEGER  float64 `pug:"MAX_SAFE_INTEGER"`
+		MAX_VALUE         float64 `pug:"MAX_VALUE"`
+		MIN_SAFE_INTEGER  float64 `pug:"MIN_SAFE_INTEGER"`
+		MIN_VALUE         float64 `pug:"MIN_VALUE"`
+		NaN               float64 `pug:"NaN"`
+		NEGATIVE_INFINITY float64 `pug:"NEGATIVE_INFINITY"`
+		POSITIVE_INFINITY float64 `pug:"POSITIVE_INFINITY"`
+	}
+)
+
+// floatPrefix matches the longest prefix parseFloat accepts
+var floatPrefix = regexp.MustCompile(`^[+-]?(Infinity|(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?)`)
+
+// Func returns the Number object
+func (nl JsNumber) Func(ctx context.Context) interface{} {
+	return func() Number {
+		return Number{
+			EPSILON:           math.Nextafter(1, 2) - 1,
+			MAX_SAFE_INTEGER:  1<<53 - 1,
+			MAX_VALUE:         math.MaxFloat64,
+			MIN_SAFE_INTEGER:  -(1<<53 - 1),
+			MIN_VALUE:         math.SmallestNonzeroFloat64,
+			NaN:               math.NaN(),
+			NEGATIVE_INFINITY: math.Inf(-1),
+			POSITIVE_INFINITY: math.Inf(1),
+		}
+	}
+}
+
+// number returns the value if it is a number, without coercion
+func number(x interface{}) (float64, bool) {
+	n, ok := pugjs.Convert(x).(pugjs.Number)
+	return float64(n), ok
+}
+
+// IsFinite checks if the value is a finite number
+func (n Number) IsFinite(x interface{}) bool {
+	f, ok := number(x)
+	return ok && !math.IsNaN(f) && !math.IsInf(f, 0)
+}
+
+// IsInteger checks if the value is a number without decimals
+func (n Number) IsInteger(x interface{}) bool {
+	f, ok := number(x)
+	return ok && !math.IsInf(f, 0) && math.Trunc(f) == f
+}
+
+// IsNaN checks if the value is the number NaN
+func (n Number) IsNaN(x interface{}) bool {
+	f, ok := number(x)
+	return ok && math.IsNaN(f)
+}
+
+// IsSafeInteger checks if the value is an integer which can be represented exactly
+func (n Number) IsSafeInteger(x interface{}) bool {
+	f, _ := number(x)
+	return n.IsInteger(x) && math.Abs(f) <= 1<<53-1
+}
+
+// ParseFloat parses the leading decimal number of a string, and is NaN if there is none
+func (n Number) ParseFloat(x interface{}) float64 {
+	s := floatPrefix.FindString(strings.TrimSpace(pugjs.Convert(x).String()))
+	if s == "" {
+		return math.NaN()
+	}
+	if strings.HasSuffix(s, "Infinity") {
+		if s[0] == '-' {
+			return math.Inf(-1)
+		}
+		return math.Inf(1)
+	}
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
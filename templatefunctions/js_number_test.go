@@ -0,0 +1,37 @@
+package templatefunctions
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"flamingo.me/pugtemplate/pugjs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJsNumber(t *testing.T) {
+	n := JsNumber{}.Func(context.Background()).(func() Number)()
+
+	assert.Equal(t, 9007199254740991., n.MAX_SAFE_INTEGER)
+	assert.True(t, math.IsNaN(n.NaN))
+
+	assert.True(t, n.IsInteger(pugjs.Number(5)))
+	assert.True(t, n.IsInteger(5))
+	assert.False(t, n.IsInteger(5.5))
+	assert.False(t, n.IsInteger("5"), "strings are not coerced")
+	assert.False(t, n.IsInteger(math.Inf(1)))
+	assert.True(t, n.IsSafeInteger(n.MAX_SAFE_INTEGER))
+	assert.False(t, n.IsSafeInteger(n.MAX_SAFE_INTEGER+1))
+
+	assert.True(t, n.IsNaN(math.NaN()))
+	assert.False(t, n.IsNaN("a"))
+	assert.True(t, n.IsFinite(1))
+	assert.False(t, n.IsFinite("1"))
+
+	assert.Equal(t, 3.5, n.ParseFloat("3.5px"))
+	assert.Equal(t, 0.5, n.ParseFloat("  .5"))
+	assert.Equal(t, -1e3, n.ParseFloat("-1e3e"))
+	assert.Equal(t, 42., n.ParseFloat(pugjs.Number(42)))
+	assert.True(t, math.IsInf(n.ParseFloat("Infinity and beyond"), 1))
+	assert.True(t, math.IsNaN(n.ParseFloat("px3")))
+}
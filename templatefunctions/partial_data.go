@@ -0,0 +1,22 @@
+package templatefunctions
+
+import (
+	"context"
+
+	"flamingo.me/pugtemplate/pugjs"
+)
+
+type (
+	// SetPartialDataFunc adds data to the partials JSON response
+	SetPartialDataFunc struct{}
+)
+
+// Func sets the value of the key in the data of the current partials render, outside of partial renders it does nothing
+func (*SetPartialDataFunc) Func(ctx context.Context) interface{} {
+	return func(key string, value interface{}) string {
+		if data := pugjs.PartialDataFromContext(ctx); data != nil {
+			data.Set(key, pugjs.Convert(value))
+		}
+		return ""
+	}
+}
@@ -0,0 +1,67 @@
+package templatefunctions
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"flamingo.me/flamingo/v3/framework/flamingo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"flamingo.me/pugtemplate/pugjs"
+)
+
+func TestSetPartialDataFunc_Func(t *testing.T) {
+	ctx, data := pugjs.WithPartialData(context.Background())
+
+	setPartialData := new(SetPartialDataFunc).Func(ctx).(func(string, interface{}) string)
+	assert.Equal(t, "", setPartialData("cartamount", 4))
+	assert.Equal(t, map[string]interface{}{"cartamount": pugjs.Number(4)}, data.Values())
+
+	setPartialData = new(SetPartialDataFunc).Func(context.Background()).(func(string, interface{}) string)
+	assert.NotPanics(t, func() { setPartialData("cartamount", 4) })
+}
+
+// writeCodeTemplate writes a template of one code line to the page templates of the basedir
+func writeCodeTemplate(t *testing.T, basedir, name, code string) {
+	t.Helper()
+
+	inline := true
+	b, err := json.Marshal(&pugjs.Token{Type: "Block", Nodes: []*pugjs.Token{
+		{Type: "Code", Val: code, MustEscape: true, IsInline: &inline},
+	}})
+	require.NoError(t, err)
+
+	file := filepath.Join(basedir, "template", "page", name+".ast.json")
+	require.NoError(t, os.MkdirAll(filepath.Dir(file), 0o755))
+	require.NoError(t, os.WriteFile(file, b, 0o644))
+}
+
+func TestSetPartialDataFunc_RenderPartialsWithData(t *testing.T) {
+	basedir := t.TempDir()
+	writeCodeTemplate(t, basedir, "cart", "setPartialData('ignored', true)")
+	writeCodeTemplate(t, basedir, "cart.partial/minicart", "setPartialData('cartamount', 4) + 'mini'")
+	writeCodeTemplate(t, basedir, "cart.partial/total", "setPartialData('total', {amount: 12.5}) + 'total'")
+
+	e := pugjs.NewEngineWithOptions()
+	e.Basedir = basedir
+	e.Logger = flamingo.NullLogger{}
+	e.FuncProvider = func() map[string]flamingo.TemplateFunc {
+		return map[string]flamingo.TemplateFunc{"setPartialData": new(SetPartialDataFunc)}
+	}
+
+	partials, data, err := e.RenderPartialsWithData(context.Background(), "cart", nil, []string{"minicart", "total"})
+	require.NoError(t, err)
+
+	b, _ := io.ReadAll(partials["minicart"])
+	assert.Equal(t, "mini", string(b))
+	assert.Equal(t, pugjs.Number(4), data["cartamount"])
+	assert.Equal(t, pugjs.Number(12.5), data["total"].(*pugjs.Map).Member("amount"))
+
+	_, err = e.Render(context.Background(), "cart", nil)
+	require.NoError(t, err, "setPartialData is ignored outside of partial renders")
+}
@@ -0,0 +1,48 @@
+package templatefunctions
+
+import (
+	"context"
+
+	"flamingo.me/pugtemplate/pugjs"
+)
+
+type (
+	// SetStatusFunc sets the status code of the response, e.g. a 404 for an empty listing
+	SetStatusFunc struct{}
+
+	// SetHeaderFunc sets a header of the response, e.g. Cache-Control or Vary
+	SetHeaderFunc struct{}
+
+	// AddCacheTagFunc adds cache tags to the response, to purge cached pages by
+	AddCacheTagFunc struct{}
+)
+
+// Func sets the status code of the current render, outside of metadata renders it does nothing
+func (*SetStatusFunc) Func(ctx context.Context) interface{} {
+	return func(status int) (string, error) {
+		if metadata := pugjs.RenderMetadataFromContext(ctx); metadata != nil {
+			return "", metadata.SetStatus(status)
+		}
+		return "", nil
+	}
+}
+
+// Func sets the header of the current render, outside of metadata renders it does nothing
+func (*SetHeaderFunc) Func(ctx context.Context) interface{} {
+	return func(key, value string) string {
+		if metadata := pugjs.RenderMetadataFromContext(ctx); metadata != nil {
+			metadata.SetHeader(key, value)
+		}
+		return ""
+	}
+}
+
+// Func adds the cache tags to the current render, outside of metadata renders it does nothing
+func (*AddCacheTagFunc) Func(ctx context.Context) interface{} {
+	return func(tags ...string) string {
+		if metadata := pugjs.RenderMetadataFromContext(ctx); metadata != nil {
+			metadata.AddCacheTag(tags...)
+		}
+		return ""
+	}
+}
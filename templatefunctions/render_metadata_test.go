@@ -0,0 +1,33 @@
+package templatefunctions
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"flamingo.me/pugtemplate/pugjs"
+)
+
+func TestRenderMetadataFuncs(t *testing.T) {
+	ctx, metadata := pugjs.WithRenderMetadata(context.Background())
+
+	setStatus := new(SetStatusFunc).Func(ctx).(func(int) (string, error))
+	_, err := setStatus(http.StatusNotFound)
+	assert.NoError(t, err)
+	_, err = setStatus(1)
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusNotFound, metadata.Status())
+
+	setHeader := new(SetHeaderFunc).Func(ctx).(func(string, string) string)
+	assert.Equal(t, "", setHeader("vary", "Accept-Language"))
+	assert.Equal(t, "Accept-Language", metadata.Header().Get("Vary"))
+
+	addCacheTag := new(AddCacheTagFunc).Func(ctx).(func(...string) string)
+	assert.Equal(t, "", addCacheTag("product-1", "category-2"))
+	assert.Equal(t, []string{"product-1", "category-2"}, metadata.CacheTags())
+
+	setStatus = new(SetStatusFunc).Func(context.Background()).(func(int) (string, error))
+	assert.NotPanics(t, func() { _, _ = setStatus(http.StatusNotFound) })
+}